@@ -16,6 +16,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -34,19 +35,25 @@ import (
 	image "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-var emptyDigest = digest.Canonical.FromBytes([]byte{})
+var (
+	emptyDigest = digest.Canonical.FromBytes([]byte{})
+)
 
 type api struct {
 	client     *http.Client
 	user, pass string
 	authCache  map[string]string
+	warnings   *warningCollector
+	rateLimit  apiRateLimit
+	proxy      *apiProxy
 }
 
 type apiOpt func(*api)
 
 func apiNew(client *http.Client, opts ...apiOpt) *api {
 	a := &api{
-		client: client,
+		client:   client,
+		warnings: warningCollectorNew(),
 	}
 	for _, opt := range opts {
 		opt(a)
@@ -102,6 +109,7 @@ func (a *api) Do(opts ...apiDoOpt) error {
 	} else if len(errs) > 1 {
 		return errors.Join(errs...)
 	}
+	a.proxy.addNamespace(req)
 	// add cached auth header if available
 	if a.authCache != nil {
 		err = a.addCachedAuth(req)
@@ -112,13 +120,16 @@ func (a *api) Do(opts ...apiDoOpt) error {
 	if out != nil {
 		out = redactWriter{w: out}
 	}
-	wt := &wrapTransport{out: out, orig: a.client.Transport}
+	wt := &wrapTransport{out: out, orig: a.client.Transport, warnings: a.warnings}
 	if a.client.Transport == nil {
 		wt.orig = http.DefaultTransport
 	}
 	c := *a.client
 	c.Transport = wt
 	resp, err := c.Do(req)
+	if err == nil {
+		resp, err = a.rateLimit.retry(&c, req, resp)
+	}
 	if err != nil {
 		return err
 	}
@@ -140,6 +151,9 @@ func (a *api) Do(opts ...apiDoOpt) error {
 				}
 			}
 			resp, err = c.Do(req)
+			if err == nil {
+				resp, err = a.rateLimit.retry(&c, req, resp)
+			}
 			if err != nil {
 				return err
 			}
@@ -151,6 +165,9 @@ func (a *api) Do(opts ...apiDoOpt) error {
 			errs = append(errs, err)
 		}
 	}
+	// warnings are verified on every registry response, including redirects and auth challenges
+	errs = append(errs, wt.warningErrs...)
+	a.proxy.verifyResponse(resp)
 	if resp.Body != nil {
 		_ = resp.Body.Close()
 	}
@@ -545,6 +562,159 @@ func (a *api) BlobPatchChunked(registry, repo string, dig digest.Digest, td *tes
 	return nil
 }
 
+// BlobPatchResume pushes the first chunk of a blob, queries the upload status, and resumes the upload from the reported offset.
+func (a *api) BlobPatchResume(registry, repo string, dig digest.Digest, td *testData, opts ...apiDoOpt) error {
+	bodyBytes, ok := td.blobs[dig]
+	if !ok {
+		return fmt.Errorf("BlobPatchResume missing expected digest to send: %s%.0w", dig.String(), errAPITestError)
+	}
+	u, err := url.Parse(registry + "/v2/" + repo + "/blobs/uploads/")
+	if err != nil {
+		return err
+	}
+	minStr := ""
+	loc := ""
+	uuid := ""
+	err = a.Do(
+		apiWithMethod("POST"),
+		apiWithURL(u),
+		apiWithContentLength(0),
+		apiExpectStatus(http.StatusAccepted),
+		apiReturnHeader("OCI-Chunk-Min-Length", &minStr),
+		apiReturnHeader("Location", &loc),
+		apiReturnHeader("Docker-Upload-UUID", &uuid),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob post failed: %w", err)
+	}
+	// the first chunk is half of the blob, adjusted to the min chunk size
+	chunkSize := max(len(bodyBytes)/2, chunkMin)
+	if minStr != "" {
+		min, err := strconv.Atoi(minStr)
+		if err != nil {
+			return fmt.Errorf("parsing OCI-Chunk-Min-Length size %q failed: %w", minStr, err)
+		}
+		chunkSize = max(chunkSize, min)
+	}
+	if chunkSize >= len(bodyBytes) {
+		return fmt.Errorf("blob of size %d is too small to resume with a chunk size of %d%.0w", len(bodyBytes), chunkSize, errAPITestError)
+	}
+	if loc == "" {
+		return fmt.Errorf("blob post did not return a location")
+	}
+	u, err = u.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("blob post could not parse location header: %w", err)
+	}
+	err = a.Do(
+		apiWithMethod("PATCH"),
+		apiWithURL(u),
+		apiWithContentLength(int64(chunkSize)),
+		apiWithHeaderAdd("Content-Type", mtOctetStream),
+		apiWithHeaderAdd("Content-Range", fmt.Sprintf("%d-%d", 0, chunkSize-1)),
+		apiWithBody(bodyBytes[:chunkSize]),
+		apiExpectStatus(http.StatusAccepted),
+		apiReturnHeader("Location", &loc),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob patch failed: %w", err)
+	}
+	// drop any open connections to simulate a client resuming after an interruption
+	a.client.CloseIdleConnections()
+	if loc == "" {
+		return fmt.Errorf("blob patch did not return a location")
+	}
+	u, err = u.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("blob patch could not parse location header: %w", err)
+	}
+	statusLoc := ""
+	rangeHeader := ""
+	statusUUID := ""
+	err = a.Do(
+		apiWithMethod("GET"),
+		apiWithURL(u),
+		apiExpectStatus(http.StatusNoContent),
+		apiReturnHeader("Location", &statusLoc),
+		apiReturnHeader("Range", &rangeHeader),
+		apiReturnHeader("Docker-Upload-UUID", &statusUUID),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob upload status request failed: %w", err)
+	}
+	if statusLoc == "" {
+		return fmt.Errorf("blob upload status did not return a location")
+	}
+	if uuid != "" && statusUUID != "" && uuid != statusUUID {
+		return fmt.Errorf("blob upload status returned Docker-Upload-UUID %q, expected %q", statusUUID, uuid)
+	}
+	rangeEnd, found := strings.CutPrefix(rangeHeader, "0-")
+	if !found {
+		return fmt.Errorf("blob upload status Range header is missing the 0- prefix: %q", rangeHeader)
+	}
+	lastByte, err := strconv.Atoi(rangeEnd)
+	if err != nil {
+		return fmt.Errorf("blob upload status Range header could not be parsed: %q", rangeHeader)
+	}
+	if lastByte < 0 || lastByte > chunkSize-1 {
+		return fmt.Errorf("blob upload status Range unexpected, received %q, expected \"0-%d\"", rangeHeader, chunkSize-1)
+	}
+	// resume from the offset reported by the registry, which may have persisted less than was sent
+	u, err = u.Parse(statusLoc)
+	if err != nil {
+		return fmt.Errorf("blob upload status could not parse location header: %w", err)
+	}
+	start := lastByte + 1
+	err = a.Do(
+		apiWithMethod("PATCH"),
+		apiWithURL(u),
+		apiWithContentLength(int64(len(bodyBytes)-start)),
+		apiWithHeaderAdd("Content-Type", mtOctetStream),
+		apiWithHeaderAdd("Content-Range", fmt.Sprintf("%d-%d", start, len(bodyBytes)-1)),
+		apiWithBody(bodyBytes[start:]),
+		apiExpectStatus(http.StatusAccepted),
+		apiReturnHeader("Location", &loc),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob patch to resume upload failed: %w", err)
+	}
+	if loc == "" {
+		return fmt.Errorf("blob patch did not return a location")
+	}
+	u, err = u.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("blob patch could not parse location header: %w", err)
+	}
+	qa := u.Query()
+	qa.Set("digest", dig.String())
+	u.RawQuery = qa.Encode()
+	resp := http.Response{Header: http.Header{}}
+	err = a.Do(
+		apiWithMethod("PUT"),
+		apiWithURL(u),
+		apiWithContentLength(0),
+		apiWithHeaderAdd("Content-Type", mtOctetStream),
+		apiExpectStatus(http.StatusCreated),
+		apiReturnHeader("Location", &loc),
+		apiReturnResponse(&resp),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob put failed: %w", err)
+	}
+	if err := a.VerifyDigest(&resp, dig, opts...); err != nil {
+		return err
+	}
+	if err := a.BlobVerifyLocation(u, loc, bodyBytes, opts...); err != nil {
+		return err
+	}
+	return nil
+}
+
 func (a *api) BlobPatchStream(registry, repo string, dig digest.Digest, td *testData, opts ...apiDoOpt) error {
 	flags := a.GetFlags(opts...)
 	bodyBytes, ok := td.blobs[dig]
@@ -633,6 +803,87 @@ func (a *api) BlobPatchStream(registry, repo string, dig digest.Digest, td *test
 	return nil
 }
 
+// BlobPostAlgorithm starts an upload with the digest-algorithm parameter and completes it with a single PUT.
+func (a *api) BlobPostAlgorithm(registry, repo string, dig digest.Digest, td *testData, opts ...apiDoOpt) error {
+	bodyBytes, ok := td.blobs[dig]
+	if !ok {
+		return fmt.Errorf("BlobPostAlgorithm missing expected digest to send: %s%.0w", dig.String(), errAPITestError)
+	}
+	u, err := url.Parse(registry + "/v2/" + repo + "/blobs/uploads/")
+	if err != nil {
+		return err
+	}
+	qa := u.Query()
+	qa.Set("digest-algorithm", dig.Algorithm().String())
+	u.RawQuery = qa.Encode()
+	loc := ""
+	err = a.Do(
+		apiWithMethod("POST"),
+		apiWithURL(u),
+		apiWithContentLength(0),
+		apiExpectStatus(http.StatusAccepted),
+		apiReturnHeader("Location", &loc),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob post with digest-algorithm failed: %w", err)
+	}
+	if loc == "" {
+		return fmt.Errorf("blob post did not return a location")
+	}
+	u, err = u.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("blob post could not parse location header: %w", err)
+	}
+	qa = u.Query()
+	qa.Set("digest", dig.String())
+	u.RawQuery = qa.Encode()
+	resp := http.Response{Header: http.Header{}}
+	err = a.Do(
+		apiWithMethod("PUT"),
+		apiWithURL(u),
+		apiWithContentLength(int64(len(bodyBytes))),
+		apiWithHeaderAdd("Content-Type", mtOctetStream),
+		apiWithBody(bodyBytes),
+		apiExpectStatus(http.StatusCreated),
+		apiReturnHeader("Location", &loc),
+		apiReturnResponse(&resp),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob put failed: %w", err)
+	}
+	if err := a.VerifyDigest(&resp, dig, opts...); err != nil {
+		return err
+	}
+	if err := a.BlobVerifyLocation(u, loc, bodyBytes, opts...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BlobPostAlgorithmUnsupported verifies an upload with an unsupported digest-algorithm is rejected.
+func (a *api) BlobPostAlgorithmUnsupported(registry, repo string, algo string, opts ...apiDoOpt) error {
+	u, err := url.Parse(registry + "/v2/" + repo + "/blobs/uploads/")
+	if err != nil {
+		return err
+	}
+	qa := u.Query()
+	qa.Set("digest-algorithm", algo)
+	u.RawQuery = qa.Encode()
+	err = a.Do(
+		apiWithMethod("POST"),
+		apiWithURL(u),
+		apiWithContentLength(0),
+		apiExpectStatus(http.StatusBadRequest),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return fmt.Errorf("blob post with unsupported digest-algorithm %s: %w", algo, err)
+	}
+	return nil
+}
+
 func (a *api) BlobPostCancel(registry, repo string, dig digest.Digest, td *testData, opts ...apiDoOpt) error {
 	u, err := url.Parse(registry + "/v2/" + repo + "/blobs/uploads/")
 	if err != nil {
@@ -1034,6 +1285,63 @@ func (a *api) ManifestPut(registry, repo, ref string, dig digest.Digest, td *tes
 	return errors.Join(errs...)
 }
 
+// ManifestGetConditional pulls a manifest with an optional If-None-Match header, returning the status and ETag.
+func (a *api) ManifestGetConditional(registry, repo, ref, ifNoneMatch string, opts ...apiDoOpt) (int, string, error) {
+	u, err := url.Parse(registry + "/v2/" + repo + "/manifests/" + ref)
+	if err != nil {
+		return 0, "", err
+	}
+	status := 0
+	etag := ""
+	reqOpts := []apiDoOpt{
+		apiWithMethod("GET"),
+		apiWithURL(u),
+		apiWithHeaderAdd("Accept", mtOCIIndex),
+		apiWithHeaderAdd("Accept", mtOCIImage),
+		apiExpectStatus(http.StatusOK, http.StatusNotModified),
+		apiReturnStatus(&status),
+		apiReturnHeader("ETag", &etag),
+	}
+	if ifNoneMatch != "" {
+		reqOpts = append(reqOpts, apiWithHeaderAdd("If-None-Match", ifNoneMatch))
+	}
+	err = a.Do(append(reqOpts, opts...)...)
+	if err != nil {
+		return status, etag, fmt.Errorf("conditional manifest get failed: %w", err)
+	}
+	return status, etag, nil
+}
+
+// ManifestPutConditional pushes a manifest with a conditional header, e.g. If-Match, returning the status and ETag.
+// The precondition failing with a 412 is not treated as an error.
+func (a *api) ManifestPutConditional(registry, repo, ref string, dig digest.Digest, td *testData, header, value string, opts ...apiDoOpt) (int, string, error) {
+	bodyBytes, ok := td.manifests[dig]
+	if !ok {
+		return 0, "", fmt.Errorf("ManifestPutConditional missing expected digest to send: %s%.0w", dig.String(), errAPITestError)
+	}
+	u, err := url.Parse(registry + "/v2/" + repo + "/manifests/" + ref)
+	if err != nil {
+		return 0, "", err
+	}
+	status := 0
+	etag := ""
+	err = a.Do(
+		apiWithMethod("PUT"),
+		apiWithURL(u),
+		apiWithBody(bodyBytes),
+		apiWithHeaderAdd("Content-Type", detectMediaType(bodyBytes)),
+		apiWithHeaderAdd(header, value),
+		apiExpectStatus(http.StatusCreated, http.StatusPreconditionFailed),
+		apiReturnStatus(&status),
+		apiReturnHeader("ETag", &etag),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return status, etag, fmt.Errorf("conditional manifest put with %s: %s failed: %w", header, value, err)
+	}
+	return status, etag, nil
+}
+
 func (a *api) PingReq(registry string, opts ...apiDoOpt) error {
 	u, err := url.Parse(registry + "/v2/")
 	if err != nil {
@@ -1050,24 +1358,69 @@ func (a *api) PingReq(registry string, opts ...apiDoOpt) error {
 	return nil
 }
 
-func (a *api) ReferrersList(registry, repo string, dig digest.Digest, opts ...apiDoOpt) (image.Index, error) {
+// ReferrersList returns the referrers to a digest, following any Link headers to combine every page of the response.
+// An empty artifactType returns the unfiltered list.
+func (a *api) ReferrersList(registry, repo string, dig digest.Digest, artifactType string, opts ...apiDoOpt) (image.Index, error) {
 	rl := image.Index{}
 	u, err := url.Parse(registry + "/v2/" + repo + "/referrers/" + dig.String())
 	if err != nil {
 		return rl, err
 	}
-	err = a.Do(
-		apiWithURL(u),
-		apiExpectHeader("Content-Type", mtOCIIndex),
-		apiExpectStatus(http.StatusOK),
-		apiReturnJSONBody(&rl),
-		apiWithAnd(opts),
-	)
-	// validate the response
-	if err == nil && (rl.MediaType != mtOCIIndex || rl.SchemaVersion != 2) {
-		err = fmt.Errorf("referrers response is not a valid OCI index (media type and schema version)%.0w", errAPITestFail)
+	if artifactType != "" {
+		u.RawQuery = url.Values{"artifactType": {artifactType}}.Encode()
+	}
+	seen := map[digest.Digest]bool{}
+	visited := map[string]bool{}
+	// a filtered response must report the applied filters on every page
+	filters := ""
+	for page := 0; ; page++ {
+		if page >= referrersMaxPages {
+			return rl, fmt.Errorf("referrers pagination did not terminate after %d pages%.0w", referrersMaxPages, errAPITestFail)
+		}
+		visited[u.String()] = true
+		pageIdx := image.Index{}
+		var next *url.URL
+		pageFilters := ""
+		err = a.Do(
+			apiWithURL(u),
+			apiExpectHeader("Content-Type", mtOCIIndex),
+			apiExpectStatus(http.StatusOK),
+			apiReturnJSONBody(&pageIdx),
+			apiReturnNextLink(&next),
+			apiReturnHeader("OCI-Filters-Applied", &pageFilters),
+			apiWithAnd(opts),
+		)
+		if err != nil {
+			return rl, err
+		}
+		if page == 0 {
+			filters = pageFilters
+		} else if artifactType != "" && pageFilters != filters {
+			return rl, fmt.Errorf("referrers OCI-Filters-Applied header on page %d is %q, expected %q from the first page%.0w", page, pageFilters, filters, errAPITestFail)
+		}
+		// validate the response
+		if pageIdx.MediaType != mtOCIIndex || pageIdx.SchemaVersion != 2 {
+			return rl, fmt.Errorf("referrers response is not a valid OCI index (media type and schema version), page %d%.0w", page, errAPITestFail)
+		}
+		if page == 0 {
+			rl = pageIdx
+			rl.Manifests = []image.Descriptor{}
+		}
+		for _, desc := range pageIdx.Manifests {
+			if seen[desc.Digest] {
+				return rl, fmt.Errorf("referrers response included %s more than once, page %d%.0w", desc.Digest.String(), page, errAPITestFail)
+			}
+			seen[desc.Digest] = true
+			rl.Manifests = append(rl.Manifests, desc)
+		}
+		if next == nil {
+			return rl, nil
+		}
+		if visited[next.String()] {
+			return rl, fmt.Errorf("referrers Link header on page %d returns to a previous page, %s%.0w", page, next.String(), errAPITestFail)
+		}
+		u = next
 	}
-	return rl, err
 }
 
 func (a *api) TagList(registry, repo string, opts ...apiDoOpt) (specs.TagList, error) {
@@ -1092,6 +1445,23 @@ func (a *api) TagList(registry, repo string, opts ...apiDoOpt) (specs.TagList, e
 	return tl, err
 }
 
+// TagListPage requests a single page of the tag listing, returning the absolute URL from a Link header with rel="next", or nil on the last page.
+func (a *api) TagListPage(u *url.URL, opts ...apiDoOpt) (specs.TagList, *url.URL, error) {
+	tl := specs.TagList{}
+	var next *url.URL
+	err := a.Do(
+		apiWithURL(u),
+		apiExpectStatus(http.StatusOK),
+		apiReturnJSONBody(&tl),
+		apiReturnNextLink(&next),
+		apiWithAnd(opts),
+	)
+	if err != nil {
+		return tl, nil, err
+	}
+	return tl, next, nil
+}
+
 func apiWithAnd(opts []apiDoOpt) apiDoOpt {
 	ret := apiDoOpt{}
 	reqFns := [](func(*http.Request) error){}
@@ -1304,6 +1674,63 @@ func apiExpectStatus(statusCodes ...int) apiDoOpt {
 	}
 }
 
+// apiErrorBody is the last response with a 4XX status, saved to validate the error response body.
+type apiErrorBody struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+// Verify checks the body is a JSON error response with valid codes, including at least one of the expected codes.
+// A body that is empty or not JSON is allowed by the spec and returns errRegUnsupported.
+func (eb apiErrorBody) Verify(codes ...string) error {
+	if len(bytes.TrimSpace(eb.body)) == 0 {
+		return fmt.Errorf("registry returned status %d without a body%.0w", eb.status, errRegUnsupported)
+	}
+	if mediaTypeBase(eb.contentType) != "application/json" && !json.Valid(eb.body) {
+		return fmt.Errorf("registry returned status %d with a non-JSON body, Content-Type %q%.0w", eb.status, eb.contentType, errRegUnsupported)
+	}
+	er := specs.ErrorResponse{}
+	if err := json.Unmarshal(eb.body, &er); err != nil {
+		return fmt.Errorf("failed to parse error response: %w", err)
+	}
+	if len(er.Errors) == 0 {
+		return fmt.Errorf("error response for status %d does not contain any errors", eb.status)
+	}
+	errs := []error{}
+	received := []string{}
+	for _, ei := range er.Errors {
+		received = append(received, ei.Code)
+		if code := specs.ErrorCode(ei.Code); !code.Valid() {
+			errs = append(errs, fmt.Errorf("error code %q must only contain uppercase letters and underscores", ei.Code))
+		} else if !code.Known() {
+			errs = append(errs, fmt.Errorf("error code %q is not defined by the spec", ei.Code))
+		}
+	}
+	if !slices.ContainsFunc(received, func(code string) bool { return slices.Contains(codes, code) }) {
+		errs = append(errs, fmt.Errorf("error response for status %d expected one of the codes %v, received %v", eb.status, codes, received))
+	}
+	return errors.Join(errs...)
+}
+
+func apiReturnErrorBody(eb *apiErrorBody) apiDoOpt {
+	return apiDoOpt{
+		respFn: func(resp *http.Response) error {
+			if resp.StatusCode < 400 || resp.StatusCode >= 500 {
+				return nil
+			}
+			body, err := cloneBodyResp(resp)
+			if err != nil {
+				return err
+			}
+			eb.status = resp.StatusCode
+			eb.contentType = resp.Header.Get("Content-Type")
+			eb.body = body
+			return nil
+		},
+	}
+}
+
 func apiReturnHeader(key string, val *string) apiDoOpt {
 	return apiDoOpt{
 		respFn: func(resp *http.Response) error {
@@ -1316,6 +1743,15 @@ func apiReturnHeader(key string, val *string) apiDoOpt {
 	}
 }
 
+func apiReturnHeaderValues(key string, vals *[]string) apiDoOpt {
+	return apiDoOpt{
+		respFn: func(resp *http.Response) error {
+			*vals = resp.Header.Values(key)
+			return nil
+		},
+	}
+}
+
 func apiReturnJSONBody(data any) apiDoOpt {
 	return apiDoOpt{
 		respFn: func(resp *http.Response) error {
@@ -1324,6 +1760,20 @@ func apiReturnJSONBody(data any) apiDoOpt {
 	}
 }
 
+// apiReturnNextLink returns the absolute URL from a Link header with rel="next", or nil when there is no next page.
+func apiReturnNextLink(next **url.URL) apiDoOpt {
+	return apiDoOpt{
+		respFn: func(resp *http.Response) error {
+			u, err := specs.NextLink(resp)
+			if err != nil {
+				return err
+			}
+			*next = u
+			return nil
+		},
+	}
+}
+
 func apiReturnResponse(ret *http.Response) apiDoOpt {
 	return apiDoOpt{
 		respFn: func(r *http.Response) error {
@@ -1385,13 +1835,16 @@ func (a *api) getAuthHeader(client http.Client, resp *http.Response) (string, er
 		if err != nil {
 			return "", fmt.Errorf("failed to parse realm url: %w", err)
 		}
+		if err := a.proxy.verifyRealm(u, parsed.Service); err != nil {
+			return "", err
+		}
 		param := url.Values{}
 		param.Set("service", parsed.Service)
 		if parsed.Scope != "" {
 			param.Set("scope", parsed.Scope)
 		}
 		u.RawQuery = param.Encode()
-		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		req, err := http.NewRequestWithContext(context.WithValue(context.Background(), apiTokenRequestKey{}, true), http.MethodGet, u.String(), nil)
 		if err != nil {
 			return "", fmt.Errorf("failed to created request: %w", err)
 		}
@@ -1492,10 +1945,16 @@ func authCacheKey(req http.Request) (string, error) {
 }
 
 type wrapTransport struct {
-	out  io.Writer
-	orig http.RoundTripper
+	out         io.Writer
+	orig        http.RoundTripper
+	warnings    *warningCollector
+	warningErrs []error
 }
 
+// apiTokenRequestKey marks the context of a request to the token server,
+// which is not part of the registry and is not checked for Warning headers.
+type apiTokenRequestKey struct{}
+
 func (wt *wrapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if wt.out != nil {
 		if err := printRequest(req, wt.out); err != nil {
@@ -1503,6 +1962,12 @@ func (wt *wrapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}
 	resp, err := wt.orig.RoundTrip(req)
+	if err == nil && wt.warnings != nil && req.Context().Value(apiTokenRequestKey{}) == nil {
+		wt.warnings.Add(resp.Header)
+		if err := warningVerify(resp.Header); err != nil {
+			wt.warningErrs = append(wt.warningErrs, err)
+		}
+	}
 	if wt.out != nil {
 		if err == nil {
 			if err := printResponse(resp, wt.out); err != nil {
@@ -22,42 +22,64 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/goccy/go-yaml"
+
+	distspec "github.com/opencontainers/distribution-spec/specs-go"
+	specs "github.com/opencontainers/distribution-spec/specs-go/v1"
 )
 
 const (
-	confGoTag      = "conformance"
-	envOCIConf     = "OCI"
-	envOCIConfFile = "OCI_CONFIGURATION"
-	envOCIVersion  = "OCI_VERSION"
-	defaultOCIConf = "oci-conformance.yaml"
-	chunkMin       = 1024
-	truncateBody   = 4096
-	biVCSCommit    = "vcs.revision"
+	confGoTag         = "conformance"
+	envOCIConf        = "OCI"
+	envOCIConfFile    = "OCI_CONFIGURATION"
+	envOCIVersion     = "OCI_VERSION"
+	defaultOCIConf    = "oci-conformance.yaml"
+	chunkMin          = 1024
+	algoUnsupported   = "md5" // digest algorithm a registry is not expected to support
+	truncateBody      = 4096
+	referrersMaxPages = 1000 // limit on the pages followed when listing referrers
+	biVCSCommit       = "vcs.revision"
 )
 
 var Version = "unknown"
 
+// confVersions lists the supported spec versions, with the aliases that select each version.
+// The tests enabled by default for a version come from the feature table in specs-go/v1.
+// The dev version tests the current development version of specs-go, which includes the unreleased features.
+var confVersions = []struct {
+	name    string
+	aliases []string
+	dev     bool
+}{
+	{name: "1.0"},
+	{name: "1.1", aliases: []string{"", "stable"}},
+	{name: "1.1+dev", aliases: []string{"dev"}, dev: true},
+}
+
 type config struct {
-	Registry   string       `conformance:"REGISTRY" yaml:"registry"`                // hostname:port of registry server
-	TLS        tls          `conformance:"TLS" yaml:"tls"`                          // tls configuration for communicating with the registry
-	Repo1      string       `conformance:"REPO1" yaml:"repo1"`                      // first repository for pushing content
-	Repo2      string       `conformance:"REPO2" yaml:"repo2"`                      // second repository for pushing content
-	LoginUser  string       `conformance:"USERNAME" yaml:"username"`                // username for login, leave blank for anonymous
-	LoginPass  string       `conformance:"PASSWORD" yaml:"password"`                // password for login, leave blank for anonymous
-	CacheAuth  bool         `conformance:"CACHE_AUTH" yaml:"cacheAuth"`             // whether to allow auth to be cached and reused between requests
-	LogLevel   string       `conformance:"LOG" yaml:"logging"`                      // slog logging level, defaults to "warn"
-	LogWriter  io.Writer    `yaml:"-"`                                              // writer used for logging, defaults to os.Stderr
-	FilterTest string       `conformance:"FILTER_TEST" yaml:"filterTest,omitempty"` // only run tests with a given name prefix
-	APIs       configAPI    `conformance:"API" yaml:"apis"`                         // API tests to run
-	Data       configData   `conformance:"DATA" yaml:"data"`                        // data types to test
-	ROData     configROData `conformance:"RO_DATA" yaml:"roData"`                   // read-only data for registries that do not support push methods
-	ResultsDir string       `conformance:"RESULTS_DIR" yaml:"resultsDir"`           // directory to write results
-	Version    string       `conformance:"VERSION" yaml:"version"`                  // spec version used to set test defaults
-	schemeReg  string       `yaml:"-"`                                              // base for url to access the registry
-	Commit     string       `yaml:"commit"`                                         // injected git commit hash from runtime
-	Legacy     bool         `yaml:"legacy,omitempty"`                               // injected to indicate that conformance was run with "go test"
+	Registry   string          `conformance:"REGISTRY" yaml:"registry"`                // hostname:port of registry server
+	TLS        tls             `conformance:"TLS" yaml:"tls"`                          // tls configuration for communicating with the registry
+	Repo1      string          `conformance:"REPO1" yaml:"repo1"`                      // first repository for pushing content
+	Repo2      string          `conformance:"REPO2" yaml:"repo2"`                      // second repository for pushing content
+	RepoTags   string          `conformance:"REPO_TAGS" yaml:"repoTags"`               // dedicated repository for the tag pagination data
+	LoginUser  string          `conformance:"USERNAME" yaml:"username"`                // username for login, leave blank for anonymous
+	LoginPass  string          `conformance:"PASSWORD" yaml:"password"`                // password for login, leave blank for anonymous
+	CacheAuth  bool            `conformance:"CACHE_AUTH" yaml:"cacheAuth"`             // whether to allow auth to be cached and reused between requests
+	LogLevel   string          `conformance:"LOG" yaml:"logging"`                      // slog logging level, defaults to "warn"
+	LogWriter  io.Writer       `yaml:"-"`                                              // writer used for logging, defaults to os.Stderr
+	FilterTest string          `conformance:"FILTER_TEST" yaml:"filterTest,omitempty"` // only run tests with a given name prefix
+	APIs       configAPI       `conformance:"API" yaml:"apis"`                         // API tests to run
+	Data       configData      `conformance:"DATA" yaml:"data"`                        // data types to test
+	ROData     configROData    `conformance:"RO_DATA" yaml:"roData"`                   // read-only data for registries that do not support push methods
+	RateLimit  configRateLimit `conformance:"RATE_LIMIT" yaml:"rateLimit"`             // retry requests rejected with a 429 status
+	Proxy      configProxy     `conformance:"PROXY" yaml:"proxy"`                      // test a proxy registry using the ns query parameter
+	ResultsDir string          `conformance:"RESULTS_DIR" yaml:"resultsDir"`           // directory to write results
+	Version    string          `conformance:"VERSION" yaml:"version"`                  // spec version used to set test defaults
+	schemeReg  string          `yaml:"-"`                                              // base for url to access the registry
+	Commit     string          `yaml:"commit"`                                         // injected git commit hash from runtime
+	Legacy     bool            `yaml:"legacy,omitempty"`                               // injected to indicate that conformance was run with "go test"
 }
 
 type tls int
@@ -69,25 +91,28 @@ const (
 )
 
 type configAPI struct {
-	Ping      bool            `conformance:"PING" yaml:"ping"`
-	Pull      bool            `conformance:"PULL" yaml:"pull"`
-	Push      bool            `conformance:"PUSH" yaml:"push"`
-	Blobs     configBlobs     `conformance:"BLOBS" yaml:"blobs"`
-	Manifests configManifests `conformance:"MANIFESTS" yaml:"manifests"`
-	Tags      configTags      `conformance:"TAGS" yaml:"tags"`
-	Referrer  bool            `conformance:"REFERRER" yaml:"referrer"`
+	Ping       bool            `conformance:"PING" yaml:"ping"`
+	Pull       bool            `conformance:"PULL" yaml:"pull"`
+	Push       bool            `conformance:"PUSH" yaml:"push"`
+	Blobs      configBlobs     `conformance:"BLOBS" yaml:"blobs"`
+	Manifests  configManifests `conformance:"MANIFESTS" yaml:"manifests"`
+	Tags       configTags      `conformance:"TAGS" yaml:"tags"`
+	Referrer   bool            `conformance:"REFERRER" yaml:"referrer"`
+	ErrorCodes bool            `conformance:"ERROR_CODES" yaml:"errorCodes"` // validate the JSON body and error code of 4XX responses
 }
 
 type configBlobs struct {
-	Atomic         bool `conformance:"ATOMIC" yaml:"atomic"`
-	Delete         bool `conformance:"DELETE" yaml:"delete"`
-	DigestHeader   bool `conformance:"DIGEST_HEADER" yaml:"digestHeader"`
-	MountAnonymous bool `conformance:"MOUNT_ANONYMOUS" yaml:"mountAnonymous"`
-	UploadCancel   bool `conformance:"UPLOAD_CANCEL" yaml:"uploadCancel"`
+	Atomic          bool `conformance:"ATOMIC" yaml:"atomic"`
+	Delete          bool `conformance:"DELETE" yaml:"delete"`
+	DigestAlgorithm bool `conformance:"DIGEST_ALGORITHM" yaml:"digestAlgorithm"`
+	DigestHeader    bool `conformance:"DIGEST_HEADER" yaml:"digestHeader"`
+	MountAnonymous  bool `conformance:"MOUNT_ANONYMOUS" yaml:"mountAnonymous"`
+	UploadCancel    bool `conformance:"UPLOAD_CANCEL" yaml:"uploadCancel"`
 }
 
 type configManifests struct {
 	Atomic       bool `conformance:"ATOMIC" yaml:"atomic"`
+	Conditional  bool `conformance:"CONDITIONAL" yaml:"conditional"` // ETag support is optional, unsupported requests are skipped
 	Delete       bool `conformance:"DELETE" yaml:"delete"`
 	DigestHeader bool `conformance:"DIGEST_HEADER" yaml:"digestHeader"`
 	TagParam     bool `conformance:"TAG_PARAM" yaml:"tagParam"`
@@ -100,21 +125,35 @@ type configTags struct {
 }
 
 type configData struct {
-	Image            bool `conformance:"IMAGE" yaml:"image"`                       // standard OCI image
-	Index            bool `conformance:"INDEX" yaml:"index"`                       // multi-platform manifest
-	IndexList        bool `conformance:"INDEX_LIST" yaml:"indexList"`              // nested index
-	Sparse           bool `conformance:"SPARSE" yaml:"sparse"`                     // manifest where some descriptors have not been pushed
-	Artifact         bool `conformance:"ARTIFACT" yaml:"artifact"`                 // OCI artifact
-	Subject          bool `conformance:"SUBJECT" yaml:"subject"`                   // artifact with the subject defined
-	SubjectMissing   bool `conformance:"SUBJECT_MISSING" yaml:"subjectMissing"`    // artifact with a missing subject
-	ArtifactList     bool `conformance:"ARTIFACT_LIST" yaml:"artifactList"`        // index of artifacts
-	SubjectList      bool `conformance:"SUBJECT_LIST" yaml:"subjectList"`          // index with a subject
-	DataField        bool `conformance:"DATA_FIELD" yaml:"dataField"`              // data field in descriptor
-	Nondistributable bool `conformance:"NONDISTRIBUTABLE" yaml:"nondistributable"` // nondistributable image, deprecated in image-spec 1.1
-	CustomFields     bool `conformance:"CUSTOM_FIELDS" yaml:"customFields"`        // fields added beyond the OCI spec
-	NoLayers         bool `conformance:"NO_LAYERS" yaml:"noLayers"`                // image manifest with an empty layer list
-	EmptyBlob        bool `conformance:"EMPTY_BLOB" yaml:"emptyBlob"`              // a zero byte blob
-	Sha512           bool `conformance:"SHA512" yaml:"sha512"`                     // sha512 digest algorithm
+	Image               bool `conformance:"IMAGE" yaml:"image"`                              // standard OCI image
+	Index               bool `conformance:"INDEX" yaml:"index"`                              // multi-platform manifest
+	IndexList           bool `conformance:"INDEX_LIST" yaml:"indexList"`                     // nested index
+	Sparse              bool `conformance:"SPARSE" yaml:"sparse"`                            // manifest where some descriptors have not been pushed
+	Artifact            bool `conformance:"ARTIFACT" yaml:"artifact"`                        // OCI artifact
+	Subject             bool `conformance:"SUBJECT" yaml:"subject"`                          // artifact with the subject defined
+	SubjectMissing      bool `conformance:"SUBJECT_MISSING" yaml:"subjectMissing"`           // artifact with a missing subject
+	ArtifactList        bool `conformance:"ARTIFACT_LIST" yaml:"artifactList"`               // index of artifacts
+	SubjectList         bool `conformance:"SUBJECT_LIST" yaml:"subjectList"`                 // index with a subject
+	DataField           bool `conformance:"DATA_FIELD" yaml:"dataField"`                     // data field in descriptor
+	Nondistributable    bool `conformance:"NONDISTRIBUTABLE" yaml:"nondistributable"`        // nondistributable image, deprecated in image-spec 1.1
+	CustomFields        bool `conformance:"CUSTOM_FIELDS" yaml:"customFields"`               // fields added beyond the OCI spec
+	NoLayers            bool `conformance:"NO_LAYERS" yaml:"noLayers"`                       // image manifest with an empty layer list
+	EmptyBlob           bool `conformance:"EMPTY_BLOB" yaml:"emptyBlob"`                     // a zero byte blob
+	Sha512              bool `conformance:"SHA512" yaml:"sha512"`                            // sha512 digest algorithm
+	ReferrersPagination int  `conformance:"REFERRERS_PAGINATION" yaml:"referrersPagination"` // number of referrers pushed to a single subject to test pagination, 0 to disable
+	TagPagination       int  `conformance:"TAG_PAGINATION" yaml:"tagPagination"`             // number of tags pushed to test pagination of the tag list, 0 to disable
+}
+
+type configRateLimit struct {
+	Retries int           `conformance:"RETRIES" yaml:"retries"`  // number of times a throttled request is retried, 0 to disable
+	Backoff time.Duration `conformance:"BACKOFF" yaml:"backoff"`  // delay before the first retry without a Retry-After header, doubled on each retry
+	MaxWait time.Duration `conformance:"MAX_WAIT" yaml:"maxWait"` // limit on the delay between retries, including the Retry-After value
+}
+
+type configProxy struct {
+	Upstream  string   `conformance:"UPSTREAM" yaml:"upstream"`    // source host sent in the ns parameter on pulls, proxy tests are disabled when empty
+	AuthHosts []string `conformance:"AUTH_HOSTS" yaml:"authHosts"` // token servers and services of the upstream registry, matched exactly
+	Namespace string   `conformance:"NAMESPACE" yaml:"namespace"`  // "echo" requires the OCI-Namespace header, "optional" only verifies the value when returned
 }
 
 type configROData struct {
@@ -160,11 +199,20 @@ func configLoad() (config, error) {
 	if configVersionEnv != "" {
 		configVersion = configVersionEnv
 	}
+	version, specVersion, err := confVersion(configVersion)
+	if err != nil {
+		return config{}, err
+	}
+	feature := func(f specs.Feature) bool {
+		return specs.FeatureSupported(specVersion, f)
+	}
 	// initialize config with default values based on spec version
 	c := config{
+		Version:    version,
 		Registry:   "localhost:5000",
 		Repo1:      "conformance/repo1",
 		Repo2:      "conformance/repo2",
+		RepoTags:   "conformance/tags",
 		CacheAuth:  true,
 		LogLevel:   "warn",
 		LogWriter:  os.Stderr,
@@ -174,61 +222,58 @@ func configLoad() (config, error) {
 			Pull: true,
 			Push: true,
 			Blobs: configBlobs{
-				Atomic:         true,
-				Delete:         true,
-				DigestHeader:   false,
-				MountAnonymous: true,
-				UploadCancel:   false,
+				Atomic:          true,
+				Delete:          true,
+				DigestAlgorithm: feature(specs.FeatureBlobUploadAlgorithm),
+				DigestHeader:    feature(specs.FeatureDigestHeader),
+				MountAnonymous:  feature(specs.FeatureBlobMountAnonymous),
+				UploadCancel:    feature(specs.FeatureBlobUploadCancel),
 			},
 			Manifests: configManifests{
 				Atomic:       true,
+				Conditional:  true,
 				Delete:       true,
-				DigestHeader: false,
-				TagParam:     false,
+				DigestHeader: feature(specs.FeatureDigestHeader),
+				TagParam:     feature(specs.FeatureManifestPutTags),
 			},
 			Tags: configTags{
 				Atomic: true,
 				Delete: true,
 				List:   true,
 			},
-			Referrer: true,
+			Referrer:   feature(specs.FeatureReferrers),
+			ErrorCodes: false,
 		},
 		Data: configData{
-			Image:            true,
-			Index:            true,
-			IndexList:        true,
-			Sparse:           false,
-			Artifact:         true,
-			Subject:          true,
-			SubjectMissing:   true,
-			ArtifactList:     true,
-			SubjectList:      true,
-			DataField:        true,
-			Nondistributable: true,
-			CustomFields:     true,
-			NoLayers:         true,
-			EmptyBlob:        true,
-			Sha512:           true,
+			Image:               true,
+			Index:               true,
+			IndexList:           true,
+			Sparse:              false,
+			Artifact:            true,
+			Subject:             true,
+			SubjectMissing:      true,
+			ArtifactList:        true,
+			SubjectList:         true,
+			DataField:           true,
+			Nondistributable:    true,
+			CustomFields:        true,
+			NoLayers:            true,
+			EmptyBlob:           true,
+			Sha512:              true,
+			ReferrersPagination: 100,
+			TagPagination:       250,
+		},
+		RateLimit: configRateLimit{
+			Retries: 5,
+			Backoff: time.Second,
+			MaxWait: time.Minute,
+		},
+		Proxy: configProxy{
+			Namespace: proxyNamespaceOptional,
 		},
-	}
-	switch configVersion {
-	case "dev", "1.1+dev":
-		c.APIs.Blobs.UploadCancel = true
-		c.APIs.Blobs.DigestHeader = true
-		c.APIs.Manifests.DigestHeader = true
-		c.APIs.Manifests.TagParam = true
-		c.Version = "1.1+dev"
-	case "", "stable", "1.1":
-		c.Version = "1.1"
-	case "1.0":
-		c.APIs.Blobs.MountAnonymous = false
-		c.APIs.Referrer = false
-		c.Version = "1.0"
-	default:
-		return config{}, fmt.Errorf("unsupported config version %s", configVersion)
 	}
 	// process legacy variables but warn user when they are seen
-	err := confLegacyEnv(&c)
+	err = confLegacyEnv(&c)
 	if err != nil {
 		return c, err
 	}
@@ -266,6 +311,23 @@ func configLoad() (config, error) {
 	return c, nil
 }
 
+// confVersion returns the name and parsed spec version of a configured version or alias.
+func confVersion(version string) (string, distspec.SpecVersion, error) {
+	for _, v := range confVersions {
+		match := v.name == version
+		for _, alias := range v.aliases {
+			match = match || alias == version
+		}
+		if match && v.dev {
+			return v.name, distspec.Current(), nil
+		} else if match {
+			sv, err := distspec.ParseVersion(v.name)
+			return v.name, sv, err
+		}
+	}
+	return "", distspec.SpecVersion{}, fmt.Errorf("unsupported config version %s", version)
+}
+
 func (t tls) MarshalText() ([]byte, error) {
 	var s string
 	switch t {
@@ -347,6 +409,20 @@ func confFromEnv(env, tag string, vp reflect.Value) error {
 			return fmt.Errorf("failed to parse bool value from environment %s=%s", env, val)
 		}
 		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("failed to parse duration value from environment %s=%s", env, val)
+			}
+			v.SetInt(int64(d))
+			break
+		}
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse int value from environment %s=%s", env, val)
+		}
+		v.SetInt(i)
 	case reflect.Slice:
 		switch v.Type().Elem().Kind() {
 		case reflect.String:
@@ -672,6 +748,32 @@ var confHTMLTemplates = map[string]string{
         <td class="bullet-left">Configuration</td>
         <td><pre>{{ .Config.Report }}</pre></td>
       </tr>
+      {{- if gt .RateLimit.Throttled 0 }}
+      <tr>
+        <td class="bullet-left">Throttled Requests</td>
+        <td>{{ .RateLimit.Throttled }}
+          {{- if .RateLimit.Invalid }}
+          <ul>
+          {{- range .RateLimit.Invalid }}
+            <li class="darkred">{{ . }}</li>
+          {{- end }}
+          </ul>
+          {{- end }}
+        </td>
+      </tr>
+      {{- end }}
+      {{- if .Warnings }}
+      <tr>
+        <td class="bullet-left">Registry Warnings</td>
+        <td>
+          <ul>
+          {{- range .Warnings }}
+            <li {{- if .Invalid }} class="darkred" {{- end }}>{{ .String }}</li>
+          {{- end }}
+          </ul>
+        </td>
+      </tr>
+      {{- end }}
     </table>`,
 	"results": `
     <div class="result {{ template "status-color" .Status }}">
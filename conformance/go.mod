@@ -8,3 +8,5 @@ require (
 	github.com/opencontainers/go-digest v1.0.0
 	github.com/opencontainers/image-spec v1.1.1
 )
+
+replace github.com/opencontainers/distribution-spec/specs-go => ../specs-go
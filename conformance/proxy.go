@@ -0,0 +1,145 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	proxyNamespaceParam  = "ns"
+	proxyNamespaceHeader = "OCI-Namespace"
+	// proxyNamespaceOptional verifies the OCI-Namespace header when the registry returns it
+	proxyNamespaceOptional = "optional"
+	// proxyNamespaceEcho requires the registry to return the ns parameter in the OCI-Namespace header
+	proxyNamespaceEcho = "echo"
+)
+
+// reProxyPull matches the pull operations that include the ns parameter
+var reProxyPull = regexp.MustCompile(`^/v2/.+/(?:blobs|manifests|tags|referrers)/[^/]+$`)
+
+// apiProxy adds the ns parameter to pull requests and tracks how the proxy registry handles them.
+type apiProxy struct {
+	upstream  string   // source host sent in the ns parameter
+	authHosts []string // additional token servers for the upstream registry
+	echo      bool     // whether the OCI-Namespace header is required
+	requests  int      // pull requests sent with the ns parameter
+	echoed    int      // responses with the OCI-Namespace header
+	invalid   []string // unique reasons the proxy did not follow the spec
+}
+
+// proxySummary is the proxy handling included in the reports.
+type proxySummary struct {
+	Upstream string   `yaml:"upstream"`
+	Requests int      `yaml:"requests"`
+	Echoed   int      `yaml:"echoed"`
+	Invalid  []string `yaml:"invalid,omitempty"`
+}
+
+func apiWithProxy(upstream string, authHosts []string, echo bool) apiOpt {
+	return func(a *api) {
+		a.proxy = &apiProxy{upstream: upstream, authHosts: authHosts, echo: echo}
+	}
+}
+
+// addNamespace sets the ns parameter on pull requests.
+func (p *apiProxy) addNamespace(req *http.Request) {
+	if p == nil || req.URL == nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) || !reProxyPull.MatchString(req.URL.Path) {
+		return
+	}
+	qa := req.URL.Query()
+	qa.Set(proxyNamespaceParam, p.upstream)
+	req.URL.RawQuery = qa.Encode()
+}
+
+// verifyResponse checks the OCI-Namespace header on the response to a request with the ns parameter.
+func (p *apiProxy) verifyResponse(resp *http.Response) {
+	if p == nil || resp.Request == nil || resp.Request.URL.Query().Get(proxyNamespaceParam) == "" {
+		return
+	}
+	p.requests++
+	ns := resp.Header.Get(proxyNamespaceHeader)
+	switch {
+	case ns == "" && p.echo:
+		p.addInvalid(fmt.Sprintf("%s header missing from %s response", proxyNamespaceHeader, resp.Request.Method))
+	case ns == "":
+	case ns != p.upstream:
+		p.addInvalid(fmt.Sprintf("%s header mismatch, expected %q, received %q", proxyNamespaceHeader, p.upstream, ns))
+	default:
+		p.echoed++
+	}
+}
+
+// verifyRealm returns an error when the proxy requests a token from the upstream registry,
+// which would forward the client's upstream credentials to the proxy.
+func (p *apiProxy) verifyRealm(realm *url.URL, service string) error {
+	if p == nil || (!p.upstreamHost(realm.Host) && !p.upstreamHost(service)) {
+		return nil
+	}
+	msg := fmt.Sprintf("proxy requested credentials for the upstream registry %s, realm %s, service %s", p.upstream, realm.String(), service)
+	p.addInvalid(msg)
+	return fmt.Errorf("%s", msg)
+}
+
+// upstreamHost returns true when the host is the upstream registry or a configured auth host.
+// Ports and case are ignored, other hosts in the upstream domain (e.g. auth.docker.io for docker.io) must be listed in the auth hosts.
+func (p *apiProxy) upstreamHost(host string) bool {
+	host = proxyHostname(host)
+	if host == "" {
+		return false
+	}
+	if host == proxyHostname(p.upstream) {
+		return true
+	}
+	for _, ah := range p.authHosts {
+		if host == proxyHostname(ah) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyHostname returns the lower case host without a port.
+func proxyHostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+func (p *apiProxy) addInvalid(msg string) {
+	if !slices.Contains(p.invalid, msg) {
+		p.invalid = append(p.invalid, msg)
+	}
+}
+
+func (p *apiProxy) Summary() proxySummary {
+	if p == nil {
+		return proxySummary{}
+	}
+	return proxySummary{
+		Upstream: p.upstream,
+		Requests: p.requests,
+		Echoed:   p.echoed,
+		Invalid:  slices.Clone(p.invalid),
+	}
+}
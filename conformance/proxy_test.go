@@ -0,0 +1,194 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit_tests
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProxyVerifyRealm(t *testing.T) {
+	tt := []struct {
+		name      string
+		upstream  string
+		authHosts []string
+		realm     string
+		service   string
+		expectErr bool
+	}{
+		{
+			name:     "proxy realm",
+			upstream: "docker.io",
+			realm:    "https://proxy.example.com/token",
+			service:  "proxy.example.com",
+		},
+		{
+			name:      "docker hub realm",
+			upstream:  "docker.io",
+			authHosts: []string{"auth.docker.io", "registry.docker.io"},
+			realm:     "https://auth.docker.io/token",
+			service:   "proxy.example.com",
+			expectErr: true,
+		},
+		{
+			name:      "docker hub service",
+			upstream:  "docker.io",
+			authHosts: []string{"auth.docker.io", "registry.docker.io"},
+			realm:     "https://proxy.example.com/token",
+			service:   "registry.docker.io",
+			expectErr: true,
+		},
+		{
+			name:     "upstream subdomain not listed",
+			upstream: "docker.io",
+			realm:    "https://auth.docker.io/token",
+			service:  "registry.docker.io",
+		},
+		{
+			name:      "upstream realm",
+			upstream:  "registry.example.org",
+			realm:     "https://registry.example.org/token",
+			service:   "proxy.example.com",
+			expectErr: true,
+		},
+		{
+			name:      "upstream with port",
+			upstream:  "upstream.example.org:5000",
+			realm:     "https://upstream.example.org:8443/token",
+			service:   "proxy.example.com",
+			expectErr: true,
+		},
+		{
+			name:      "case insensitive",
+			upstream:  "docker.io",
+			authHosts: []string{"auth.docker.io"},
+			realm:     "https://Auth.Docker.IO/token",
+			service:   "proxy.example.com",
+			expectErr: true,
+		},
+		{
+			name:     "similar domain",
+			upstream: "docker.io",
+			realm:    "https://auth.notdocker.io/token",
+			service:  "proxy.example.com",
+		},
+		{
+			name:      "configured auth host",
+			upstream:  "registry.example.org",
+			authHosts: []string{"token.example.net"},
+			realm:     "https://token.example.net/token",
+			service:   "proxy.example.com",
+			expectErr: true,
+		},
+		{
+			name:      "configured auth host not matched",
+			upstream:  "registry.example.org",
+			authHosts: []string{"token.example.net"},
+			realm:     "https://token.example.com/token",
+			service:   "proxy.example.com",
+		},
+		{
+			name:      "ip upstream",
+			upstream:  "127.0.0.1:5000",
+			realm:     "http://127.0.0.1:5001/token",
+			service:   "proxy",
+			expectErr: true,
+		},
+		{
+			name:     "ip upstream different host",
+			upstream: "127.0.0.1:5000",
+			realm:    "http://10.0.0.1/token",
+			service:  "proxy",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &apiProxy{upstream: tc.upstream, authHosts: tc.authHosts}
+			realm, err := url.Parse(tc.realm)
+			if err != nil {
+				t.Fatalf("failed to parse realm: %v", err)
+			}
+			err = p.verifyRealm(realm, tc.service)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("did not receive expected error")
+				}
+				if len(p.Summary().Invalid) != 1 {
+					t.Errorf("expected an invalid entry, received %v", p.Summary().Invalid)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// TestProxyAuth verifies the proxy fails before a token is requested from the upstream registry.
+func TestProxyAuth(t *testing.T) {
+	tokenRequests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenRequests++
+			_, _ = w.Write([]byte(`{"token":"abc"}`))
+		case "/v2/":
+			if r.Header.Get("Authorization") == "Bearer abc" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			realm := "/token"
+			if r.URL.Query().Get("realm") != "" {
+				realm = r.URL.Query().Get("realm")
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`",service="`+r.URL.Query().Get("service")+`"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+	tt := []struct {
+		name        string
+		query       string
+		expectErr   bool
+		expectToken int
+	}{
+		{name: "proxy token", query: "service=proxy", expectToken: 1},
+		{name: "upstream realm", query: "realm=https://auth.docker.io/token&service=proxy", expectErr: true},
+		{name: "upstream service", query: "service=registry.docker.io", expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenRequests = 0
+			a := apiNew(srv.Client(), apiWithAuth("user", "pass", false), apiWithProxy("docker.io", []string{"auth.docker.io", "registry.docker.io"}, false))
+			u, err := url.Parse(srv.URL + "/v2/?" + tc.query)
+			if err != nil {
+				t.Fatalf("failed to parse url: %v", err)
+			}
+			err = a.Do(apiWithMethod("GET"), apiWithURL(u), apiExpectStatus(http.StatusOK))
+			if tc.expectErr && (err == nil || !strings.Contains(err.Error(), "proxy requested credentials for the upstream registry")) {
+				t.Errorf("expected an upstream credentials error, received %v", err)
+			} else if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tokenRequests != tc.expectToken {
+				t.Errorf("expected %d token requests, received %d", tc.expectToken, tokenRequests)
+			}
+		})
+	}
+}
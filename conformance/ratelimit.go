@@ -0,0 +1,157 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	specs "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+const retryAfterHeader = "Retry-After"
+
+// apiRateLimit retries requests rejected with a 429 status and tracks the responses for the report.
+type apiRateLimit struct {
+	retries   int           // number of times a request is retried
+	backoff   time.Duration // delay before the first retry when the registry does not send a Retry-After header
+	maxWait   time.Duration // limit on the delay between retries
+	throttled int           // count of 429 responses
+	invalid   []string      // unique reasons a 429 response did not follow the spec
+}
+
+// rateLimitSummary is the rate limiting included in the reports.
+type rateLimitSummary struct {
+	Throttled int      `yaml:"throttled"`
+	Invalid   []string `yaml:"invalid,omitempty"`
+}
+
+func apiWithRateLimit(retries int, backoff, maxWait time.Duration) apiOpt {
+	return func(a *api) {
+		a.rateLimit.retries = retries
+		a.rateLimit.backoff = backoff
+		a.rateLimit.maxWait = maxWait
+	}
+}
+
+// retry resends the request until the response is not a 429 or the retries are exhausted.
+func (rl *apiRateLimit) retry(c *http.Client, req *http.Request, resp *http.Response) (*http.Response, error) {
+	for attempt := 0; resp.StatusCode == http.StatusTooManyRequests; attempt++ {
+		rl.throttled++
+		wait, ok := rl.verify(resp)
+		if attempt >= rl.retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		wait = rl.delay(attempt, wait, ok)
+		if resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+		time.Sleep(wait)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to reset body after rate limit: %w", err)
+			}
+			req.Body = body
+		}
+		next, err := c.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		resp = next
+	}
+	return resp, nil
+}
+
+// delay returns the time to wait before a retry, using the backoff when the registry did not request a delay.
+func (rl *apiRateLimit) delay(attempt int, wait time.Duration, ok bool) time.Duration {
+	if !ok {
+		wait = rl.backoff << attempt
+	}
+	if rl.maxWait > 0 && wait > rl.maxWait {
+		wait = rl.maxWait
+	}
+	return wait
+}
+
+// verify checks a 429 response includes the Retry-After header and a TOOMANYREQUESTS error in the body,
+// returning the delay requested by the registry.
+func (rl *apiRateLimit) verify(resp *http.Response) (time.Duration, bool) {
+	wait, ok, err := retryAfterParse(resp.Header.Get(retryAfterHeader), time.Now())
+	if err != nil {
+		rl.addInvalid(err.Error())
+	}
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		// HEAD responses do not include a body
+		return wait, ok
+	}
+	body, err := cloneBodyResp(resp)
+	if err != nil {
+		rl.addInvalid(fmt.Sprintf("failed to read body: %v", err))
+		return wait, ok
+	}
+	er := specs.ErrorResponse{}
+	if err := json.Unmarshal(body, &er); err != nil {
+		rl.addInvalid(fmt.Sprintf("body is not a JSON error response: %v", err))
+		return wait, ok
+	}
+	for _, e := range er.Errors {
+		if e.Code == "TOOMANYREQUESTS" {
+			return wait, ok
+		}
+	}
+	rl.addInvalid("body does not include the TOOMANYREQUESTS error code")
+	return wait, ok
+}
+
+func (rl *apiRateLimit) addInvalid(msg string) {
+	for _, cur := range rl.invalid {
+		if cur == msg {
+			return
+		}
+	}
+	rl.invalid = append(rl.invalid, msg)
+}
+
+func (rl *apiRateLimit) Summary() rateLimitSummary {
+	return rateLimitSummary{
+		Throttled: rl.throttled,
+		Invalid:   append([]string{}, rl.invalid...),
+	}
+}
+
+// retryAfterParse returns the delay from a Retry-After header in either the delay-seconds or HTTP-date form.
+func retryAfterParse(value string, now time.Time) (time.Duration, bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false, fmt.Errorf("missing %s header", retryAfterHeader)
+	}
+	if strings.Trim(value, "0123456789") == "" {
+		sec, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s header %q: %w", retryAfterHeader, value, err)
+		}
+		return time.Duration(sec) * time.Second, true, nil
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s header %q, expected delay-seconds or an HTTP-date", retryAfterHeader, value)
+	}
+	return max(t.Sub(now), 0), true, nil
+}
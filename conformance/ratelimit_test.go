@@ -0,0 +1,179 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit_tests
+
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterParse(t *testing.T) {
+	now := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	tt := []struct {
+		name      string
+		value     string
+		expect    time.Duration
+		expectOK  bool
+		expectErr bool
+	}{
+		{
+			name:      "empty",
+			value:     "",
+			expectErr: true,
+		},
+		{
+			name:     "delay-seconds",
+			value:    "120",
+			expect:   120 * time.Second,
+			expectOK: true,
+		},
+		{
+			name:     "delay-seconds zero",
+			value:    "0",
+			expect:   0,
+			expectOK: true,
+		},
+		{
+			name:     "delay-seconds whitespace",
+			value:    " 5 ",
+			expect:   5 * time.Second,
+			expectOK: true,
+		},
+		{
+			name:      "delay-seconds overflow",
+			value:     "99999999999",
+			expectErr: true,
+		},
+		{
+			name:      "negative",
+			value:     "-5",
+			expectErr: true,
+		},
+		{
+			name:      "fractional",
+			value:     "1.5",
+			expectErr: true,
+		},
+		{
+			name:     "HTTP-date future",
+			value:    now.Add(90 * time.Second).Format(http.TimeFormat),
+			expect:   90 * time.Second,
+			expectOK: true,
+		},
+		{
+			name:     "HTTP-date past",
+			value:    now.Add(-time.Hour).Format(http.TimeFormat),
+			expect:   0,
+			expectOK: true,
+		},
+		{
+			name:     "HTTP-date RFC 850",
+			value:    now.Add(time.Minute).Format(time.RFC850),
+			expect:   time.Minute,
+			expectOK: true,
+		},
+		{
+			name:      "invalid date",
+			value:     "tomorrow",
+			expectErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			wait, ok, err := retryAfterParse(tc.value, now)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("did not receive expected error, wait %s", wait)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tc.expectOK {
+				t.Errorf("expected ok %t, received %t", tc.expectOK, ok)
+			}
+			if wait != tc.expect {
+				t.Errorf("expected wait %s, received %s", tc.expect, wait)
+			}
+		})
+	}
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	tt := []struct {
+		name    string
+		backoff time.Duration
+		maxWait time.Duration
+		attempt int
+		wait    time.Duration
+		ok      bool
+		expect  time.Duration
+	}{
+		{
+			name:    "retry-after",
+			backoff: time.Second,
+			maxWait: time.Minute,
+			wait:    10 * time.Second,
+			ok:      true,
+			expect:  10 * time.Second,
+		},
+		{
+			name:    "retry-after clamped",
+			backoff: time.Second,
+			maxWait: time.Minute,
+			wait:    time.Hour,
+			ok:      true,
+			expect:  time.Minute,
+		},
+		{
+			name:    "retry-after without max",
+			backoff: time.Second,
+			wait:    time.Hour,
+			ok:      true,
+			expect:  time.Hour,
+		},
+		{
+			name:    "backoff first attempt",
+			backoff: time.Second,
+			maxWait: time.Minute,
+			expect:  time.Second,
+		},
+		{
+			name:    "backoff doubled",
+			backoff: time.Second,
+			maxWait: time.Minute,
+			attempt: 3,
+			expect:  8 * time.Second,
+		},
+		{
+			name:    "backoff clamped",
+			backoff: time.Second,
+			maxWait: time.Minute,
+			attempt: 10,
+			expect:  time.Minute,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rl := apiRateLimit{backoff: tc.backoff, maxWait: tc.maxWait}
+			received := rl.delay(tc.attempt, tc.wait, tc.ok)
+			if received != tc.expect {
+				t.Errorf("expected %s, received %s", tc.expect, received)
+			}
+		})
+	}
+}
@@ -0,0 +1,726 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit_tests
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	specs "github.com/opencontainers/distribution-spec/specs-go/v1"
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const memRegManifestMax = 4 * 1024 * 1024
+
+var (
+	memRegPath = regexp.MustCompile(`^/v2/(.+?)/(blobs/uploads|blobs|manifests|tags|referrers)/([^/]*)$`)
+	memRegName = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*$`)
+	memRegTag  = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+)
+
+// memReg is an in-memory registry implementing every API used by the conformance tests.
+// It is used to verify changes to the runner without an external registry.
+type memReg struct {
+	mu             sync.Mutex
+	repos          map[string]*memRegRepo
+	uploads        map[string]*memRegUpload
+	uploadCount    int
+	noReferrers    bool
+	noDelete       bool
+	deleteDenied   bool
+	noMount        bool
+	lowerCodes     bool
+	warnings       []string
+	warningPing    []string
+	rateEvery      int  // return a 429 for every n requests
+	rateInvalid    bool // omit the Retry-After header and error body from 429 responses
+	requests       int
+	noStatus       bool
+	namespace      bool
+	noETag         bool
+	noPrecond      bool // return ETags but ignore If-Match and If-None-Match on manifest pushes
+	noHeadETag     bool // omit the ETag header from manifest HEAD responses
+	noTagLink      bool // omit the Link header from paginated tag listings
+	noTagLimit     bool // ignore the n parameter on tag listings
+	tagLinkFirst   bool // list a link to the first page, with a comma in its title, before the next page link on tag listings
+	refPageSize    int  // split the referrers response into pages of this size
+	refPageDup     bool // repeat the last entry of each referrers page on the next page
+	refFilterFirst bool // only set the OCI-Filters-Applied header on the first referrers page
+}
+
+type memRegRepo struct {
+	blobs     map[digest.Digest][]byte
+	manifests map[digest.Digest]memRegManifest
+	tags      map[string]digest.Digest
+}
+
+type memRegManifest struct {
+	mediaType string
+	raw       []byte
+}
+
+type memRegUpload struct {
+	repo string
+	buf  bytes.Buffer
+}
+
+type memRegOpt func(*memReg)
+
+func memRegNew(opts ...memRegOpt) *memReg {
+	m := &memReg{
+		repos:   map[string]*memRegRepo{},
+		uploads: map[string]*memRegUpload{},
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// memRegWithoutReferrers disables the referrers API and the OCI-Subject header.
+func memRegWithoutReferrers() memRegOpt {
+	return func(m *memReg) {
+		m.noReferrers = true
+	}
+}
+
+// memRegWithoutDelete rejects blob and manifest deletes with a 405.
+func memRegWithoutDelete() memRegOpt {
+	return func(m *memReg) {
+		m.noDelete = true
+	}
+}
+
+// memRegWithDeleteDenied rejects delete requests with a 403 and the DENIED code.
+func memRegWithDeleteDenied() memRegOpt {
+	return func(m *memReg) {
+		m.deleteDenied = true
+	}
+}
+
+// memRegWithoutMount ignores cross repository mount requests, falling back to an upload session.
+func memRegWithoutMount() memRegOpt {
+	return func(m *memReg) {
+		m.noMount = true
+	}
+}
+
+// memRegWithLowerCaseErrors returns error codes in lower case, which the spec does not allow.
+func memRegWithLowerCaseErrors() memRegOpt {
+	return func(m *memReg) {
+		m.lowerCodes = true
+	}
+}
+
+// memRegWithWarnings adds Warning headers to every response.
+func memRegWithWarnings(values ...string) memRegOpt {
+	return func(m *memReg) {
+		m.warnings = append(m.warnings, values...)
+	}
+}
+
+// memRegWithPingWarnings adds Warning headers to the response from the /v2/ endpoint.
+func memRegWithPingWarnings(values ...string) memRegOpt {
+	return func(m *memReg) {
+		m.warningPing = append(m.warningPing, values...)
+	}
+}
+
+// memRegWithoutUploadStatus rejects GET requests for the status of an upload session.
+func memRegWithoutUploadStatus() memRegOpt {
+	return func(m *memReg) {
+		m.noStatus = true
+	}
+}
+
+// memRegWithoutETag does not return ETag headers or support conditional manifest requests.
+func memRegWithoutETag() memRegOpt {
+	return func(m *memReg) {
+		m.noETag = true
+	}
+}
+
+// memRegWithoutPrecondition returns ETags but ignores the conditional headers on manifest pushes.
+func memRegWithoutPrecondition() memRegOpt {
+	return func(m *memReg) {
+		m.noPrecond = true
+	}
+}
+
+// memRegWithoutHeadETag omits the ETag header from manifest HEAD responses.
+func memRegWithoutHeadETag() memRegOpt {
+	return func(m *memReg) {
+		m.noHeadETag = true
+	}
+}
+
+// memRegWithoutTagLink omits the Link header from paginated tag listings, requiring clients to use the last parameter.
+func memRegWithoutTagLink() memRegOpt {
+	return func(m *memReg) {
+		m.noTagLink = true
+	}
+}
+
+// memRegWithoutTagLimit ignores the n parameter on tag listings, returning every tag.
+func memRegWithoutTagLimit() memRegOpt {
+	return func(m *memReg) {
+		m.noTagLimit = true
+	}
+}
+
+// memRegWithTagLinkFirst adds a link to the first page before the next page link on tag listings.
+func memRegWithTagLinkFirst() memRegOpt {
+	return func(m *memReg) {
+		m.tagLinkFirst = true
+	}
+}
+
+// memRegWithReferrersFilterFirst only sets the OCI-Filters-Applied header on the first page of a filtered referrers response.
+func memRegWithReferrersFilterFirst() memRegOpt {
+	return func(m *memReg) {
+		m.refFilterFirst = true
+	}
+}
+
+// memRegWithReferrersPages splits the referrers response into pages, linked with the Link header.
+// When dup is set, each page repeats the last entry of the previous page.
+func memRegWithReferrersPages(size int, dup bool) memRegOpt {
+	return func(m *memReg) {
+		m.refPageSize = size
+		m.refPageDup = dup
+	}
+}
+
+// memRegWithNamespace returns the ns query parameter in the OCI-Namespace header.
+func memRegWithNamespace() memRegOpt {
+	return func(m *memReg) {
+		m.namespace = true
+	}
+}
+
+// memRegWithRateLimit rejects every n requests with a 429 status.
+// When invalid is set, the response does not include the Retry-After header or the TOOMANYREQUESTS error.
+func memRegWithRateLimit(every int, invalid bool) memRegOpt {
+	return func(m *memReg) {
+		m.rateEvery = every
+		m.rateInvalid = invalid
+	}
+}
+
+func (m *memReg) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+	if m.rateEvery > 0 && m.requests%m.rateEvery == 0 {
+		if m.rateInvalid {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Retry-After", "0")
+		m.writeError(w, http.StatusTooManyRequests, "TOOMANYREQUESTS", "rate limit exceeded")
+		return
+	}
+	for _, value := range m.warnings {
+		w.Header().Add("Warning", value)
+	}
+	if ns := r.URL.Query().Get("ns"); ns != "" && m.namespace {
+		w.Header().Set("OCI-Namespace", ns)
+	}
+	if r.URL.Path == "/v2/" || r.URL.Path == "/v2" {
+		for _, value := range m.warningPing {
+			w.Header().Add("Warning", value)
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+		return
+	}
+	match := memRegPath.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		m.writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "unknown path")
+		return
+	}
+	repo, kind, ref := match[1], match[2], match[3]
+	if !memRegName.MatchString(repo) {
+		m.writeError(w, http.StatusBadRequest, "NAME_INVALID", "invalid repository name")
+		return
+	}
+	switch {
+	case kind == "blobs/uploads" && ref == "" && r.Method == http.MethodPost:
+		m.uploadStart(w, r, repo)
+	case kind == "blobs/uploads" && ref != "":
+		m.upload(w, r, repo, ref)
+	case kind == "blobs":
+		m.blob(w, r, repo, ref)
+	case kind == "manifests":
+		m.manifest(w, r, repo, ref)
+	case kind == "tags" && ref == "list" && r.Method == http.MethodGet:
+		m.tagList(w, r, repo)
+	case kind == "referrers" && r.Method == http.MethodGet:
+		m.referrers(w, r, repo, ref)
+	default:
+		m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+	}
+}
+
+func (m *memReg) repo(name string) *memRegRepo {
+	if _, ok := m.repos[name]; !ok {
+		m.repos[name] = &memRegRepo{
+			blobs:     map[digest.Digest][]byte{},
+			manifests: map[digest.Digest]memRegManifest{},
+			tags:      map[string]digest.Digest{},
+		}
+	}
+	return m.repos[name]
+}
+
+func (m *memReg) blob(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	dig, err := digest.Parse(ref)
+	if err != nil {
+		m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+		return
+	}
+	if r.Method == http.MethodDelete && m.noDelete {
+		m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "blob delete is disabled")
+		return
+	}
+	if r.Method == http.MethodDelete && m.deleteDenied {
+		m.writeError(w, http.StatusForbidden, "DENIED", "blob delete is denied")
+		return
+	}
+	b, ok := m.repo(repo).blobs[dig]
+	if !ok {
+		m.writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", "blob unknown to registry")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Content-Type", mtOctetStream)
+		w.Header().Set("Docker-Content-Digest", dig.String())
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+	case http.MethodDelete:
+		delete(m.repo(repo).blobs, dig)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+	}
+}
+
+func (m *memReg) uploadStart(w http.ResponseWriter, r *http.Request, repo string) {
+	q := r.URL.Query()
+	if mount := q.Get("mount"); mount != "" && !m.noMount {
+		dig, err := digest.Parse(mount)
+		if err != nil {
+			m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+			return
+		}
+		// a missing from parameter is an anonymous mount, searching every repository
+		var b []byte
+		found := false
+		for name, source := range m.repos {
+			if from := q.Get("from"); from != "" && from != name {
+				continue
+			}
+			if b, found = source.blobs[dig]; found {
+				break
+			}
+		}
+		if found {
+			m.repo(repo).blobs[dig] = b
+			memRegBlobCreated(w, repo, dig)
+			return
+		}
+		// fall back to an upload session
+	}
+	if dq := q.Get("digest"); dq != "" {
+		dig, err := digest.Parse(dq)
+		if err != nil {
+			m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			m.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		if dig.Algorithm().FromBytes(b) != dig {
+			m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "digest does not match content")
+			return
+		}
+		m.repo(repo).blobs[dig] = b
+		memRegBlobCreated(w, repo, dig)
+		return
+	}
+	if algo := q.Get("digest-algorithm"); algo != "" && !digest.Algorithm(algo).Available() {
+		m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "unsupported digest algorithm")
+		return
+	}
+	m.uploadCount++
+	id := strconv.Itoa(m.uploadCount)
+	m.uploads[id] = &memRegUpload{repo: repo}
+	memRegUploadStatus(w, repo, id, m.uploads[id], http.StatusAccepted)
+}
+
+func (m *memReg) upload(w http.ResponseWriter, r *http.Request, repo, id string) {
+	u, ok := m.uploads[id]
+	if !ok || u.repo != repo {
+		m.writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", "upload session unknown")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if m.noStatus {
+			m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "upload status is not supported")
+			return
+		}
+		memRegUploadStatus(w, repo, id, u, http.StatusNoContent)
+	case http.MethodDelete:
+		delete(m.uploads, id)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPatch, http.MethodPut:
+		var dig digest.Digest
+		if r.Method == http.MethodPut {
+			var err error
+			dig, err = digest.Parse(r.URL.Query().Get("digest"))
+			if err != nil {
+				m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+				return
+			}
+		}
+		if cr := r.Header.Get("Content-Range"); cr != "" {
+			startStr, endStr, _ := strings.Cut(cr, "-")
+			start, errStart := strconv.ParseInt(startStr, 10, 64)
+			end, errEnd := strconv.ParseInt(endStr, 10, 64)
+			if errStart != nil || errEnd != nil || end < start {
+				m.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", "invalid Content-Range")
+				return
+			}
+			if start != int64(u.buf.Len()) {
+				memRegUploadStatus(w, repo, id, u, http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			if r.ContentLength >= 0 && r.ContentLength != end-start+1 {
+				m.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", "Content-Range does not match Content-Length")
+				return
+			}
+		}
+		if _, err := u.buf.ReadFrom(r.Body); err != nil {
+			m.writeError(w, http.StatusBadRequest, "BLOB_UPLOAD_INVALID", err.Error())
+			return
+		}
+		if r.Method == http.MethodPatch {
+			memRegUploadStatus(w, repo, id, u, http.StatusAccepted)
+			return
+		}
+		if dig.Algorithm().FromBytes(u.buf.Bytes()) != dig {
+			m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "digest does not match content")
+			return
+		}
+		m.repo(repo).blobs[dig] = bytes.Clone(u.buf.Bytes())
+		delete(m.uploads, id)
+		memRegBlobCreated(w, repo, dig)
+	default:
+		m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+	}
+}
+
+func (m *memReg) manifest(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	var dig digest.Digest
+	if strings.Contains(ref, ":") {
+		var err error
+		dig, err = digest.Parse(ref)
+		if err != nil {
+			m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+			return
+		}
+	} else if !memRegTag.MatchString(ref) {
+		m.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", "invalid tag")
+		return
+	}
+	rp := m.repo(repo)
+	if r.Method == http.MethodPut {
+		if !m.noETag && !m.noPrecond && !memRegPrecondition(r, rp, ref, dig) {
+			m.writeError(w, http.StatusPreconditionFailed, "UNSUPPORTED", "precondition failed")
+			return
+		}
+		m.manifestPut(w, r, repo, ref, dig)
+		return
+	}
+	if r.Method == http.MethodDelete && m.noDelete {
+		m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "manifest delete is disabled")
+		return
+	}
+	if r.Method == http.MethodDelete && m.deleteDenied {
+		m.writeError(w, http.StatusForbidden, "DENIED", "manifest delete is denied")
+		return
+	}
+	if dig == "" {
+		dig = rp.tags[ref]
+	}
+	man, ok := rp.manifests[dig]
+	if !ok {
+		m.writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", "manifest unknown to registry")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		if !m.noETag && (r.Method == http.MethodGet || !m.noHeadETag) {
+			etag := memRegETag(dig)
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", man.mediaType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(man.raw)))
+		w.Header().Set("Docker-Content-Digest", dig.String())
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(man.raw)
+		}
+	case http.MethodDelete:
+		if ref != dig.String() {
+			delete(rp.tags, ref)
+		} else {
+			delete(rp.manifests, dig)
+			for tag, tagDig := range rp.tags {
+				if tagDig == dig {
+					delete(rp.tags, tag)
+				}
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		m.writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed")
+	}
+}
+
+func memRegETag(dig digest.Digest) string {
+	return `"` + dig.String() + `"`
+}
+
+// memRegPrecondition reports if the If-Match and If-None-Match headers allow the manifest to be replaced.
+func memRegPrecondition(r *http.Request, rp *memRegRepo, ref string, dig digest.Digest) bool {
+	if dig == "" {
+		dig = rp.tags[ref]
+	}
+	cur := ""
+	if _, ok := rp.manifests[dig]; ok {
+		cur = memRegETag(dig)
+	}
+	if im := r.Header.Get("If-Match"); im != "" && (cur == "" || (im != "*" && im != cur)) {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" && cur != "" && (inm == "*" || inm == cur) {
+		return false
+	}
+	return true
+}
+
+func (m *memReg) manifestPut(w http.ResponseWriter, r *http.Request, repo, ref string, refDig digest.Digest) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, memRegManifestMax+1))
+	if err != nil {
+		m.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+		return
+	}
+	if len(raw) > memRegManifestMax {
+		m.writeError(w, http.StatusRequestEntityTooLarge, "SIZE_INVALID", "manifest exceeds the size limit")
+		return
+	}
+	dig := digest.Canonical.FromBytes(raw)
+	if refDig != "" {
+		if dig = refDig.Algorithm().FromBytes(raw); dig != refDig {
+			m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "digest does not match content")
+			return
+		}
+	}
+	tags := r.URL.Query()["tag"]
+	if len(tags) > 0 && refDig == "" {
+		m.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", "tag parameters require a digest reference")
+		return
+	}
+	for _, tag := range tags {
+		if !memRegTag.MatchString(tag) {
+			m.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", "invalid tag")
+			return
+		}
+	}
+	fields := struct {
+		Subject *image.Descriptor `json:"subject,omitempty"`
+	}{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		m.writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+		return
+	}
+	rp := m.repo(repo)
+	rp.manifests[dig] = memRegManifest{mediaType: r.Header.Get("Content-Type"), raw: raw}
+	if refDig == "" {
+		rp.tags[ref] = dig
+	}
+	for _, tag := range tags {
+		rp.tags[tag] = dig
+	}
+	if len(tags) > 0 {
+		w.Header().Set("OCI-Tag", strings.Join(tags, ", "))
+	}
+	if fields.Subject != nil && !m.noReferrers {
+		w.Header().Set("OCI-Subject", fields.Subject.Digest.String())
+	}
+	w.Header().Set("Location", "/v2/"+repo+"/manifests/"+dig.String())
+	w.Header().Set("Docker-Content-Digest", dig.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (m *memReg) tagList(w http.ResponseWriter, r *http.Request, repo string) {
+	q := r.URL.Query()
+	tags := []string{}
+	for tag := range m.repo(repo).tags {
+		if last := q.Get("last"); last == "" || tag > last {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	if nStr := q.Get("n"); nStr != "" && !m.noTagLimit {
+		n, err := strconv.Atoi(nStr)
+		if err != nil || n < 0 {
+			m.writeError(w, http.StatusBadRequest, "UNSUPPORTED", "invalid n parameter")
+			return
+		}
+		if n < len(tags) {
+			tags = tags[:n]
+			if n > 0 && !m.noTagLink {
+				next := url.Values{"n": {nStr}, "last": {tags[n-1]}}
+				link := fmt.Sprintf("</v2/%s/tags/list?%s>; rel=\"next\"", repo, next.Encode())
+				if m.tagLinkFirst {
+					link = fmt.Sprintf("</v2/%s/tags/list?n=%s>; rel=\"first\"; title=\"first, page\", %s", repo, nStr, link)
+				}
+				w.Header().Set("Link", link)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(specs.TagList{Name: repo, Tags: tags})
+}
+
+func (m *memReg) referrers(w http.ResponseWriter, r *http.Request, repo, ref string) {
+	if m.noReferrers {
+		m.writeError(w, http.StatusNotFound, "UNSUPPORTED", "referrers are disabled")
+		return
+	}
+	subject, err := digest.Parse(ref)
+	if err != nil {
+		m.writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+		return
+	}
+	at := r.URL.Query().Get("artifactType")
+	index := image.Index{
+		MediaType: mtOCIIndex,
+		Manifests: []image.Descriptor{},
+	}
+	index.SchemaVersion = 2
+	for dig, man := range m.repo(repo).manifests {
+		fields := struct {
+			ArtifactType string            `json:"artifactType,omitempty"`
+			Config       *image.Descriptor `json:"config,omitempty"`
+			Subject      *image.Descriptor `json:"subject,omitempty"`
+			Annotations  map[string]string `json:"annotations,omitempty"`
+		}{}
+		if err := json.Unmarshal(man.raw, &fields); err != nil || fields.Subject == nil || fields.Subject.Digest != subject {
+			continue
+		}
+		desc := image.Descriptor{
+			MediaType:    man.mediaType,
+			Digest:       dig,
+			Size:         int64(len(man.raw)),
+			ArtifactType: fields.ArtifactType,
+			Annotations:  fields.Annotations,
+		}
+		if desc.ArtifactType == "" && fields.Config != nil {
+			desc.ArtifactType = fields.Config.MediaType
+		}
+		if at != "" && desc.ArtifactType != at {
+			continue
+		}
+		index.Manifests = append(index.Manifests, desc)
+	}
+	slices.SortFunc(index.Manifests, func(a, b image.Descriptor) int {
+		return strings.Compare(a.Digest.String(), b.Digest.String())
+	})
+	if m.refPageSize > 0 {
+		if last := r.URL.Query().Get("last"); last != "" {
+			index.Manifests = slices.DeleteFunc(index.Manifests, func(d image.Descriptor) bool {
+				return d.Digest.String() < last || (d.Digest.String() == last && !m.refPageDup)
+			})
+		}
+		if len(index.Manifests) > m.refPageSize {
+			index.Manifests = index.Manifests[:m.refPageSize]
+			next := url.Values{"last": {index.Manifests[m.refPageSize-1].Digest.String()}}
+			if at != "" {
+				next.Set("artifactType", at)
+			}
+			w.Header().Set("Link", fmt.Sprintf("</v2/%s/referrers/%s?%s>; rel=\"next\"", repo, subject.String(), next.Encode()))
+		}
+	}
+	if at != "" && (!m.refFilterFirst || r.URL.Query().Get("last") == "") {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	w.Header().Set("Content-Type", mtOCIIndex)
+	_ = json.NewEncoder(w).Encode(index)
+}
+
+func memRegBlobCreated(w http.ResponseWriter, repo string, dig digest.Digest) {
+	w.Header().Set("Location", "/v2/"+repo+"/blobs/"+dig.String())
+	w.Header().Set("Docker-Content-Digest", dig.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+func memRegUploadStatus(w http.ResponseWriter, repo, id string, u *memRegUpload, status int) {
+	end := u.buf.Len() - 1
+	if end < 0 {
+		end = 0
+	}
+	w.Header().Set("Location", "/v2/"+repo+"/blobs/uploads/"+id)
+	w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.WriteHeader(status)
+}
+
+func (m *memReg) writeError(w http.ResponseWriter, status int, code, message string) {
+	if m.lowerCodes {
+		code = strings.ToLower(code)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(specs.ErrorResponse{
+		Errors: []specs.ErrorInfo{{Code: code, Message: message}},
+	})
+}
@@ -24,6 +24,7 @@ import (
 	"log/slog"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"slices"
 	"sort"
@@ -32,6 +33,7 @@ import (
 	"time"
 
 	"github.com/goccy/go-yaml"
+	specs "github.com/opencontainers/distribution-spec/specs-go/v1"
 	digest "github.com/opencontainers/go-digest"
 	image "github.com/opencontainers/image-spec/specs-go/v1"
 )
@@ -61,7 +63,15 @@ func runnerNew(c config) (*runner, error) {
 	if c.LogWriter == nil {
 		c.LogWriter = os.Stderr
 	}
-	apiOpts := []apiOpt{}
+	apiOpts := []apiOpt{
+		apiWithRateLimit(c.RateLimit.Retries, c.RateLimit.Backoff, c.RateLimit.MaxWait),
+	}
+	if c.Proxy.Upstream != "" {
+		if c.Proxy.Namespace != proxyNamespaceOptional && c.Proxy.Namespace != proxyNamespaceEcho {
+			return nil, fmt.Errorf("unsupported proxy namespace behavior %q, expected %q or %q", c.Proxy.Namespace, proxyNamespaceOptional, proxyNamespaceEcho)
+		}
+		apiOpts = append(apiOpts, apiWithProxy(c.Proxy.Upstream, c.Proxy.AuthHosts, c.Proxy.Namespace == proxyNamespaceEcho))
+	}
 	if c.LoginUser != "" && c.LoginPass != "" {
 		apiOpts = append(apiOpts, apiWithAuth(c.LoginUser, c.LoginPass, c.CacheAuth))
 	}
@@ -72,6 +82,10 @@ func runnerNew(c config) (*runner, error) {
 		Results: resultsNew(testName, nil),
 		Log:     slog.New(slog.NewTextHandler(c.LogWriter, &slog.HandlerOptions{Level: lvl})),
 	}
+	// reset the package level tracking so each runner starts with a clean state
+	dataTests = []string{}
+	blobAPIsTested = [stateAPIMax]bool{}
+	blobAPIsTestedByAlgo = map[digest.Algorithm]*[stateAPIMax]bool{}
 	for api := range stateAPIMax {
 		if err := r.APIRequire(api); errors.Is(err, errAPITestDisabled) {
 			r.State.APIStatus[api] = statusDisabled
@@ -347,6 +361,38 @@ func (r *runner) GenerateData() error {
 	} else {
 		r.State.DataStatus[tdName] = statusDisabled
 	}
+	// many referrers to a single subject, split between two artifact types, to test pagination
+	tdName = "referrers-pagination"
+	r.State.Data[tdName] = newTestData("Referrers Pagination")
+	if r.Config.Data.Subject && r.Config.Data.ReferrersPagination > 0 {
+		r.State.DataStatus[tdName] = statusUnknown
+		dataTests = append(dataTests, tdName)
+		subjDig, err := r.State.Data[tdName].genManifestFull(
+			genWithTag("referrers-pagination"),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to generate test data: %w", err)
+		}
+		subjDesc := *r.State.Data[tdName].desc[subjDig]
+		artifactTypes := []string{mtExampleConf1, mtExampleConf2}
+		for i := range r.Config.Data.ReferrersPagination {
+			_, err = r.State.Data[tdName].genManifestFull(
+				genWithArtifactType(artifactTypes[i%len(artifactTypes)]),
+				genWithAnnotationUniq(),
+				genWithConfigMediaType(mtOCIEmptyJSON),
+				genWithConfigBytes([]byte("{}")),
+				genWithLayerCount(1),
+				genWithLayerBytes([]byte("{}")),
+				genWithLayerMediaType(mtOCIEmptyJSON),
+				genWithSubject(subjDesc),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to generate test data: %w", err)
+			}
+		}
+	} else {
+		r.State.DataStatus[tdName] = statusDisabled
+	}
 	// index and artifact-index with a subject
 	tdName = "index-with-subject"
 	r.State.Data[tdName] = newTestData("Index with Subject")
@@ -701,6 +747,24 @@ func (r *runner) Report(w io.Writer) {
 		_, _ = fmt.Fprintf(w, "  %s%s: %10s\n", r.State.Data[tdName].name, pad, r.State.DataStatus[tdName].String())
 	}
 	_, _ = fmt.Fprintf(w, "\n")
+
+	if rl := r.API.rateLimit.Summary(); rl.Throttled > 0 {
+		_, _ = fmt.Fprintf(w, "Rate limiting:\n")
+		pad := strings.Repeat(".", padWidth-len("Throttled requests"))
+		_, _ = fmt.Fprintf(w, "  %s%s: %10d\n", "Throttled requests", pad, rl.Throttled)
+		for _, msg := range rl.Invalid {
+			_, _ = fmt.Fprintf(w, "  INVALID: %s\n", msg)
+		}
+		_, _ = fmt.Fprintf(w, "\n")
+	}
+
+	if warnings := r.API.warnings.Entries(); len(warnings) > 0 {
+		_, _ = fmt.Fprintf(w, "Registry warnings:\n")
+		for _, we := range warnings {
+			_, _ = fmt.Fprintf(w, "  %s\n", we.String())
+		}
+		_, _ = fmt.Fprintf(w, "\n")
+	}
 }
 
 func (r *runner) ReportJunit(w io.Writer) error {
@@ -756,6 +820,8 @@ type reportData struct {
 	AllFailed       bool
 	AllSkipped      bool
 	Version         string
+	Warnings        []warningEntry
+	RateLimit       rateLimitSummary
 }
 
 func (r *runner) ReportHTML(w io.Writer) error {
@@ -777,6 +843,8 @@ func (r *runner) ReportHTML(w io.Writer) error {
 	data.AllFailed = data.NumFailed == data.NumTotal
 	data.AllSkipped = data.NumSkipped == data.NumTotal
 	data.Version = r.Config.Version
+	data.Warnings = r.API.warnings.Entries()
+	data.RateLimit = r.API.rateLimit.Summary()
 	// load all templates
 	t := template.New("report")
 	for name, value := range confHTMLTemplates {
@@ -795,13 +863,22 @@ func (r *runner) ReportHTML(w io.Writer) error {
 
 func (r *runner) ReportResultsYAML(w io.Writer) error {
 	results := struct {
-		Config config                  `yaml:"config"`
-		APIs   map[stateAPIType]status `yaml:"apis"`
-		Data   map[string]status       `yaml:"data"`
+		Config    config                  `yaml:"config"`
+		APIs      map[stateAPIType]status `yaml:"apis"`
+		Data      map[string]status       `yaml:"data"`
+		Warnings  []warningEntry          `yaml:"warnings,omitempty"`
+		RateLimit rateLimitSummary        `yaml:"rateLimit"`
+		Proxy     *proxySummary           `yaml:"proxy,omitempty"`
 	}{
-		Config: r.Config.Redact(),
-		APIs:   r.State.APIStatus,
-		Data:   map[string]status{},
+		Config:    r.Config.Redact(),
+		APIs:      r.State.APIStatus,
+		Data:      map[string]status{},
+		Warnings:  r.API.warnings.Entries(),
+		RateLimit: r.API.rateLimit.Summary(),
+	}
+	if r.API.proxy != nil {
+		p := r.API.proxy.Summary()
+		results.Proxy = &p
 	}
 	for k, v := range r.State.DataStatus {
 		results.Data[r.State.Data[k].name] = v
@@ -886,12 +963,36 @@ func (r *runner) TestAll() error {
 		}
 	}
 
+	// paginated tag listing in a dedicated repository
+	err = r.TestTagPagination(r.Results, r.Config.RepoTags)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
 	// various manifest error conditions
 	err = r.TestManifestErrors(r.Results, repo)
 	if err != nil {
 		errs = append(errs, err)
 	}
 
+	// optional ETag support for conditional requests
+	err = r.TestManifestConditional(r.Results, repo)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// 429 responses seen in any of the above tests
+	err = r.TestRateLimit(r.Results)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	// handling of the ns parameter in the above pulls
+	err = r.TestProxy(r.Results)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
 	r.Results.Stop = time.Now()
 
 	if len(errs) > 0 {
@@ -913,7 +1014,7 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 		if _, ok := blobAPIsTestedByAlgo[algo]; !ok {
 			blobAPIsTestedByAlgo[algo] = &[stateAPIMax]bool{}
 		}
-		blobAPITests := []string{"post only", "post+put", "chunked single", "stream", "mount", "mount anonymous", "mount missing", "post cancel"}
+		blobAPITests := []string{"post only", "post+put", "chunked single", "stream", "mount", "mount anonymous", "mount missing", "post cancel", "post digest-algorithm", "post digest-algorithm unsupported"}
 		for _, name := range blobAPITests {
 			dig, _, err := r.State.Data[tdName].genBlob(genWithBlobSize(512), genWithAlgo(algo))
 			if err != nil {
@@ -922,12 +1023,13 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 			digests[name] = dig
 		}
 		// try pulling a blob that has not been pushed
+		eb := apiErrorBody{}
 		err := r.ChildRun("get-missing", res, func(r *runner, res *results) error {
 			if err := r.APIRequire(stateAPIBlobGetFull); err != nil {
 				r.TestSkip(res, err, tdName, stateAPIBlobGetFull)
 				return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 			}
-			if err := r.API.BlobGetReq(r.Config.schemeReg, repo, digests["post cancel"], r.State.Data[tdName], apiExpectStatus(http.StatusNotFound), apiSaveOutput(res.Output)); err != nil {
+			if err := r.API.BlobGetReq(r.Config.schemeReg, repo, digests["post cancel"], r.State.Data[tdName], apiExpectStatus(http.StatusNotFound), apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 				r.TestFail(res, err, tdName, stateAPIBlobGetFull)
 				return fmt.Errorf("%.0w%w", errAPITestFail, err)
 			}
@@ -937,7 +1039,10 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 		if err != nil {
 			errs = append(errs, err)
 		}
-		blobAPITests = append(blobAPITests, "chunked multi", "chunked multi and put chunk", "chunked out-of-order", "chunked out-of-order and put chunk")
+		if err := r.TestErrorBody(res, "get-missing error body", &eb, "BLOB_UNKNOWN", "NAME_UNKNOWN"); err != nil {
+			errs = append(errs, err)
+		}
+		blobAPITests = append(blobAPITests, "chunked multi", "chunked multi and put chunk", "chunked out-of-order", "chunked out-of-order and put chunk", "chunked resume")
 		minChunkSize := int64(chunkMin)
 		minHeader := ""
 		// test the various blob push APIs
@@ -1021,6 +1126,18 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 					if err != nil {
 						errs = append(errs, err)
 					}
+				case "chunked resume":
+					api = stateAPIBlobPatchResume
+					// generate a blob large enough to span two chunks
+					dig, _, err = r.State.Data[tdName].genBlob(genWithBlobSize(minChunkSize*2+5), genWithAlgo(algo))
+					if err != nil {
+						return fmt.Errorf("failed to generate resumable blob of size %d: %w", minChunkSize*2+5, err)
+					}
+					digests[testName] = dig
+					err = r.TestPushBlobPatchResume(res, tdName, repo, dig)
+					if err != nil {
+						errs = append(errs, err)
+					}
 				case "stream":
 					api = stateAPIBlobPatchStream
 					err = r.TestPushBlobPatchStream(res, tdName, repo, dig)
@@ -1033,6 +1150,18 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 					if err != nil {
 						errs = append(errs, err)
 					}
+				case "post digest-algorithm":
+					api = stateAPIBlobPostAlgorithm
+					err = r.TestPushBlobPostAlgorithm(res, tdName, repo, dig)
+					if err != nil {
+						errs = append(errs, err)
+					}
+				case "post digest-algorithm unsupported":
+					api = stateAPIBlobPostAlgorithm
+					err = r.TestPushBlobPostAlgorithmUnsupported(res, tdName, repo)
+					if err != nil {
+						errs = append(errs, err)
+					}
 				case "mount":
 					api = stateAPIBlobMountSource
 					// first push to repo2
@@ -1069,7 +1198,7 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 				// track the used APIs so TestPushBlobAny doesn't rerun tests
 				blobAPIsTested[api] = true
 				blobAPIsTestedByAlgo[dig.Algorithm()][api] = true
-				if err == nil && testName != "post cancel" {
+				if err == nil && testName != "post cancel" && testName != "post digest-algorithm unsupported" {
 					// head request
 					err = r.TestHeadBlob(res, tdName, repo, dig)
 					if err != nil {
@@ -1290,20 +1419,24 @@ func (r *runner) TestBlobAPIs(parent *results, tdName, tdDesc string, algo diges
 		for _, testName := range blobAPIBadDigTests {
 			err := r.ChildRun(testName, res, func(r *runner, res *results) error {
 				dig := digests[testName]
+				eb := apiErrorBody{}
+				optErrBody := apiReturnErrorBody(&eb)
+				var err error
 				switch testName {
 				case "bad digest post only":
-					return r.TestPushBlobPostOnly(res, tdName, repo, dig, optBadDig)
+					err = r.TestPushBlobPostOnly(res, tdName, repo, dig, optBadDig, optErrBody)
 				case "bad digest post+put":
-					return r.TestPushBlobPostPut(res, tdName, repo, dig, optBadDig)
+					err = r.TestPushBlobPostPut(res, tdName, repo, dig, optBadDig, optErrBody)
 				case "bad digest chunked":
-					return r.TestPushBlobPatchChunked(res, tdName, repo, dig, optBadDig)
+					err = r.TestPushBlobPatchChunked(res, tdName, repo, dig, optBadDig, optErrBody)
 				case "bad digest chunked and put chunk":
-					return r.TestPushBlobPatchChunked(res, tdName, repo, dig, optBadDig)
+					err = r.TestPushBlobPatchChunked(res, tdName, repo, dig, optBadDig, optErrBody)
 				case "bad digest stream":
-					return r.TestPushBlobPatchStream(res, tdName, repo, dig, optBadDig)
+					err = r.TestPushBlobPatchStream(res, tdName, repo, dig, optBadDig, optErrBody)
 				default:
 					return fmt.Errorf("unknown api test %s", testName)
 				}
+				return errors.Join(err, r.TestErrorBody(res, "error body", &eb, "DIGEST_INVALID"))
 			})
 			if err != nil {
 				errs = append(errs, err)
@@ -1349,13 +1482,14 @@ func (r *runner) TestDeleteTag(parent *results, tdName string, repo string, tag
 	if !td.tagPushed[tag] {
 		return nil // tag was not pushed so skip the attempt to delete it
 	}
-	return r.ChildRun("tag-delete", parent, func(r *runner, res *results) error {
+	eb := apiErrorBody{}
+	err := r.ChildRun("tag-delete", parent, func(r *runner, res *results) error {
 		if err := r.APIRequire(stateAPITagDelete); err != nil {
 			r.State.DataStatus[tdName] = r.State.DataStatus[tdName].Set(statusSkip)
 			r.TestSkip(res, err, tdName, stateAPITagDelete, stateAPITagDeleteAtomic)
 			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 		}
-		if err := r.API.ManifestDelete(r.Config.schemeReg, repo, tag, dig, td, apiSaveOutput(res.Output)); err != nil {
+		if err := r.API.ManifestDelete(r.Config.schemeReg, repo, tag, dig, td, apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 			r.TestFail(res, err, tdName, stateAPITagDelete)
 			r.TestSkip(res, err, tdName, stateAPITagDeleteAtomic)
 			return fmt.Errorf("%.0w%w", errAPITestFail, err)
@@ -1373,17 +1507,19 @@ func (r *runner) TestDeleteTag(parent *results, tdName string, repo string, tag
 		r.TestPass(res, tdName, stateAPITagDeleteAtomic)
 		return nil
 	})
+	return errors.Join(err, r.TestErrorBody(parent, "tag-delete error body", &eb, deleteErrorCodes(eb.status, "MANIFEST_UNKNOWN")...))
 }
 
 func (r *runner) TestDeleteManifest(parent *results, tdName string, repo string, dig digest.Digest) error {
 	td := r.State.Data[tdName]
-	return r.ChildRun("manifest-delete", parent, func(r *runner, res *results) error {
+	eb := apiErrorBody{}
+	err := r.ChildRun("manifest-delete", parent, func(r *runner, res *results) error {
 		if err := r.APIRequire(stateAPIManifestDelete); err != nil {
 			r.State.DataStatus[tdName] = r.State.DataStatus[tdName].Set(statusSkip)
 			r.TestSkip(res, err, tdName, stateAPIManifestDelete, stateAPIManifestDeleteAtomic)
 			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 		}
-		if err := r.API.ManifestDelete(r.Config.schemeReg, repo, dig.String(), dig, td, apiSaveOutput(res.Output)); err != nil {
+		if err := r.API.ManifestDelete(r.Config.schemeReg, repo, dig.String(), dig, td, apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 			r.TestFail(res, err, tdName, stateAPIManifestDelete)
 			r.TestSkip(res, err, tdName, stateAPIManifestDeleteAtomic)
 			return fmt.Errorf("%.0w%w", errAPITestFail, err)
@@ -1401,16 +1537,18 @@ func (r *runner) TestDeleteManifest(parent *results, tdName string, repo string,
 		r.TestPass(res, tdName, stateAPIManifestDeleteAtomic)
 		return nil
 	})
+	return errors.Join(err, r.TestErrorBody(parent, "manifest-delete error body", &eb, deleteErrorCodes(eb.status, "MANIFEST_UNKNOWN")...))
 }
 
 func (r *runner) TestDeleteBlob(parent *results, tdName string, repo string, dig digest.Digest) error {
-	return r.ChildRun("blob-delete", parent, func(r *runner, res *results) error {
+	eb := apiErrorBody{}
+	err := r.ChildRun("blob-delete", parent, func(r *runner, res *results) error {
 		td := r.State.Data[tdName]
 		if err := r.APIRequire(stateAPIBlobDelete); err != nil {
 			r.TestSkip(res, err, tdName, stateAPIBlobDelete, stateAPIBlobDeleteAtomic)
 			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 		}
-		if err := r.API.BlobDelete(r.Config.schemeReg, repo, dig, td, apiSaveOutput(res.Output)); err != nil {
+		if err := r.API.BlobDelete(r.Config.schemeReg, repo, dig, td, apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 			r.TestFail(res, err, tdName, stateAPIBlobDelete)
 			r.TestSkip(res, err, tdName, stateAPIBlobDeleteAtomic)
 			return fmt.Errorf("%.0w%w", errAPITestFail, err)
@@ -1428,6 +1566,20 @@ func (r *runner) TestDeleteBlob(parent *results, tdName string, repo string, dig
 		r.TestPass(res, tdName, stateAPIBlobDeleteAtomic)
 		return nil
 	})
+	return errors.Join(err, r.TestErrorBody(parent, "blob-delete error body", &eb, deleteErrorCodes(eb.status, "BLOB_UNKNOWN")...))
+}
+
+// deleteErrorCodes returns the error codes expected when a delete request fails with the status.
+// A missing manifest or blob returns the unknown code, a registry may deny the delete with an auth code,
+// and other failures indicate deletes are unsupported.
+func deleteErrorCodes(status int, unknown string) []string {
+	switch status {
+	case http.StatusNotFound:
+		return []string{unknown, "NAME_UNKNOWN"}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return []string{"UNAUTHORIZED", "DENIED", "UNSUPPORTED"}
+	}
+	return []string{"UNSUPPORTED"}
 }
 
 func (r *runner) TestEmpty(parent *results, repo string) error {
@@ -1453,7 +1605,7 @@ func (r *runner) TestEmptyReferrers(parent *results, repo string) error {
 			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 		}
 		subj := digest.Canonical.FromString(rand.Text())
-		_, err := r.API.ReferrersList(r.Config.schemeReg, repo, subj, apiSaveOutput(res.Output))
+		_, err := r.API.ReferrersList(r.Config.schemeReg, repo, subj, "", apiSaveOutput(res.Output))
 		if err != nil {
 			r.TestFail(res, err, "", stateAPIReferrers)
 			return fmt.Errorf("%.0w%w", errAPITestFail, err)
@@ -1478,6 +1630,72 @@ func (r *runner) TestEmptyTagList(parent *results, repo string) error {
 	})
 }
 
+// TestErrorBody verifies the body of a 4XX response has one of the expected error codes.
+// Results are tracked with stateAPIErrorCodes, separately from the status code of the response.
+// No test is run when a 4XX response was not received.
+func (r *runner) TestErrorBody(parent *results, name string, eb *apiErrorBody, codes ...string) error {
+	if eb.status == 0 {
+		return nil
+	}
+	return r.ChildRun(name, parent, func(r *runner, res *results) error {
+		if err := r.APIRequire(stateAPIErrorCodes); err != nil {
+			r.TestSkip(res, err, "", stateAPIErrorCodes)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		_, _ = fmt.Fprintf(res.Output, "status %d, Content-Type %q\n", eb.status, eb.contentType)
+		_ = printBody(eb.body, res.Output)
+		if err := eb.Verify(codes...); err != nil {
+			r.TestFail(res, err, "", stateAPIErrorCodes)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, "", stateAPIErrorCodes)
+		return nil
+	})
+}
+
+func (r *runner) TestRateLimit(parent *results) error {
+	return r.ChildRun("rate limit", parent, func(r *runner, res *results) error {
+		rl := r.API.rateLimit.Summary()
+		if rl.Throttled == 0 {
+			r.TestSkip(res, fmt.Errorf("registry did not return a 429 status"), "", stateAPIRateLimit)
+			return fmt.Errorf("%.0w", errAPITestSkip)
+		}
+		_, _ = fmt.Fprintf(res.Output, "%d requests were throttled\n", rl.Throttled)
+		if len(rl.Invalid) > 0 {
+			err := fmt.Errorf("429 responses did not follow the spec:\n%s", strings.Join(rl.Invalid, "\n"))
+			r.TestFail(res, err, "", stateAPIRateLimit)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, "", stateAPIRateLimit)
+		return nil
+	})
+}
+
+func (r *runner) TestProxy(parent *results) error {
+	return r.ChildRun("proxy", parent, func(r *runner, res *results) error {
+		if err := r.APIRequire(stateAPIProxy); err != nil {
+			r.TestSkip(res, err, "", stateAPIProxy)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		p := r.API.proxy.Summary()
+		_, _ = fmt.Fprintf(res.Output, "%d pull requests sent with ns=%s, %d returned the %s header\n", p.Requests, p.Upstream, p.Echoed, proxyNamespaceHeader)
+		errs := []error{}
+		if p.Requests == 0 {
+			errs = append(errs, fmt.Errorf("no pull requests were sent with the %s parameter", proxyNamespaceParam))
+		}
+		for _, msg := range p.Invalid {
+			errs = append(errs, fmt.Errorf("%s", msg))
+		}
+		if len(errs) > 0 {
+			err := errors.Join(errs...)
+			r.TestFail(res, err, "", stateAPIProxy)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, "", stateAPIProxy)
+		return nil
+	})
+}
+
 func (r *runner) TestHead(parent *results, tdName string, repo string) error {
 	return r.ChildRun("head", parent, func(r *runner, res *results) error {
 		errs := []error{}
@@ -1631,10 +1849,154 @@ func (r *runner) TestList(parent *results, tdName string, repo string) error {
 	})
 }
 
+// TestTagPagination pushes many tags to a repository and walks the tag listing with various page sizes.
+func (r *runner) TestTagPagination(parent *results, repo string) error {
+	tdName := "tag-pagination"
+	r.State.Data[tdName] = newTestData("Tag Pagination")
+	r.State.DataStatus[tdName] = statusUnknown
+	count := r.Config.Data.TagPagination
+	if count <= 0 {
+		r.State.DataStatus[tdName] = statusDisabled
+		return nil
+	}
+	return r.ChildRun("tag-pagination", parent, func(r *runner, res *results) error {
+		if err := r.APIRequire(stateAPITagList, stateAPIManifestPutTag); err != nil {
+			r.TestSkip(res, err, tdName, stateAPITagList)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		errs := []error{}
+		td := r.State.Data[tdName]
+		dig, err := td.genManifestFull(genWithLayerCount(1))
+		if err != nil {
+			return err
+		}
+		for blobDig := range td.blobs {
+			if err := r.TestPushBlobAny(res, tdName, repo, blobDig); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		// mix the case and leading characters to verify the sort order
+		prefixes := []string{"A", "b", "C", "d", "0", "_"}
+		tags := make([]string, count)
+		for i := range count {
+			tags[i] = fmt.Sprintf("%s%04d", prefixes[i%len(prefixes)], i)
+		}
+		// individual tags are not tracked in the test data, deleting the manifest removes them
+		err = r.ChildRun("push tags", res, func(r *runner, res *results) error {
+			for _, tag := range tags {
+				if err := r.API.ManifestPut(r.Config.schemeReg, repo, tag, dig, td, false, nil, apiSaveOutput(res.Output)); err != nil {
+					r.TestFail(res, err, tdName, stateAPIManifestPutTag)
+					return fmt.Errorf("%.0w%w", errAPITestFail, err)
+				}
+			}
+			r.TestPass(res, tdName, stateAPIManifestPutTag)
+			return nil
+		})
+		if err != nil {
+			r.TestSkip(res, fmt.Errorf("tag pagination requires the tags to be pushed"), tdName, stateAPITagList)
+			return errors.Join(append(errs, err)...)
+		}
+		for _, n := range []int{0, 7, 100, count, count + 1} {
+			err := r.ChildRun(fmt.Sprintf("n=%d", n), res, func(r *runner, res *results) error {
+				if err := r.TestTagPaginationWalk(res, repo, n, tags); err != nil {
+					r.TestFail(res, err, tdName, stateAPITagList)
+					return fmt.Errorf("%.0w%w", errAPITestFail, err)
+				}
+				r.TestPass(res, tdName, stateAPITagList)
+				return nil
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		// cleanup
+		if err := r.TestDelete(res, tdName, repo); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// TestTagPaginationWalk lists the tags with a page size of n, following the Link header or the last parameter,
+// and verifies the page sizes, sort order, and that every pushed tag was returned once.
+func (r *runner) TestTagPaginationWalk(res *results, repo string, n int, pushed []string) error {
+	u, err := url.Parse(fmt.Sprintf("%s/v2/%s/tags/list?n=%d", r.Config.schemeReg, repo, n))
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	listed := []string{}
+	linked := false
+	// a page size of 0 makes a single request
+	maxPages := 1
+	if n > 0 {
+		maxPages = len(pushed)/n + 2
+	}
+	for page := 0; ; page++ {
+		if page >= maxPages {
+			return fmt.Errorf("pagination did not terminate after %d pages", maxPages)
+		}
+		tl, next, err := r.API.TagListPage(u, apiSaveOutput(res.Output))
+		if err != nil {
+			return fmt.Errorf("failed to list page %d: %w", page, err)
+		}
+		if len(tl.Tags) > n {
+			return fmt.Errorf("page %d returned %d tags, more than n=%d", page, len(tl.Tags), n)
+		}
+		if n == 0 {
+			if len(tl.Tags) != 0 || next != nil {
+				return fmt.Errorf("n=0 must return an empty list without a Link header, received %d tags and Link %s", len(tl.Tags), next)
+			}
+			return nil
+		}
+		if linked && len(tl.Tags) == 0 {
+			return fmt.Errorf("Link header on page %d returned an empty page", page-1)
+		}
+		for _, tag := range tl.Tags {
+			if seen[tag] {
+				return fmt.Errorf("tag %q returned more than once", tag)
+			}
+			seen[tag] = true
+			listed = append(listed, tag)
+		}
+		linked = next != nil
+		if linked {
+			u = next
+			continue
+		}
+		if len(tl.Tags) < n {
+			break
+		}
+		// without a Link header, fall back to the last parameter
+		u, err = u.Parse(fmt.Sprintf("/v2/%s/tags/list", repo))
+		if err != nil {
+			return err
+		}
+		u.RawQuery = url.Values{"n": {strconv.Itoa(n)}, "last": {tl.Tags[len(tl.Tags)-1]}}.Encode()
+	}
+	_, _ = fmt.Fprintf(res.Output, "listed %d tags with n=%d\n", len(listed), n)
+	if !slices.IsSorted(listed) && !slices.IsSortedFunc(listed, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}) {
+		return fmt.Errorf("tags were not returned in lexical or ASCIIbetical order: %v", listed)
+	}
+	missing := []string{}
+	for _, tag := range pushed {
+		if !seen[tag] {
+			missing = append(missing, tag)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("listing with n=%d is missing %d of %d tags: %v", n, len(missing), len(pushed), missing)
+	}
+	return nil
+}
+
 func (r *runner) TestManifestErrors(parent *results, repo string) error {
 	errs := []error{}
 	err := r.ChildRun("missing-manifest", parent, func(r *runner, res *results) error {
 		errs := []error{}
+		eb := apiErrorBody{}
 		err := r.ChildRun("by-digest", res, func(r *runner, res *results) error {
 			if err := r.APIRequire(stateAPIManifestGetDigest); err != nil {
 				r.TestSkip(res, err, "", stateAPIManifestGetDigest)
@@ -1647,7 +2009,7 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 			}
 			dig := digest.Canonical.FromBytes(b)
 			if err := r.API.ManifestGetReq(r.Config.schemeReg, repo, dig.String(), dig, nil,
-				apiExpectStatus(http.StatusNotFound), apiSaveOutput(res.Output)); err != nil {
+				apiExpectStatus(http.StatusNotFound), apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 				r.TestFail(res, err, "", stateAPIManifestGetDigest)
 				return fmt.Errorf("%.0w%w", errAPITestFail, err)
 			}
@@ -1657,6 +2019,10 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err := r.TestErrorBody(res, "by-digest error body", &eb, "MANIFEST_UNKNOWN", "NAME_UNKNOWN"); err != nil {
+			errs = append(errs, err)
+		}
+		eb = apiErrorBody{}
 		err = r.ChildRun("by-tag", res, func(r *runner, res *results) error {
 			if err := r.APIRequire(stateAPIManifestGetTag); err != nil {
 				r.TestSkip(res, err, "", stateAPIManifestGetTag)
@@ -1665,7 +2031,7 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 			rnd := rand.Text()
 			tag := fmt.Sprintf("missing-%.20s", strings.ToLower(rnd))
 			if err := r.API.ManifestGetReq(r.Config.schemeReg, repo, tag, digest.Digest(""), nil,
-				apiExpectStatus(http.StatusNotFound), apiSaveOutput(res.Output)); err != nil {
+				apiExpectStatus(http.StatusNotFound), apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 				r.TestFail(res, err, "", stateAPIManifestGetTag)
 				return fmt.Errorf("%.0w%w", errAPITestFail, err)
 			}
@@ -1675,6 +2041,9 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err := r.TestErrorBody(res, "by-tag error body", &eb, "MANIFEST_UNKNOWN", "NAME_UNKNOWN"); err != nil {
+			errs = append(errs, err)
+		}
 		return errors.Join(errs...)
 	})
 	if err != nil {
@@ -1695,6 +2064,7 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 			}
 		}
 		// push digest "sha256:baddigeststring"
+		eb := apiErrorBody{}
 		err = r.ChildRun("manifest-put", res, func(r *runner, res *results) error {
 			if err := r.APIRequire(stateAPIManifestPutDigest); err != nil {
 				r.State.DataStatus[tdName] = r.State.DataStatus[tdName].Set(statusSkip)
@@ -1702,7 +2072,7 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 				return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 			}
 			if err := r.API.ManifestPut(r.Config.schemeReg, repo, "sha256:baddigeststring", manDig, r.State.Data[tdName], r.Config.APIs.Referrer, nil,
-				apiWithFlag("ExpectBadDigest"), apiSaveOutput(res.Output)); err != nil {
+				apiWithFlag("ExpectBadDigest"), apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 				r.TestFail(res, err, tdName, stateAPIManifestPutDigest)
 				return fmt.Errorf("%.0w%w", errAPITestFail, err)
 			}
@@ -1712,6 +2082,10 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err := r.TestErrorBody(res, "manifest-put error body", &eb, "DIGEST_INVALID", "MANIFEST_INVALID"); err != nil {
+			errs = append(errs, err)
+		}
+		eb = apiErrorBody{}
 		// pull digest "sha256:baddigeststring"
 		err = r.ChildRun("manifest-get", res, func(r *runner, res *results) error {
 			if err := r.APIRequire(stateAPIManifestGetDigest); err != nil {
@@ -1720,7 +2094,7 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 				return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 			}
 			if err := r.API.ManifestGetReq(r.Config.schemeReg, repo, "sha256:baddigeststring", manDig, r.State.Data[tdName],
-				apiExpectStatus(http.StatusNotFound, http.StatusBadRequest), apiSaveOutput(res.Output)); err != nil {
+				apiExpectStatus(http.StatusNotFound, http.StatusBadRequest), apiReturnErrorBody(&eb), apiSaveOutput(res.Output)); err != nil {
 				r.TestFail(res, err, tdName, stateAPIManifestGetDigest)
 				return fmt.Errorf("%.0w%w", errAPITestFail, err)
 			}
@@ -1730,6 +2104,9 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 		if err != nil {
 			errs = append(errs, err)
 		}
+		if err := r.TestErrorBody(res, "manifest-get error body", &eb, "DIGEST_INVALID", "MANIFEST_INVALID", "MANIFEST_UNKNOWN"); err != nil {
+			errs = append(errs, err)
+		}
 		// cleanup
 		err = r.TestDelete(res, tdName, repo)
 		if err != nil {
@@ -1744,6 +2121,169 @@ func (r *runner) TestManifestErrors(parent *results, repo string) error {
 	return errors.Join(errs...)
 }
 
+// TestManifestConditional verifies the optional support for ETags, which clients may use to avoid clobbering a tag such as the referrers tag.
+// Registries without ETag support are skipped rather than failed.
+func (r *runner) TestManifestConditional(parent *results, repo string) error {
+	return r.ChildRun("conditional-requests", parent, func(r *runner, res *results) error {
+		apis := []stateAPIType{stateAPIManifestConditionalGet, stateAPIManifestConditionalPut}
+		if err := r.APIRequire(apis...); err != nil {
+			r.TestSkip(res, err, "", apis...)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		errs := []error{}
+		tdName := "conditional-requests"
+		r.State.Data[tdName] = newTestData("Conditional Requests")
+		digs := []digest.Digest{}
+		for range 3 {
+			dig, err := r.State.Data[tdName].genManifestFull(genWithLayerCount(1))
+			if err != nil {
+				return err
+			}
+			digs = append(digs, dig)
+		}
+		// referrers are pushed without a subject so the fallback tag is tested without depending on the referrers API
+		referrers := []digest.Digest{}
+		for range 2 {
+			dig, err := r.State.Data[tdName].genManifestFull(genWithLayerCount(1), genWithArtifactType(mtExampleConf1))
+			if err != nil {
+				return err
+			}
+			referrers = append(referrers, dig)
+		}
+		indexes := []digest.Digest{}
+		for _, list := range [][]digest.Digest{referrers[:1], referrers, referrers[1:]} {
+			dig, _, err := r.State.Data[tdName].genIndex(make([]*image.Platform, len(list)), list)
+			if err != nil {
+				return err
+			}
+			indexes = append(indexes, dig)
+		}
+		for dig := range r.State.Data[tdName].blobs {
+			if err := r.TestPushBlobAny(res, tdName, repo, dig); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, dig := range referrers {
+			if err := r.TestPushManifestDigest(res, tdName, repo, dig); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		err := r.ChildRun("tag", res, func(r *runner, res *results) error {
+			return r.testManifestConditionalTag(res, tdName, repo, "conditional", digs)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		err = r.ChildRun("referrers tag", res, func(r *runner, res *results) error {
+			return r.testManifestConditionalTag(res, tdName, repo, specs.ReferrersTag(digs[0]), indexes)
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		// cleanup
+		if err := r.TestDelete(res, tdName, repo); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// testManifestConditionalTag pushes the first digest to the tag and replaces it with the others using conditional requests.
+// Once the registry has returned an ETag and a 304 for an unchanged manifest, ignoring a precondition on a push is a failure.
+func (r *runner) testManifestConditionalTag(res *results, tdName, repo, tag string, digs []digest.Digest) error {
+	apis := []stateAPIType{stateAPIManifestConditionalGet, stateAPIManifestConditionalPut}
+	errs := []error{}
+	if err := r.TestPushManifestTag(res, tdName, repo, tag, digs[0]); err != nil {
+		r.TestSkip(res, fmt.Errorf("conditional requests require a manifest pushed by tag"), "", apis...)
+		return errors.Join(append(errs, err)...)
+	}
+	r.State.Data[tdName].tags[tag] = digs[0]
+	etag := ""
+	supported := false
+	err := r.ChildRun("conditional get", res, func(r *runner, res *results) error {
+		status, cur, err := r.API.ManifestGetConditional(r.Config.schemeReg, repo, tag, "", apiSaveOutput(res.Output))
+		if err == nil && cur == "" {
+			err = fmt.Errorf("registry did not return an ETag header%.0w", errRegUnsupported)
+		}
+		if err == nil {
+			etag = cur
+			headETag := ""
+			err = r.API.ManifestHeadReq(r.Config.schemeReg, repo, tag, digs[0], r.State.Data[tdName],
+				apiExpectStatus(http.StatusOK), apiReturnHeader("ETag", &headETag), apiSaveOutput(res.Output))
+			if err == nil && headETag != etag {
+				err = fmt.Errorf("registry returned ETag %q on HEAD, expected %q from GET", headETag, etag)
+			}
+		}
+		if err == nil {
+			status, _, err = r.API.ManifestGetConditional(r.Config.schemeReg, repo, tag, etag, apiSaveOutput(res.Output))
+		}
+		if err == nil && status != http.StatusNotModified {
+			err = fmt.Errorf("registry returned status %d for an unchanged manifest with If-None-Match: %s%.0w", status, etag, errRegUnsupported)
+		}
+		if err != nil {
+			r.TestFail(res, err, tdName, stateAPIManifestConditionalGet)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		supported = true
+		r.TestPass(res, tdName, stateAPIManifestConditionalGet)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = r.ChildRun("conditional put", res, func(r *runner, res *results) error {
+		if etag == "" {
+			err := fmt.Errorf("registry did not return an ETag header%.0w", errRegUnsupported)
+			r.TestSkip(res, err, tdName, stateAPIManifestConditionalPut)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		errs := []error{}
+		// a registry that has not shown ETag support is skipped when it ignores the precondition
+		preconditionErr := func(err error) error {
+			if supported {
+				return err
+			}
+			return fmt.Errorf("%w%.0w", err, errRegUnsupported)
+		}
+		// a matching ETag updates the tag
+		status, _, err := r.API.ManifestPutConditional(r.Config.schemeReg, repo, tag, digs[1], r.State.Data[tdName], "If-Match", etag, apiSaveOutput(res.Output))
+		if err != nil {
+			errs = append(errs, err)
+		} else if status != http.StatusCreated {
+			errs = append(errs, preconditionErr(fmt.Errorf("registry returned status %d for a push with the current ETag in If-Match: %s", status, etag)))
+		} else {
+			r.State.Data[tdName].tags[tag] = digs[1]
+		}
+		// the original ETag is now stale
+		status, _, err = r.API.ManifestPutConditional(r.Config.schemeReg, repo, tag, digs[2], r.State.Data[tdName], "If-Match", etag, apiSaveOutput(res.Output))
+		if err != nil {
+			errs = append(errs, err)
+		} else if status != http.StatusPreconditionFailed {
+			r.State.Data[tdName].tags[tag] = digs[2]
+			errs = append(errs, preconditionErr(fmt.Errorf("registry returned status %d for a push with a stale ETag in If-Match: %s", status, etag)))
+		}
+		// the tag already exists
+		status, _, err = r.API.ManifestPutConditional(r.Config.schemeReg, repo, tag, digs[2], r.State.Data[tdName], "If-None-Match", "*", apiSaveOutput(res.Output))
+		if err != nil {
+			errs = append(errs, err)
+		} else if status != http.StatusPreconditionFailed {
+			r.State.Data[tdName].tags[tag] = digs[2]
+			errs = append(errs, preconditionErr(fmt.Errorf("registry returned status %d for a push to an existing tag with If-None-Match: *", status)))
+		}
+		if len(errs) > 0 {
+			err := errors.Join(errs...)
+			r.TestFail(res, err, tdName, stateAPIManifestConditionalPut)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, tdName, stateAPIManifestConditionalPut)
+		return nil
+	})
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
 func (r *runner) TestPing(parent *results) error {
 	return r.ChildRun("ping", parent, func(r *runner, res *results) error {
 		if err := r.APIRequire(stateAPIPing); err != nil {
@@ -2020,6 +2560,46 @@ func (r *runner) TestPushBlobPostCancel(parent *results, tdName string, repo str
 	})
 }
 
+func (r *runner) TestPushBlobPostAlgorithm(parent *results, tdName string, repo string, dig digest.Digest, opts ...apiDoOpt) error {
+	return r.ChildRun("blob-post-digest-algorithm", parent, func(r *runner, res *results) error {
+		if err := r.APIRequire(stateAPIBlobPostAlgorithm); err != nil {
+			r.TestSkip(res, err, tdName, stateAPIBlobPostAlgorithm)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		opts = append(opts, apiSaveOutput(res.Output))
+		if r.Config.APIs.Blobs.DigestHeader {
+			opts = append(opts, apiWithFlag("RequireDigestHeader"))
+		}
+		if err := r.API.BlobPostAlgorithm(r.Config.schemeReg, repo, dig, r.State.Data[tdName], opts...); err != nil {
+			r.TestFail(res, err, tdName, stateAPIBlobPostAlgorithm)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, tdName, stateAPIBlobPostAlgorithm, stateAPIBlobPush)
+		return nil
+	})
+}
+
+func (r *runner) TestPushBlobPostAlgorithmUnsupported(parent *results, tdName string, repo string, opts ...apiDoOpt) error {
+	eb := apiErrorBody{}
+	err := r.ChildRun("blob-post-digest-algorithm-unsupported", parent, func(r *runner, res *results) error {
+		if err := r.APIRequire(stateAPIBlobPostAlgorithm); err != nil {
+			r.TestSkip(res, err, tdName, stateAPIBlobPostAlgorithm)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		opts = append(opts, apiReturnErrorBody(&eb), apiSaveOutput(res.Output))
+		if err := r.API.BlobPostAlgorithmUnsupported(r.Config.schemeReg, repo, algoUnsupported, opts...); err != nil {
+			r.TestFail(res, err, tdName, stateAPIBlobPostAlgorithm)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, tdName, stateAPIBlobPostAlgorithm)
+		return nil
+	})
+	if errBody := r.TestErrorBody(parent, "blob-post-digest-algorithm-unsupported error body", &eb, "DIGEST_INVALID", "UNSUPPORTED"); errBody != nil {
+		err = errors.Join(err, errBody)
+	}
+	return err
+}
+
 func (r *runner) TestPushBlobPostPut(parent *results, tdName string, repo string, dig digest.Digest, opts ...apiDoOpt) error {
 	return r.ChildRun("blob-post-put", parent, func(r *runner, res *results) error {
 		if err := r.APIRequire(stateAPIBlobPostPut); err != nil {
@@ -2077,6 +2657,25 @@ func (r *runner) TestPushBlobPatchChunked(parent *results, tdName string, repo s
 	})
 }
 
+func (r *runner) TestPushBlobPatchResume(parent *results, tdName string, repo string, dig digest.Digest, opts ...apiDoOpt) error {
+	return r.ChildRun("blob-patch-resume", parent, func(r *runner, res *results) error {
+		if err := r.APIRequire(stateAPIBlobPatchResume); err != nil {
+			r.TestSkip(res, err, tdName, stateAPIBlobPatchResume)
+			return fmt.Errorf("%.0w%w", errAPITestSkip, err)
+		}
+		opts = append(opts, apiSaveOutput(res.Output))
+		if r.Config.APIs.Blobs.DigestHeader {
+			opts = append(opts, apiWithFlag("RequireDigestHeader"))
+		}
+		if err := r.API.BlobPatchResume(r.Config.schemeReg, repo, dig, r.State.Data[tdName], opts...); err != nil {
+			r.TestFail(res, err, tdName, stateAPIBlobPatchResume)
+			return fmt.Errorf("%.0w%w", errAPITestFail, err)
+		}
+		r.TestPass(res, tdName, stateAPIBlobPatchResume, stateAPIBlobPush)
+		return nil
+	})
+}
+
 func (r *runner) TestPushBlobPatchStream(parent *results, tdName string, repo string, dig digest.Digest, opts ...apiDoOpt) error {
 	return r.ChildRun("blob-patch-stream", parent, func(r *runner, res *results) error {
 		if err := r.APIRequire(stateAPIBlobPatchStream); err != nil {
@@ -2198,7 +2797,7 @@ func (r *runner) TestReferrers(parent *results, tdName string, repo string) erro
 				r.TestSkip(res, err, tdName, stateAPIReferrers)
 				return fmt.Errorf("%.0w%w", errAPITestSkip, err)
 			}
-			referrerResp, err := r.API.ReferrersList(r.Config.schemeReg, repo, subj, apiSaveOutput(res.Output))
+			referrerResp, err := r.API.ReferrersList(r.Config.schemeReg, repo, subj, "", apiSaveOutput(res.Output))
 			if err != nil {
 				errs = append(errs, err)
 			}
@@ -2221,9 +2820,9 @@ func (r *runner) TestReferrers(parent *results, tdName string, repo string) erro
 			}
 			// search for referrers filtered by artifactType
 			for referrerAT := range referrerATs {
+				// ReferrersList requires the same header on every page
 				var filtersHeader string
-				referrerResp, err := r.API.ReferrersList(r.Config.schemeReg, repo, subj, apiSaveOutput(res.Output),
-					apiWithURLParam("artifactType", referrerAT),
+				referrerResp, err := r.API.ReferrersList(r.Config.schemeReg, repo, subj, referrerAT, apiSaveOutput(res.Output),
 					apiReturnHeader("OCI-Filters-Applied", &filtersHeader))
 				if err != nil {
 					errs = append(errs, err)
@@ -2392,7 +2991,7 @@ func (r *runner) APIRequire(apis ...stateAPIType) error {
 			}
 		case stateAPIManifestPutTag, stateAPIManifestPutDigest, stateAPIManifestPutSubject,
 			stateAPIBlobPush, stateAPIBlobPostOnly, stateAPIBlobPostPut,
-			stateAPIBlobPatchChunked, stateAPIBlobPatchStream, stateAPIBlobMountSource:
+			stateAPIBlobPatchChunked, stateAPIBlobPatchStream, stateAPIBlobPatchResume, stateAPIBlobMountSource:
 			if !r.Config.APIs.Push {
 				configDisabled = true
 			}
@@ -2400,6 +2999,10 @@ func (r *runner) APIRequire(apis ...stateAPIType) error {
 			if !r.Config.APIs.Push || !r.Config.APIs.Manifests.TagParam {
 				configDisabled = true
 			}
+		case stateAPIBlobPostAlgorithm:
+			if !r.Config.APIs.Push || !r.Config.APIs.Blobs.DigestAlgorithm {
+				configDisabled = true
+			}
 		case stateAPIBlobCancel:
 			if !r.Config.APIs.Blobs.UploadCancel {
 				configDisabled = true
@@ -2424,6 +3027,10 @@ func (r *runner) APIRequire(apis ...stateAPIType) error {
 			if !r.Config.APIs.Manifests.Atomic {
 				configDisabled = true
 			}
+		case stateAPIManifestConditionalGet, stateAPIManifestConditionalPut:
+			if !r.Config.APIs.Push || !r.Config.APIs.Pull || !r.Config.APIs.Manifests.Conditional {
+				configDisabled = true
+			}
 		case stateAPIBlobDelete:
 			if !r.Config.APIs.Blobs.Delete {
 				configDisabled = true
@@ -2436,6 +3043,16 @@ func (r *runner) APIRequire(apis ...stateAPIType) error {
 			if !r.Config.APIs.Referrer {
 				configDisabled = true
 			}
+		case stateAPIErrorCodes:
+			if !r.Config.APIs.ErrorCodes {
+				configDisabled = true
+			}
+		case stateAPIRateLimit:
+			// 429 responses are always validated when they are returned by the registry
+		case stateAPIProxy:
+			if r.Config.Proxy.Upstream == "" {
+				configDisabled = true
+			}
 		default:
 			return fmt.Errorf("APIRequire check is missing for state %s%.0w", a.String(), errAPITestError)
 		}
@@ -0,0 +1,403 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit_tests
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRunnerMemReg(t *testing.T) {
+	tt := []struct {
+		name       string
+		version    string
+		regOpts    []memRegOpt
+		env        map[string]string
+		expect     status
+		expectAPIs map[stateAPIType]status
+		expectWarn []string
+	}{
+		{
+			name:    "dev",
+			version: "1.1+dev",
+			expect:  statusPass,
+		},
+		{
+			name:    "stable",
+			version: "1.1",
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIBlobCancel:          statusDisabled,
+				stateAPIBlobPostAlgorithm:   statusDisabled,
+				stateAPIManifestPutTagParam: statusDisabled,
+			},
+		},
+		{
+			name:    "referrers unsupported",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutReferrers()},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				// the missing OCI-Subject header fails the manifest push
+				stateAPIReferrers:          statusFail,
+				stateAPIManifestPutSubject: statusFail,
+				stateAPIManifestPutDigest:  statusFail,
+				stateAPIManifestPutTag:     statusFail,
+			},
+		},
+		{
+			name:    "referrers disabled",
+			version: "1.0",
+			regOpts: []memRegOpt{memRegWithoutReferrers()},
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIReferrers:           statusDisabled,
+				stateAPIBlobMountAnonymous:  statusDisabled,
+				stateAPIBlobCancel:          statusDisabled,
+				stateAPIBlobPostAlgorithm:   statusDisabled,
+				stateAPIManifestPutTagParam: statusDisabled,
+			},
+		},
+		{
+			name:    "delete unsupported",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutDelete()},
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPITagDelete:            statusSkip,
+				stateAPITagDeleteAtomic:      statusSkip,
+				stateAPIManifestDelete:       statusSkip,
+				stateAPIManifestDeleteAtomic: statusSkip,
+				stateAPIBlobDelete:           statusSkip,
+				stateAPIBlobDeleteAtomic:     statusSkip,
+			},
+		},
+		{
+			name:    "delete disabled",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutDelete()},
+			env: map[string]string{
+				"OCI_API_BLOBS_DELETE":     "false",
+				"OCI_API_BLOBS_ATOMIC":     "false",
+				"OCI_API_MANIFESTS_DELETE": "false",
+				"OCI_API_MANIFESTS_ATOMIC": "false",
+				"OCI_API_TAGS_DELETE":      "false",
+			},
+			expect: statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPITagDelete:            statusDisabled,
+				stateAPITagDeleteAtomic:      statusDisabled,
+				stateAPIManifestDelete:       statusDisabled,
+				stateAPIManifestDeleteAtomic: statusDisabled,
+				stateAPIBlobDelete:           statusDisabled,
+				stateAPIBlobDeleteAtomic:     statusDisabled,
+			},
+		},
+		{
+			name:    "error codes",
+			version: "1.1+dev",
+			env:     map[string]string{"OCI_API_ERROR_CODES": "true"},
+			expect:  statusPass,
+		},
+		{
+			name:    "error codes invalid",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithLowerCaseErrors()},
+			env:     map[string]string{"OCI_API_ERROR_CODES": "true"},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				// the status codes are still valid, only the error code check fails
+				stateAPIErrorCodes: statusFail,
+			},
+		},
+		{
+			name:    "delete unsupported with error codes",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutDelete()},
+			env:     map[string]string{"OCI_API_ERROR_CODES": "true"},
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPITagDelete:            statusSkip,
+				stateAPITagDeleteAtomic:      statusSkip,
+				stateAPIManifestDelete:       statusSkip,
+				stateAPIManifestDeleteAtomic: statusSkip,
+				stateAPIBlobDelete:           statusSkip,
+				stateAPIBlobDeleteAtomic:     statusSkip,
+			},
+		},
+		{
+			name:    "delete denied with error codes",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithDeleteDenied()},
+			env:     map[string]string{"OCI_API_ERROR_CODES": "true"},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				// the 403 fails each delete, while the DENIED code is accepted in the error body
+				stateAPITagDelete:            statusFail,
+				stateAPITagDeleteAtomic:      statusSkip,
+				stateAPIManifestDelete:       statusFail,
+				stateAPIManifestDeleteAtomic: statusSkip,
+				stateAPIBlobDelete:           statusFail,
+				stateAPIBlobDeleteAtomic:     statusSkip,
+			},
+		},
+		{
+			name:    "warnings",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{
+				memRegWithWarnings(`299 - "repository is deprecated"`),
+				memRegWithPingWarnings(`299 - "maintenance \"tonight\"", 299 - "repository is deprecated"`),
+			},
+			expect:     statusPass,
+			expectWarn: []string{`"repository is deprecated"`, `"maintenance \"tonight\""`},
+		},
+		{
+			name:    "warnings invalid",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithPingWarnings(`199 registry "deprecated" "Thu, 01 Jan 2026 00:00:00 GMT"`)},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIPing: statusFail,
+			},
+			expectWarn: []string{`INVALID "199 registry \"deprecated\" \"Thu, 01 Jan 2026 00:00:00 GMT\"": warning must use a warn-code of 299: received 199`},
+		},
+		{
+			name:    "upload status unsupported",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutUploadStatus()},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				// out-of-order chunks recover with the upload status
+				stateAPIBlobPatchChunked: statusFail,
+				stateAPIBlobPatchResume:  statusFail,
+			},
+		},
+		{
+			name:    "proxy",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithNamespace()},
+			env: map[string]string{
+				"OCI_PROXY_UPSTREAM":  "upstream.example.com",
+				"OCI_PROXY_NAMESPACE": "echo",
+			},
+			expect: statusPass,
+		},
+		{
+			name:    "proxy namespace ignored",
+			version: "1.1+dev",
+			env:     map[string]string{"OCI_PROXY_UPSTREAM": "upstream.example.com"},
+			expect:  statusPass,
+		},
+		{
+			name:    "proxy namespace missing",
+			version: "1.1+dev",
+			env: map[string]string{
+				"OCI_PROXY_UPSTREAM":  "upstream.example.com",
+				"OCI_PROXY_NAMESPACE": "echo",
+			},
+			expect: statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIProxy: statusFail,
+			},
+		},
+		{
+			name:    "conditional requests unsupported",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutETag()},
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIManifestConditionalGet: statusSkip,
+				stateAPIManifestConditionalPut: statusSkip,
+			},
+		},
+		{
+			name:    "conditional requests ignored on push",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutPrecondition()},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIManifestConditionalPut: statusFail,
+			},
+		},
+		{
+			name:    "conditional requests without ETag on HEAD",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutHeadETag()},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIManifestConditionalGet: statusFail,
+			},
+		},
+		{
+			name:    "rate limit",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithRateLimit(7, false)},
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIRateLimit: statusPass,
+			},
+		},
+		{
+			name:    "rate limit invalid",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithRateLimit(7, true)},
+			env:     map[string]string{"OCI_RATE_LIMIT_BACKOFF": "1ms"},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				// throttled requests are retried, only the 429 validation fails
+				stateAPIRateLimit: statusFail,
+			},
+		},
+		{
+			name:    "tag list without link",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutTagLink()},
+			expect:  statusPass,
+		},
+		{
+			name:    "tag list with multiple links",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithTagLinkFirst()},
+			env:     map[string]string{"OCI_DATA_TAG_PAGINATION": "20"},
+			expect:  statusPass,
+		},
+		{
+			name:    "tag list ignores n",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutTagLimit()},
+			env:     map[string]string{"OCI_DATA_TAG_PAGINATION": "20"},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPITagList: statusFail,
+			},
+		},
+		{
+			name:    "referrers pagination",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithReferrersPages(7, false)},
+			expect:  statusPass,
+		},
+		{
+			name:    "referrers pagination duplicates",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithReferrersPages(7, true)},
+			env:     map[string]string{"OCI_DATA_REFERRERS_PAGINATION": "20"},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIReferrers: statusFail,
+			},
+		},
+		{
+			name:    "referrers filter header on first page",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithReferrersPages(7, false), memRegWithReferrersFilterFirst()},
+			env:     map[string]string{"OCI_DATA_REFERRERS_PAGINATION": "20"},
+			expect:  statusFail,
+			expectAPIs: map[stateAPIType]status{
+				stateAPIReferrers: statusFail,
+			},
+		},
+		{
+			name:    "mount unsupported",
+			version: "1.1+dev",
+			regOpts: []memRegOpt{memRegWithoutMount()},
+			expect:  statusPass,
+			expectAPIs: map[stateAPIType]status{
+				// the mount of a missing blob passes when the registry falls back to an upload session
+				stateAPIBlobMountSource:    statusPass,
+				stateAPIBlobMountAnonymous: statusSkip,
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(memRegNew(tc.regOpts...))
+			t.Cleanup(srv.Close)
+			r := memRegRunner(t, srv, tc.version, tc.env)
+			// errors are expected for unsupported APIs, the status is verified below
+			_ = r.TestAll()
+			if r.Results.Status != tc.expect {
+				var buf bytes.Buffer
+				r.Results.ReportWalkErr(&buf, "")
+				t.Errorf("unexpected result, expected %s, received %s\n%s", tc.expect.String(), r.Results.Status.String(), buf.String())
+			}
+			for api := range stateAPIMax {
+				expect, ok := tc.expectAPIs[api]
+				if !ok && api == stateAPIErrorCodes && tc.env["OCI_API_ERROR_CODES"] != "true" {
+					// error codes are only validated when enabled
+					expect = statusDisabled
+				} else if !ok && api == stateAPIProxy && tc.env["OCI_PROXY_UPSTREAM"] == "" {
+					// proxy tests only run with an upstream host
+					expect = statusDisabled
+				} else if !ok && api == stateAPIRateLimit {
+					// rate limits are only validated when the registry returns a 429
+					expect = statusSkip
+				} else if !ok {
+					expect = statusPass
+				}
+				if r.State.APIStatus[api] != expect {
+					t.Errorf("unexpected status for %s, expected %s, received %s", api.String(), expect.String(), r.State.APIStatus[api].String())
+				}
+			}
+			warnings := []string{}
+			for _, we := range r.API.warnings.Entries() {
+				warnings = append(warnings, we.String())
+			}
+			if !slices.Equal(warnings, tc.expectWarn) && (len(warnings) > 0 || len(tc.expectWarn) > 0) {
+				t.Errorf("unexpected warnings, expected %v, received %v", tc.expectWarn, warnings)
+			}
+			for tdName, s := range r.State.DataStatus {
+				if tc.expect == statusPass && s != statusPass && s != statusSkip && s != statusDisabled {
+					t.Errorf("unexpected status for data %s: %s", tdName, s.String())
+				}
+			}
+		})
+	}
+}
+
+// memRegRunner loads the config from environment variables in the same way as the command, pointing to the test server.
+func memRegRunner(t *testing.T, srv *httptest.Server, version string, env map[string]string) *runner {
+	t.Helper()
+	confFile := filepath.Join(t.TempDir(), "oci-conformance.yaml")
+	if err := os.WriteFile(confFile, []byte{}, 0o600); err != nil {
+		t.Fatalf("failed to create config file: %v", err)
+	}
+	t.Setenv(envOCIConfFile, confFile)
+	t.Setenv(envOCIVersion, version)
+	t.Setenv("OCI_REGISTRY", strings.TrimPrefix(srv.URL, "http://"))
+	t.Setenv("OCI_TLS", "disabled")
+	t.Setenv("OCI_RESULTS_DIR", t.TempDir())
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	c, err := configLoad()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	c.LogWriter = io.Discard
+	r, err := runnerNew(c)
+	if err != nil {
+		t.Fatalf("failed to create runner: %v", err)
+	}
+	return r
+}
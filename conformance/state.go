@@ -45,6 +45,8 @@ const (
 	stateAPIBlobPostPut
 	stateAPIBlobPatchChunked
 	stateAPIBlobPatchStream
+	stateAPIBlobPatchResume
+	stateAPIBlobPostAlgorithm
 	stateAPIBlobMountSource
 	stateAPIBlobMountAnonymous
 	stateAPIBlobGetFull
@@ -62,9 +64,14 @@ const (
 	stateAPIManifestHeadTag
 	stateAPIManifestDelete
 	stateAPIManifestDeleteAtomic
+	stateAPIManifestConditionalGet
+	stateAPIManifestConditionalPut
 	stateAPIReferrers
 	stateAPIPing
-	stateAPIMax // number of APIs for iterating
+	stateAPIErrorCodes // error response bodies, reported separately from the status codes
+	stateAPIRateLimit  // 429 responses, only tested when the registry throttles requests
+	stateAPIProxy      // pulls with the ns query parameter from a proxy registry
+	stateAPIMax        // number of APIs for iterating
 )
 
 func (a stateAPIType) String() string {
@@ -89,6 +96,10 @@ func (a stateAPIType) String() string {
 		return "Blob chunked"
 	case stateAPIBlobPatchStream:
 		return "Blob streaming"
+	case stateAPIBlobPatchResume:
+		return "Blob resumable push"
+	case stateAPIBlobPostAlgorithm:
+		return "Blob digest algorithm"
 	case stateAPIBlobMountSource:
 		return "Blob mount"
 	case stateAPIBlobMountAnonymous:
@@ -123,10 +134,20 @@ func (a stateAPIType) String() string {
 		return "Manifest delete"
 	case stateAPIManifestDeleteAtomic:
 		return "Manifest delete atomic"
+	case stateAPIManifestConditionalGet:
+		return "Manifest conditional get"
+	case stateAPIManifestConditionalPut:
+		return "Manifest conditional put"
 	case stateAPIReferrers:
 		return "Referrers"
 	case stateAPIPing:
 		return "Ping"
+	case stateAPIErrorCodes:
+		return "Error codes"
+	case stateAPIRateLimit:
+		return "Rate limit"
+	case stateAPIProxy:
+		return "Proxy"
 	}
 }
 
@@ -160,6 +181,10 @@ func (a *stateAPIType) UnmarshalText(b []byte) error {
 		*a = stateAPIBlobPatchChunked
 	case "Blob streaming":
 		*a = stateAPIBlobPatchStream
+	case "Blob resumable push":
+		*a = stateAPIBlobPatchResume
+	case "Blob digest algorithm":
+		*a = stateAPIBlobPostAlgorithm
 	case "Blob mount":
 		*a = stateAPIBlobMountSource
 	case "Blob anonymous mount":
@@ -194,10 +219,20 @@ func (a *stateAPIType) UnmarshalText(b []byte) error {
 		*a = stateAPIManifestDelete
 	case "Manifest delete atomic":
 		*a = stateAPIManifestDeleteAtomic
+	case "Manifest conditional get":
+		*a = stateAPIManifestConditionalGet
+	case "Manifest conditional put":
+		*a = stateAPIManifestConditionalPut
 	case "Referrers":
 		*a = stateAPIReferrers
 	case "Ping":
 		*a = stateAPIPing
+	case "Error codes":
+		*a = stateAPIErrorCodes
+	case "Rate limit":
+		*a = stateAPIRateLimit
+	case "Proxy":
+		*a = stateAPIProxy
 	}
 	return nil
 }
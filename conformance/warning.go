@@ -0,0 +1,88 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	specs "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// warningEntry is a deduplicated Warning header value received during the tests.
+type warningEntry struct {
+	Text    string `yaml:"text,omitempty"`    // warn-text from a valid header
+	Value   string `yaml:"value,omitempty"`   // header value that does not follow the spec
+	Invalid string `yaml:"invalid,omitempty"` // reason the header value does not follow the spec
+}
+
+func (we warningEntry) String() string {
+	if we.Invalid != "" {
+		return fmt.Sprintf("INVALID %q: %s", we.Value, we.Invalid)
+	}
+	return strconv.Quote(we.Text)
+}
+
+// warningCollector tracks the unique warnings from every registry response.
+type warningCollector struct {
+	seen    map[string]bool
+	entries []warningEntry
+}
+
+func warningCollectorNew() *warningCollector {
+	return &warningCollector{seen: map[string]bool{}}
+}
+
+func (wc *warningCollector) Add(h http.Header) {
+	for _, value := range h.Values(specs.WarningHeader) {
+		ws, err := specs.ParseWarningHeader(http.Header{specs.WarningHeader: {value}})
+		if err != nil {
+			if !wc.seen[value] {
+				wc.seen[value] = true
+				wc.entries = append(wc.entries, warningEntry{Value: value, Invalid: warningReason(err)})
+			}
+			continue
+		}
+		for _, w := range ws {
+			if !wc.seen[w.Text] {
+				wc.seen[w.Text] = true
+				wc.entries = append(wc.entries, warningEntry{Text: w.Text})
+			}
+		}
+	}
+}
+
+func (wc *warningCollector) Entries() []warningEntry {
+	return append([]warningEntry{}, wc.entries...)
+}
+
+// warningVerify returns an error when the Warning headers do not follow the spec.
+func warningVerify(h http.Header) error {
+	if _, err := specs.ParseWarningHeader(h); err != nil {
+		return fmt.Errorf("invalid Warning header: %w", err)
+	}
+	return nil
+}
+
+// warningReason returns the broken rule without the header value, which is included separately in the report.
+func warningReason(err error) string {
+	we := &specs.WarningError{}
+	if !errors.As(err, &we) {
+		return err.Error()
+	}
+	return (&specs.WarningError{Err: we.Err, Reason: we.Reason}).Error()
+}
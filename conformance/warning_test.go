@@ -0,0 +1,88 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build unit_tests
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestAPIWarnings verifies warnings are checked on every registry response, but not on the token server.
+func TestAPIWarnings(t *testing.T) {
+	const invalid = `199 registry "invalid"`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Add("Warning", `199 token "ignored"`)
+			_, _ = w.Write([]byte(`{"token":"abc"}`))
+		case "/v2/redirect":
+			w.Header().Add("Warning", invalid)
+			http.Redirect(w, r, "/v2/", http.StatusTemporaryRedirect)
+		case "/v2/":
+			if r.URL.Query().Get("auth") != "" && r.Header.Get("Authorization") != "Bearer abc" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="/token",service="test"`)
+				if r.URL.Query().Get("auth") == "warn" {
+					w.Header().Add("Warning", invalid)
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Add("Warning", `299 - "valid"`)
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+	tt := []struct {
+		name      string
+		path      string
+		expectErr bool
+	}{
+		{name: "valid", path: "/v2/"},
+		{name: "redirect", path: "/v2/redirect", expectErr: true},
+		{name: "token server", path: "/v2/?auth=token"},
+		{name: "auth challenge", path: "/v2/?auth=warn", expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := apiNew(srv.Client(), apiWithAuth("user", "pass", false))
+			u, err := url.Parse(srv.URL + tc.path)
+			if err != nil {
+				t.Fatalf("failed to parse url: %v", err)
+			}
+			err = a.Do(apiWithMethod("GET"), apiWithURL(u), apiExpectStatus(http.StatusOK))
+			if tc.expectErr && (err == nil || !strings.Contains(err.Error(), "invalid Warning header")) {
+				t.Errorf("expected a Warning header error, received %v", err)
+			} else if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			received := []string{}
+			for _, we := range a.warnings.Entries() {
+				received = append(received, we.String())
+			}
+			expect := `"valid"`
+			if tc.expectErr {
+				expect = `INVALID "199 registry \"invalid\"": warning must use a warn-code of 299: received 199,"valid"`
+			}
+			if strings.Join(received, ",") != expect {
+				t.Errorf("expected warnings %s, received %v", expect, received)
+			}
+		})
+	}
+}
@@ -1,3 +1,8 @@
 module github.com/opencontainers/distribution-spec/specs-go
 
 go 1.18
+
+require (
+	github.com/opencontainers/go-digest v1.0.0
+	github.com/opencontainers/image-spec v1.1.1
+)
@@ -0,0 +1,44 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema contains JSON Schemas for the responses defined by the spec,
+// along with a validator to verify a response matches the schema.
+package schema
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed *.json
+var schemaFS embed.FS
+
+// Validator is the file name of a JSON Schema used to validate a response.
+type Validator string
+
+const (
+	// ValidatorTagList validates the response from the tag list endpoint (end-8a, end-8b).
+	ValidatorTagList Validator = "tag-list-schema.json"
+	// ValidatorRepositoryList validates the response from the _catalog endpoint.
+	ValidatorRepositoryList Validator = "repository-list-schema.json"
+	// ValidatorErrorResponse validates the JSON body of a 4XX response.
+	ValidatorErrorResponse Validator = "error-response-schema.json"
+	// ValidatorExtensionList validates the response from the _oci/ext/discover endpoint.
+	ValidatorExtensionList Validator = "extension-list-schema.json"
+)
+
+// FileSystem returns the file system containing each JSON Schema.
+func FileSystem() fs.FS {
+	return schemaFS
+}
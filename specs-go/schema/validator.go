@@ -0,0 +1,242 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrSchemaUnknown is returned when the Validator is not one of the embedded schemas.
+var ErrSchemaUnknown = errors.New("schema is unknown")
+
+// Violation is a single failure to match the schema.
+type Violation struct {
+	// Path is a JSON Pointer (RFC 6901) to the value in the document, with an empty string for the root.
+	Path    string
+	Message string
+}
+
+// String returns the path and message of the violation.
+func (v Violation) String() string {
+	path := v.Path
+	if path == "" {
+		path = "/"
+	}
+	return path + ": " + v.Message
+}
+
+// ValidationError is returned when a document does not match the schema, listing every violation found.
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error implements the Error interface.
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validate reads a JSON document and verifies it matches the schema.
+// A ValidationError is returned when the document is valid JSON with violations.
+func (v Validator) Validate(src io.Reader) error {
+	s, err := v.schema()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("failed to parse JSON: unexpected data after the document")
+	}
+	violations := s.validate(doc, "")
+	if len(violations) > 0 {
+		return ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+var (
+	schemaMu    sync.Mutex
+	schemaCache = map[Validator]*jsonSchema{}
+)
+
+func (v Validator) schema() (*jsonSchema, error) {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+	if s, ok := schemaCache[v]; ok {
+		return s, nil
+	}
+	raw, err := schemaFS.ReadFile(string(v))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSchemaUnknown, string(v))
+	}
+	s := &jsonSchema{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s: %w", string(v), err)
+	}
+	if err := s.compile(s); err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %w", string(v), err)
+	}
+	schemaCache[v] = s
+	return s, nil
+}
+
+// jsonSchema implements the subset of JSON Schema draft-07 used by the embedded schemas.
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Ref         string                 `json:"$ref,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	Items       *jsonSchema            `json:"items,omitempty"`
+	Pattern     string                 `json:"pattern,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Definitions map[string]*jsonSchema `json:"definitions,omitempty"`
+
+	ref     *jsonSchema
+	pattern *regexp.Regexp
+}
+
+// compile resolves references to the definitions in the root schema and compiles each pattern.
+func (s *jsonSchema) compile(root *jsonSchema) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		ref, ok := root.Definitions[name]
+		if name == s.Ref || !ok {
+			return fmt.Errorf("unsupported reference %q", s.Ref)
+		}
+		s.ref = ref
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return err
+		}
+		s.pattern = re
+	}
+	if s.Format != "" && s.Format != "uri" {
+		return fmt.Errorf("unsupported format %q", s.Format)
+	}
+	for _, child := range s.children() {
+		if err := child.compile(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) children() []*jsonSchema {
+	ret := []*jsonSchema{}
+	for _, m := range []map[string]*jsonSchema{s.Properties, s.Definitions} {
+		for _, child := range m {
+			ret = append(ret, child)
+		}
+	}
+	if s.Items != nil {
+		ret = append(ret, s.Items)
+	}
+	return ret
+}
+
+// validate returns the violations for a value decoded with json.Number.
+func (s *jsonSchema) validate(value interface{}, path string) []Violation {
+	if s.ref != nil {
+		return s.ref.validate(value, path)
+	}
+	if s.Type != "" && jsonType(value) != s.Type && !(s.Type == "number" && jsonType(value) == "integer") {
+		return []Violation{{Path: path, Message: fmt.Sprintf("expected %s, received %s", s.Type, jsonType(value))}}
+	}
+	violations := []Violation{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				violations = append(violations, Violation{Path: path, Message: fmt.Sprintf("missing required property %q", name)})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				violations = append(violations, prop.validate(v[name], path+"/"+escapePointer(name))...)
+			}
+		}
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range v {
+				violations = append(violations, s.Items.validate(item, path+"/"+strconv.Itoa(i))...)
+			}
+		}
+	case string:
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			violations = append(violations, Violation{Path: path, Message: fmt.Sprintf("%q does not match pattern %s", v, s.Pattern)})
+		}
+		if s.Format == "uri" {
+			if u, err := url.Parse(v); err != nil || !u.IsAbs() {
+				violations = append(violations, Violation{Path: path, Message: fmt.Sprintf("%q is not an absolute URI", v)})
+			}
+		}
+	}
+	return violations
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func escapePointer(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,122 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schema
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		name      string
+		validator Validator
+		doc       string
+		// expectPaths lists the path of each violation, nil when the document is valid
+		expectPaths []string
+		expectErr   bool
+	}{
+		// tag list
+		{name: "tag list", validator: ValidatorTagList, doc: `{"name":"library/alpine","tags":["3.20","latest","sha256-abc.sig"]}`},
+		{name: "tag list empty", validator: ValidatorTagList, doc: `{"name":"repo","tags":[]}`},
+		{name: "tag list missing name", validator: ValidatorTagList, doc: `{"tags":["latest"]}`, expectPaths: []string{""}},
+		{name: "tag list null tags", validator: ValidatorTagList, doc: `{"name":"repo","tags":null}`, expectPaths: []string{"/tags"}},
+		{name: "tag list missing tags", validator: ValidatorTagList, doc: `{"name":"repo"}`, expectPaths: []string{""}},
+		{name: "tag list invalid name", validator: ValidatorTagList, doc: `{"name":"Repo","tags":[]}`, expectPaths: []string{"/name"}},
+		{name: "tag list invalid tags", validator: ValidatorTagList, doc: `{"name":"repo","tags":["ok",".bad","x",1]}`, expectPaths: []string{"/tags/1", "/tags/3"}},
+		{name: "tag list long tag", validator: ValidatorTagList, doc: `{"name":"repo","tags":["` + strings.Repeat("a", 129) + `"]}`, expectPaths: []string{"/tags/0"}},
+		{name: "tag list array", validator: ValidatorTagList, doc: `[]`, expectPaths: []string{""}},
+		// repository list
+		{name: "repository list", validator: ValidatorRepositoryList, doc: `{"repositories":["a","b/c","d__e/f-g"]}`},
+		{name: "repository list missing", validator: ValidatorRepositoryList, doc: `{}`, expectPaths: []string{""}},
+		{name: "repository list null", validator: ValidatorRepositoryList, doc: `{"repositories":null}`, expectPaths: []string{"/repositories"}},
+		{name: "repository list invalid", validator: ValidatorRepositoryList, doc: `{"repositories":["a","B","c//d"]}`, expectPaths: []string{"/repositories/1", "/repositories/2"}},
+		// error response
+		{name: "error response", validator: ValidatorErrorResponse, doc: `{"errors":[{"code":"BLOB_UNKNOWN","message":"blob unknown","detail":{"digest":"sha256:abc"}},{"code":"DENIED"}]}`},
+		{name: "error response detail types", validator: ValidatorErrorResponse, doc: `{"errors":[{"code":"UNSUPPORTED","detail":"text"},{"code":"UNSUPPORTED","detail":null},{"code":"UNSUPPORTED","detail":[1]}]}`},
+		{name: "error response lowercase code", validator: ValidatorErrorResponse, doc: `{"errors":[{"code":"blob_unknown"}]}`, expectPaths: []string{"/errors/0/code"}},
+		{name: "error response missing code", validator: ValidatorErrorResponse, doc: `{"errors":[{"code":"DENIED"},{"message":"denied"}]}`, expectPaths: []string{"/errors/1"}},
+		{name: "error response message type", validator: ValidatorErrorResponse, doc: `{"errors":[{"code":"DENIED","message":1}]}`, expectPaths: []string{"/errors/0/message"}},
+		{name: "error response missing errors", validator: ValidatorErrorResponse, doc: `{"error":"denied"}`, expectPaths: []string{""}},
+		{name: "error response errors object", validator: ValidatorErrorResponse, doc: `{"errors":{"code":"DENIED"}}`, expectPaths: []string{"/errors"}},
+		// extension list
+		{name: "extension list", validator: ValidatorExtensionList, doc: `{"extensions":[{"name":"_oci","url":"https://github.com/opencontainers/distribution-spec/blob/main/extensions/_oci.md","description":"discovery","endpoints":["_oci/ext/discover","/v2/_oci/ext/other"]}]}`},
+		{name: "extension list empty", validator: ValidatorExtensionList, doc: `{"extensions":[]}`},
+		{name: "extension missing endpoints", validator: ValidatorExtensionList, doc: `{"extensions":[{"name":"_oci","url":"https://example.com"}]}`, expectPaths: []string{"/extensions/0"}},
+		{name: "extension missing fields", validator: ValidatorExtensionList, doc: `{"extensions":[{}]}`, expectPaths: []string{"/extensions/0", "/extensions/0", "/extensions/0"}},
+		{name: "extension invalid fields", validator: ValidatorExtensionList, doc: `{"extensions":[{"name":"oci","url":"/docs","endpoints":["_oci/ext","_oci/ext/discover"]}]}`, expectPaths: []string{"/extensions/0/endpoints/0", "/extensions/0/name", "/extensions/0/url"}},
+		{name: "extension list null", validator: ValidatorExtensionList, doc: `{"extensions":null}`, expectPaths: []string{"/extensions"}},
+		// invalid documents
+		{name: "invalid json", validator: ValidatorTagList, doc: `{"name":"repo",`, expectErr: true},
+		{name: "trailing data", validator: ValidatorTagList, doc: `{"name":"repo","tags":[]} {}`, expectErr: true},
+		{name: "unknown schema", validator: Validator("unknown.json"), doc: `{}`, expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.validator.Validate(strings.NewReader(tc.doc))
+			ve := ValidationError{}
+			switch {
+			case tc.expectErr:
+				if err == nil || errors.As(err, &ve) {
+					t.Errorf("expected a parse error, received %v", err)
+				}
+			case tc.expectPaths == nil:
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			default:
+				if !errors.As(err, &ve) {
+					t.Fatalf("expected a ValidationError, received %v", err)
+				}
+				paths := make([]string, len(ve.Violations))
+				for i, v := range ve.Violations {
+					paths[i] = v.Path
+				}
+				if strings.Join(paths, ",") != strings.Join(tc.expectPaths, ",") {
+					t.Errorf("expected violations at %v, received %v", tc.expectPaths, ve.Violations)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	err := ValidatorErrorResponse.Validate(strings.NewReader(`{"errors":[{"code":"denied"}]}`))
+	expect := `schema validation failed: /errors/0/code: "denied" does not match pattern ^[A-Z_]+$`
+	if err == nil || err.Error() != expect {
+		t.Errorf("expected %s, received %v", expect, err)
+	}
+	err = ValidatorTagList.Validate(strings.NewReader(`{"tags":[]}`))
+	expect = `schema validation failed: /: missing required property "name"`
+	if err == nil || err.Error() != expect {
+		t.Errorf("expected %s, received %v", expect, err)
+	}
+	if err := Validator("unknown.json").Validate(strings.NewReader(`{}`)); !errors.Is(err, ErrSchemaUnknown) {
+		t.Errorf("expected error %v, received %v", ErrSchemaUnknown, err)
+	}
+}
+
+func TestFileSystem(t *testing.T) {
+	for _, v := range []Validator{ValidatorTagList, ValidatorRepositoryList, ValidatorErrorResponse, ValidatorExtensionList} {
+		if _, err := fs.Stat(FileSystem(), string(v)); err != nil {
+			t.Errorf("schema %s is missing: %v", v, err)
+		}
+		if _, err := v.schema(); err != nil {
+			t.Errorf("schema %s failed to compile: %v", v, err)
+		}
+	}
+}
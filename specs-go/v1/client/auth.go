@@ -0,0 +1,294 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// Authenticator adds credentials to requests and handles the challenge in a 401 response.
+type Authenticator interface {
+	// Authorize adds any known credentials to the request.
+	Authorize(req *http.Request) error
+	// Challenge processes the WWW-Authenticate header of a 401 response,
+	// returning true when the request should be sent again.
+	// The doer is used for any requests to a token server.
+	Challenge(ctx context.Context, doer v1.Doer, resp *http.Response) (bool, error)
+}
+
+// Challenge is a single challenge from a WWW-Authenticate header, defined in RFC 7235 (section 4.1).
+type Challenge struct {
+	// Scheme is the lowercase auth scheme, e.g. "basic" or "bearer".
+	Scheme string
+	// Params contains the auth params, with lowercase names.
+	Params map[string]string
+}
+
+// ParseChallenges parses the challenges in WWW-Authenticate header values.
+// A token68 value, e.g. "Negotiate abc==", is skipped and the challenge is returned without params.
+func ParseChallenges(values ...string) ([]Challenge, error) {
+	cs := []Challenge{}
+	for _, value := range values {
+		p := challengeParser{s: value}
+		for {
+			p.skip(" \t,")
+			if p.done() {
+				break
+			}
+			scheme := p.token()
+			if scheme == "" {
+				return nil, fmt.Errorf("failed to parse WWW-Authenticate header %q at offset %d", value, p.i)
+			}
+			c := Challenge{Scheme: strings.ToLower(scheme), Params: map[string]string{}}
+			p.skipToken68()
+			// parse auth-params until the next scheme, each param is a token followed by "="
+			for {
+				p.skip(" \t,")
+				start := p.i
+				name := p.token()
+				p.skip(" \t")
+				if name == "" || p.done() || p.s[p.i] != '=' {
+					p.i = start
+					break
+				}
+				p.i++
+				p.skip(" \t")
+				val, err := p.value()
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse WWW-Authenticate header %q: %w", value, err)
+				}
+				c.Params[strings.ToLower(name)] = val
+			}
+			cs = append(cs, c)
+		}
+	}
+	return cs, nil
+}
+
+// CredentialAuth handles basic and bearer token challenges with a username and password.
+// Credentials are cached for each host, repository, and action, and reused on later requests.
+type CredentialAuth struct {
+	user, pass string
+	mu         sync.Mutex
+	cache      map[string]string
+}
+
+// NewCredentialAuth returns an Authenticator for the credentials.
+// Empty credentials request anonymous bearer tokens.
+func NewCredentialAuth(user, pass string) *CredentialAuth {
+	return &CredentialAuth{
+		user:  user,
+		pass:  pass,
+		cache: map[string]string{},
+	}
+}
+
+// Authorize adds a cached Authorization header to the request.
+func (ca *CredentialAuth) Authorize(req *http.Request) error {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if auth, ok := ca.cache[authCacheKey(req)]; ok {
+		req.Header.Set("Authorization", auth)
+	}
+	return nil
+}
+
+// Challenge requests a token for a bearer challenge, or uses the credentials for a basic challenge.
+func (ca *CredentialAuth) Challenge(ctx context.Context, doer v1.Doer, resp *http.Response) (bool, error) {
+	cs, err := ParseChallenges(resp.Header.Values("WWW-Authenticate")...)
+	if err != nil {
+		return false, err
+	}
+	key := authCacheKey(resp.Request)
+	for _, c := range cs {
+		var auth string
+		switch c.Scheme {
+		case "basic":
+			if ca.user == "" && ca.pass == "" {
+				continue
+			}
+			auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(ca.user+":"+ca.pass))
+		case "bearer":
+			auth, err = ca.token(ctx, doer, resp.Request.URL, c)
+			if err != nil {
+				return false, err
+			}
+		default:
+			continue
+		}
+		ca.mu.Lock()
+		ca.cache[key] = auth
+		ca.mu.Unlock()
+		return true, nil
+	}
+	return false, nil
+}
+
+// token requests a bearer token from the realm in the challenge.
+func (ca *CredentialAuth) token(ctx context.Context, doer v1.Doer, reqURL *url.URL, c Challenge) (string, error) {
+	realm := c.Params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge is missing the realm")
+	}
+	u, err := reqURL.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse realm url: %w", err)
+	}
+	q := u.Query()
+	if service := c.Params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	if scope := c.Params["scope"]; scope != "" {
+		q.Set("scope", scope)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if ca.user != "" || ca.pass != "" {
+		req.SetBasicAuth(ca.user, ca.pass)
+	}
+	resp, err := doer.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to send auth request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("invalid status on auth request: %s", resp.Status)
+	}
+	ti := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&ti); err != nil {
+		return "", fmt.Errorf("failed to parse auth response: %w", err)
+	}
+	if ti.AccessToken != "" {
+		ti.Token = ti.AccessToken
+	}
+	if ti.Token == "" {
+		return "", fmt.Errorf("auth response did not include a token")
+	}
+	return "Bearer " + ti.Token, nil
+}
+
+// authCacheKey returns the host, repository, and action of the request, joined with a colon separator.
+// Requests that do not match an endpoint, such as the ping, use an empty repository.
+func authCacheKey(req *http.Request) string {
+	repo := ""
+	if m, err := v1.MatchEndpoint(req); err == nil {
+		repo = m.Name
+	}
+	// compatible methods are merged
+	action := req.Method
+	switch action {
+	case http.MethodGet, http.MethodHead:
+		action = "pull"
+	case http.MethodPost, http.MethodPatch, http.MethodPut:
+		action = "push"
+	}
+	return strings.Join([]string{req.URL.Host, repo, action}, ":")
+}
+
+type challengeParser struct {
+	s string
+	i int
+}
+
+func (p *challengeParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *challengeParser) skip(chars string) {
+	for !p.done() && strings.IndexByte(chars, p.s[p.i]) >= 0 {
+		p.i++
+	}
+}
+
+// skipToken68 skips a token68 value following the scheme, leaving the position unchanged for auth-params.
+// A token68 is only followed by the end of the value or a comma, while an auth-param has a value after the "=".
+func (p *challengeParser) skipToken68() {
+	start := p.i
+	p.skip(" \t")
+	if p.i == start {
+		return
+	}
+	t68 := p.i
+	for !p.done() && isToken68Char(p.s[p.i]) {
+		p.i++
+	}
+	if p.i == t68 {
+		p.i = start
+		return
+	}
+	p.skip("=")
+	p.skip(" \t")
+	if !p.done() && p.s[p.i] != ',' {
+		p.i = start
+	}
+}
+
+func (p *challengeParser) token() string {
+	start := p.i
+	for !p.done() && isTokenChar(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func (p *challengeParser) value() (string, error) {
+	if p.done() || p.s[p.i] != '"' {
+		return p.token(), nil
+	}
+	p.i++
+	var sb strings.Builder
+	for !p.done() {
+		c := p.s[p.i]
+		p.i++
+		switch c {
+		case '"':
+			return sb.String(), nil
+		case '\\':
+			if p.done() {
+				return "", fmt.Errorf("unterminated quoted-pair")
+			}
+			sb.WriteByte(p.s[p.i])
+			p.i++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted string")
+}
+
+func isTokenChar(c byte) bool {
+	return c > 0x20 && c < 0x7f && !strings.ContainsRune("()<>@,;:\\\"/[]?={}", rune(c))
+}
+
+func isToken68Char(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte("-._~+/", c) >= 0
+}
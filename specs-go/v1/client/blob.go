@@ -0,0 +1,309 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// BlobHead returns the descriptor of a blob in the repository (end-2 with HEAD).
+// A missing blob returns a *v1.ResponseError with a 404 status.
+func (c *Client) BlobHead(ctx context.Context, repo string, dig digest.Digest) (image.Descriptor, error) {
+	resp, err := c.send(ctx, http.MethodHead, c.urls.Blob(repo, dig.String()), nil, nil)
+	if err != nil {
+		return image.Descriptor{}, err
+	}
+	defer resp.Body.Close()
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return image.Descriptor{}, err
+	}
+	if h := resp.Header.Get(v1.ContentDigestHeader); h != "" && h != dig.String() {
+		return image.Descriptor{}, fmt.Errorf("%w: %s header %q, expected %s", ErrDigestMismatch, v1.ContentDigestHeader, h, dig)
+	}
+	return image.Descriptor{
+		MediaType: v1.MediaTypeOctetStream,
+		Digest:    dig,
+		Size:      resp.ContentLength,
+	}, nil
+}
+
+// BlobGet returns a reader for a blob in the repository (end-2).
+// The reader returns ErrDigestMismatch at the end of the blob when the content does not match the digest,
+// and must be closed by the caller.
+func (c *Client) BlobGet(ctx context.Context, repo string, dig digest.Digest) (io.ReadCloser, error) {
+	if err := dig.Validate(); err != nil {
+		return nil, err
+	}
+	resp, err := c.send(ctx, http.MethodGet, c.urls.Blob(repo, dig.String()), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return &verifyReader{
+		rc:       resp.Body,
+		verifier: dig.Verifier(),
+		dig:      dig,
+		size:     resp.ContentLength,
+	}, nil
+}
+
+// BlobPush uploads a blob with a POST and single PUT (end-4a and end-6).
+// The desc must include the digest and size, and r must return exactly size bytes.
+func (c *Client) BlobPush(ctx context.Context, repo string, desc image.Descriptor, r io.Reader) error {
+	s, err := c.blobUploadStart(ctx, repo)
+	if err != nil {
+		return err
+	}
+	req, err := s.NewFinishRequest(ctx, desc.Digest.String(), r, desc.Size)
+	if err != nil {
+		return err
+	}
+	// a monolithic PUT is not a chunk and does not include a Content-Range
+	req.Header.Del("Content-Range")
+	return c.blobUploadFinish(s, req, desc.Digest)
+}
+
+// BlobPushChunked uploads a blob in a series of PATCH requests (end-4a, end-5, and end-6).
+// Each chunk is buffered, allowing a chunk rejected with a 416 to be resumed from the offset reported by the registry (end-13).
+// An error is returned before any chunk is sent when the chunk size is larger than the buffer limit.
+func (c *Client) BlobPushChunked(ctx context.Context, repo string, desc image.Descriptor, r io.Reader) error {
+	s, err := c.blobUploadStart(ctx, repo)
+	if err != nil {
+		return err
+	}
+	size := s.ChunkSize(c.chunkSize)
+	if size <= 0 || size > maxChunkSize {
+		c.blobUploadCancel(s)
+		return fmt.Errorf("chunk size %d must be between 1 and %d bytes", size, maxChunkSize)
+	}
+	buf := make([]byte, size)
+	for {
+		n, errRead := io.ReadFull(r, buf)
+		if errRead != nil && !errors.Is(errRead, io.EOF) && !errors.Is(errRead, io.ErrUnexpectedEOF) {
+			c.blobUploadCancel(s)
+			return errRead
+		}
+		if n == 0 {
+			break
+		}
+		if err := c.blobUploadChunk(ctx, s, buf[:n]); err != nil {
+			c.blobUploadCancel(s)
+			return err
+		}
+		if errRead != nil {
+			break
+		}
+	}
+	if desc.Size > 0 && s.Offset != desc.Size {
+		c.blobUploadCancel(s)
+		return fmt.Errorf("uploaded %d bytes, expected %d", s.Offset, desc.Size)
+	}
+	req, err := s.NewFinishRequest(ctx, desc.Digest.String(), nil, 0)
+	if err != nil {
+		return err
+	}
+	return c.blobUploadFinish(s, req, desc.Digest)
+}
+
+// BlobPushStream uploads a blob with a single streamed PATCH request (end-4a, end-5, and end-6).
+// The size in desc is optional, and is verified against the upload when set.
+func (c *Client) BlobPushStream(ctx context.Context, repo string, desc image.Descriptor, r io.Reader) error {
+	s, err := c.blobUploadStart(ctx, repo)
+	if err != nil {
+		return err
+	}
+	req, err := s.NewChunkRequest(ctx, r, -1)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	err = s.HandleChunkResponse(resp, -1)
+	drainClose(resp.Body)
+	if err != nil {
+		c.blobUploadCancel(s)
+		return err
+	}
+	if desc.Size > 0 && s.Offset != desc.Size {
+		c.blobUploadCancel(s)
+		return fmt.Errorf("uploaded %d bytes, expected %d", s.Offset, desc.Size)
+	}
+	req, err = s.NewFinishRequest(ctx, desc.Digest.String(), nil, 0)
+	if err != nil {
+		return err
+	}
+	return c.blobUploadFinish(s, req, desc.Digest)
+}
+
+// BlobMount requests the registry mount a blob from another repository (end-11).
+// The from repository is omitted when empty, allowing the registry to find the blob.
+// It returns false when the registry did not mount the blob, and the blob should be pushed.
+func (c *Client) BlobMount(ctx context.Context, repo, from string, dig digest.Digest) (bool, error) {
+	resp, err := c.send(ctx, http.MethodPost, c.urls.BlobMount(repo, dig.String(), from), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer drainClose(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil
+	case http.StatusAccepted:
+		// the registry opened an upload session instead, which is not needed
+		if s, err := v1.NewUploadSession(resp); err == nil {
+			c.blobUploadCancel(s)
+		}
+		return false, nil
+	}
+	return false, expectStatus(resp, http.StatusCreated, http.StatusAccepted)
+}
+
+// BlobDelete deletes a blob from the repository (end-10).
+func (c *Client) BlobDelete(ctx context.Context, repo string, dig digest.Digest) error {
+	resp, err := c.send(ctx, http.MethodDelete, c.urls.Blob(repo, dig.String()), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	return expectStatus(resp, http.StatusAccepted)
+}
+
+func (c *Client) blobUploadStart(ctx context.Context, repo string) (*v1.UploadSession, error) {
+	req, err := newRequest(ctx, http.MethodPost, c.urls.BlobUpload(repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = 0
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer drainClose(resp.Body)
+	return v1.NewUploadSession(resp)
+}
+
+// blobUploadChunk sends a buffered chunk, resuming from the registry's offset after a 416.
+func (c *Client) blobUploadChunk(ctx context.Context, s *v1.UploadSession, chunk []byte) error {
+	start := s.Offset
+	for {
+		rest := chunk[s.Offset-start:]
+		req, err := s.NewChunkRequest(ctx, bytes.NewReader(rest), int64(len(rest)))
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		err = s.HandleChunkResponse(resp, int64(len(rest)))
+		drainClose(resp.Body)
+		if !errors.Is(err, v1.ErrUploadRangeNotSatisfiable) {
+			return err
+		}
+		prev := s.Offset
+		if err := c.blobUploadStatus(ctx, s); err != nil {
+			return err
+		}
+		if s.Offset <= prev || s.Offset > start+int64(len(chunk)) {
+			return fmt.Errorf("cannot resume upload, registry offset is %d: %w", s.Offset, v1.ErrUploadRangeNotSatisfiable)
+		}
+		if s.Offset == start+int64(len(chunk)) {
+			return nil
+		}
+	}
+}
+
+func (c *Client) blobUploadStatus(ctx context.Context, s *v1.UploadSession) error {
+	req, err := s.NewStatusRequest(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	return s.HandleStatusResponse(resp)
+}
+
+func (c *Client) blobUploadFinish(s *v1.UploadSession, req *http.Request, dig digest.Digest) error {
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	if _, err := s.HandleFinishResponse(resp, dig.String()); err != nil {
+		c.blobUploadCancel(s)
+		return err
+	}
+	return nil
+}
+
+// blobUploadCancel closes an upload session, ignoring any errors.
+func (c *Client) blobUploadCancel(s *v1.UploadSession) {
+	req, err := s.NewCancelRequest(context.Background())
+	if err != nil {
+		return
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return
+	}
+	drainClose(resp.Body)
+}
+
+// verifyReader verifies the content against the digest and size when the end of the body is reached.
+type verifyReader struct {
+	rc       io.ReadCloser
+	verifier digest.Verifier
+	dig      digest.Digest
+	size     int64
+	read     int64
+}
+
+func (vr *verifyReader) Read(p []byte) (int, error) {
+	n, err := vr.rc.Read(p)
+	if n > 0 {
+		_, _ = vr.verifier.Write(p[:n])
+		vr.read += int64(n)
+	}
+	if errors.Is(err, io.EOF) {
+		if vr.size >= 0 && vr.read != vr.size {
+			return n, fmt.Errorf("read %d bytes, expected %d: %w", vr.read, vr.size, io.ErrUnexpectedEOF)
+		}
+		if !vr.verifier.Verified() {
+			return n, fmt.Errorf("%w: %s", ErrDigestMismatch, vr.dig)
+		}
+	}
+	return n, err
+}
+
+func (vr *verifyReader) Close() error {
+	return vr.rc.Close()
+}
@@ -0,0 +1,202 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client is a reference client for the distribution API.
+// Each method maps to one or more of the endpoints in the spec,
+// validating responses with the helpers from the v1 package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+const (
+	// defaultChunkSize is the preferred chunk size for chunked blob uploads.
+	defaultChunkSize = 8 << 20
+	// maxChunkSize limits the buffer allocated for each chunk,
+	// including a larger size requested by the registry with the OCI-Chunk-Min-Length header.
+	maxChunkSize = 256 << 20
+)
+
+// ErrDigestMismatch is returned when pulled content does not match the requested digest.
+var ErrDigestMismatch = errors.New("content does not match the digest")
+
+// Client sends requests to a single registry.
+// It is safe for concurrent use when the Doer and Authenticator are.
+type Client struct {
+	urls      *v1.URLBuilder
+	doer      v1.Doer
+	auth      Authenticator
+	chunkSize int64
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the Doer used to send requests, which defaults to http.DefaultClient.
+func WithHTTPClient(doer v1.Doer) Option {
+	return func(c *Client) {
+		c.doer = doer
+	}
+}
+
+// WithAuth sets the Authenticator used to add credentials to requests.
+func WithAuth(auth Authenticator) Option {
+	return func(c *Client) {
+		c.auth = auth
+	}
+}
+
+// WithChunkSize sets the preferred chunk size for BlobPushChunked.
+// The registry may increase the size with the OCI-Chunk-Min-Length header.
+// BlobPushChunked returns an error when the resulting size is not positive or exceeds 256MiB.
+func WithChunkSize(size int64) Option {
+	return func(c *Client) {
+		c.chunkSize = size
+	}
+}
+
+// New returns a Client for the registry at the base URL, e.g. "https://registry.example.org".
+func New(registry string, opts ...Option) (*Client, error) {
+	urls, err := v1.NewURLBuilder(registry)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		urls:      urls,
+		doer:      http.DefaultClient,
+		chunkSize: defaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Do sends the request with credentials from the Authenticator.
+// When the registry responds with a 401 and the Authenticator accepts the challenge,
+// the request is sent again, which requires a nil body or a GetBody function on the request.
+// Do implements v1.Doer, so a Client may be passed to the iterators in the v1 package.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	if c.auth == nil {
+		return c.doer.Do(req)
+	}
+	if err := c.auth.Authorize(req); err != nil {
+		return nil, err
+	}
+	resp, err := c.doer.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	retry, err := c.auth.Challenge(req.Context(), c.doer, resp)
+	if err != nil || !retry {
+		return resp, err
+	}
+	_ = resp.Body.Close()
+	if req.GetBody != nil {
+		req.Body, err = req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset body after auth request: %w", err)
+		}
+	}
+	if err := c.auth.Authorize(req); err != nil {
+		return nil, err
+	}
+	return c.doer.Do(req)
+}
+
+// Ping verifies the registry implements the distribution API (end-1).
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.send(ctx, http.MethodGet, c.urls.Ping(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return expectStatus(resp, http.StatusOK)
+}
+
+// TagIterator returns an iterator over the tags in the repository (end-8a and end-8b).
+// When n is positive, it is sent as the page size.
+func (c *Client) TagIterator(repo string, n int) (*v1.TagIterator, error) {
+	return v1.NewTagIterator(c, c.urls.TagList(repo, -1, ""), n)
+}
+
+// Tags returns every tag in the repository.
+func (c *Client) Tags(ctx context.Context, repo string) ([]string, error) {
+	ti, err := c.TagIterator(repo, 0)
+	if err != nil {
+		return nil, err
+	}
+	return ti.All(ctx)
+}
+
+// send creates and sends a request, applying the optional header.
+func (c *Client) send(ctx context.Context, method, u string, header http.Header, body []byte) (*http.Response, error) {
+	req, err := newRequest(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range header {
+		req.Header[k] = append([]string{}, vals...)
+	}
+	return c.Do(req)
+}
+
+// newRequest creates a request with a body that can be replayed after an auth challenge.
+func newRequest(ctx context.Context, method, u string, body []byte) (*http.Request, error) {
+	if body == nil {
+		return http.NewRequestWithContext(ctx, method, u, nil)
+	}
+	// a *bytes.Reader body sets GetBody and Content-Length
+	return http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+}
+
+// expectStatus returns nil when the response has one of the status codes,
+// and otherwise returns the error from the response.
+func expectStatus(resp *http.Response, statusCodes ...int) error {
+	for _, s := range statusCodes {
+		if resp.StatusCode == s {
+			return nil
+		}
+	}
+	if err := v1.ParseErrorResponse(resp); err != nil {
+		return err
+	}
+	if resp.Request == nil || resp.Request.URL == nil {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return fmt.Errorf("unexpected status %s from %s %s", resp.Status, resp.Request.Method, resp.Request.URL.Redacted())
+}
+
+// isNotFound returns true for a 404 response.
+func isNotFound(err error) bool {
+	re := &v1.ResponseError{}
+	return errors.As(err, &re) && re.StatusCode == http.StatusNotFound
+}
+
+// drainClose discards the remaining body so the connection may be reused.
+func drainClose(rc io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(rc, 64<<10))
+	_ = rc.Close()
+}
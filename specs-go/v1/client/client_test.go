@@ -0,0 +1,492 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// fakeRegistry is a minimal registry for a single repository, with options to inject failures.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	manifests map[string]fakeManifest
+	blobs     map[digest.Digest][]byte
+	uploads   map[string][]byte
+	nextID    int
+	// referrers enables the referrers API and the OCI-Subject header.
+	referrers bool
+	// chunkMinLength is returned in the OCI-Chunk-Min-Length header when set.
+	chunkMinLength int64
+	// truncateChunk is the number of the PATCH request that only stores half the chunk and responds with a 416.
+	truncateChunk int
+	chunks        int
+	// token enables bearer auth, with the user and pass required by the token server.
+	token, user, pass string
+	tokenRequests     []*http.Request
+}
+
+type fakeManifest struct {
+	mediaType string
+	raw       []byte
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		manifests: map[string]fakeManifest{},
+		blobs:     map[digest.Digest][]byte{},
+		uploads:   map[string][]byte{},
+	}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path == "/token" {
+		f.tokenRequests = append(f.tokenRequests, r)
+		if user, pass, _ := r.BasicAuth(); user != f.user || pass != f.pass {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"token":%q}`, f.token)
+		return
+	}
+	m, err := v1.MatchEndpoint(r)
+	if f.token != "" && r.Header.Get("Authorization") != "Bearer "+f.token {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="fake",scope="repository:%s:pull"`, r.Host, m.Name))
+		fakeError(w, http.StatusUnauthorized, v1.ErrUnauthorized)
+		return
+	}
+	if err != nil {
+		fakeError(w, http.StatusNotFound, v1.ErrUnsupported)
+		return
+	}
+	switch m.ID {
+	case v1.EndpointPing:
+		w.WriteHeader(http.StatusOK)
+	case v1.EndpointBlobGet:
+		blob, ok := f.blobs[digest.Digest(m.Digest)]
+		if !ok {
+			fakeError(w, http.StatusNotFound, v1.ErrBlobUnknown)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(blob)
+		}
+	case v1.EndpointBlobUploadStart:
+		f.nextID++
+		id := strconv.Itoa(f.nextID)
+		f.uploads[id] = []byte{}
+		if f.chunkMinLength > 0 {
+			w.Header().Set(v1.ChunkMinLengthHeader, strconv.FormatInt(f.chunkMinLength, 10))
+		}
+		f.uploadStatus(w, m.Name, id, http.StatusAccepted)
+	case v1.EndpointBlobUploadChunk:
+		buf, ok := f.uploads[m.SessionID]
+		if !ok {
+			fakeError(w, http.StatusNotFound, v1.ErrBlobUploadUnknown)
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		start, _, _ := strings.Cut(r.Header.Get("Content-Range"), "-")
+		if start != strconv.Itoa(len(buf)) {
+			f.uploadStatus(w, m.Name, m.SessionID, http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		f.chunks++
+		if f.chunks == f.truncateChunk {
+			f.uploads[m.SessionID] = append(buf, chunk[:len(chunk)/2]...)
+			f.uploadStatus(w, m.Name, m.SessionID, http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		f.uploads[m.SessionID] = append(buf, chunk...)
+		f.uploadStatus(w, m.Name, m.SessionID, http.StatusAccepted)
+	case v1.EndpointBlobUploadStatus:
+		if _, ok := f.uploads[m.SessionID]; !ok {
+			fakeError(w, http.StatusNotFound, v1.ErrBlobUploadUnknown)
+			return
+		}
+		f.uploadStatus(w, m.Name, m.SessionID, http.StatusNoContent)
+	case v1.EndpointBlobUploadFinish:
+		buf, ok := f.uploads[m.SessionID]
+		if !ok {
+			fakeError(w, http.StatusNotFound, v1.ErrBlobUploadUnknown)
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		buf = append(buf, chunk...)
+		if digest.FromBytes(buf).String() != m.Digest {
+			fakeError(w, http.StatusBadRequest, v1.ErrDigestInvalid)
+			return
+		}
+		delete(f.uploads, m.SessionID)
+		f.blobs[digest.Digest(m.Digest)] = buf
+		w.Header().Set("Location", "/v2/"+m.Name+"/blobs/"+m.Digest)
+		w.Header().Set(v1.ContentDigestHeader, m.Digest)
+		w.WriteHeader(http.StatusCreated)
+	case v1.EndpointBlobUploadCancel:
+		delete(f.uploads, m.SessionID)
+		w.WriteHeader(http.StatusNoContent)
+	case v1.EndpointManifestGet:
+		mf, ok := f.manifests[m.Reference]
+		if !ok {
+			fakeError(w, http.StatusNotFound, v1.ErrManifestUnknown)
+			return
+		}
+		w.Header().Set("Content-Type", mf.mediaType)
+		w.Header().Set(v1.ContentDigestHeader, digest.FromBytes(mf.raw).String())
+		w.Header().Set("Content-Length", strconv.Itoa(len(mf.raw)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(mf.raw)
+		}
+	case v1.EndpointManifestPut:
+		raw, _ := io.ReadAll(r.Body)
+		mf := fakeManifest{mediaType: r.Header.Get("Content-Type"), raw: raw}
+		dig := digest.FromBytes(raw)
+		f.manifests[m.Reference] = mf
+		f.manifests[dig.String()] = mf
+		if _, subject, err := v1.ReferrerDescriptor(mf.mediaType, raw); err == nil && f.referrers {
+			w.Header().Set(v1.SubjectHeader, subject.Digest.String())
+		}
+		w.Header().Set("Location", "/v2/"+m.Name+"/manifests/"+dig.String())
+		w.Header().Set(v1.ContentDigestHeader, dig.String())
+		w.WriteHeader(http.StatusCreated)
+	case v1.EndpointManifestDelete:
+		mf, ok := f.manifests[m.Reference]
+		if !ok {
+			fakeError(w, http.StatusNotFound, v1.ErrManifestUnknown)
+			return
+		}
+		for ref, cur := range f.manifests {
+			if bytes.Equal(cur.raw, mf.raw) {
+				delete(f.manifests, ref)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case v1.EndpointReferrers, v1.EndpointReferrersFiltered:
+		if !f.referrers {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		raw, _ := json.Marshal(v1.NewReferrersIndex())
+		w.Header().Set("Content-Type", image.MediaTypeImageIndex)
+		_, _ = w.Write(raw)
+	default:
+		fakeError(w, http.StatusMethodNotAllowed, v1.ErrUnsupported)
+	}
+}
+
+// uploadStatus responds with the location and range of an upload session.
+func (f *fakeRegistry) uploadStatus(w http.ResponseWriter, name, id string, status int) {
+	end := len(f.uploads[id]) - 1
+	if end < 0 {
+		end = 0
+	}
+	w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+	w.Header().Set("Range", "0-"+strconv.Itoa(end))
+	w.WriteHeader(status)
+}
+
+func fakeError(w http.ResponseWriter, status int, code v1.ErrorCode) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `{"errors":[{"code":%q,"message":"fake error"}]}`, code)
+}
+
+func newFakeClient(t *testing.T, f *fakeRegistry, opts ...Option) *Client {
+	t.Helper()
+	s := httptest.NewServer(f)
+	t.Cleanup(s.Close)
+	c, err := New(s.URL, append([]Option{WithHTTPClient(s.Client())}, opts...)...)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	return c
+}
+
+func TestParseChallenges(t *testing.T) {
+	tt := []struct {
+		name      string
+		values    []string
+		expect    []Challenge
+		expectErr bool
+	}{
+		{
+			name:   "bearer",
+			values: []string{`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:repo:pull,push"`},
+			expect: []Challenge{{Scheme: "bearer", Params: map[string]string{"realm": "https://auth.example.com/token", "service": "registry.example.com", "scope": "repository:repo:pull,push"}}},
+		},
+		{
+			name:   "multiple challenges",
+			values: []string{`Basic realm="basic", Bearer realm=token`, `Newauth`},
+			expect: []Challenge{
+				{Scheme: "basic", Params: map[string]string{"realm": "basic"}},
+				{Scheme: "bearer", Params: map[string]string{"realm": "token"}},
+				{Scheme: "newauth", Params: map[string]string{}},
+			},
+		},
+		{
+			name:   "quoted pair and spaces",
+			values: []string{`Bearer  Realm = "a \"b\"" , error=invalid_token`},
+			expect: []Challenge{{Scheme: "bearer", Params: map[string]string{"realm": `a "b"`, "error": "invalid_token"}}},
+		},
+		{
+			name:   "token68",
+			values: []string{`Basic abc==`, `Negotiate a+b/c~, Bearer realm="token"`},
+			expect: []Challenge{
+				{Scheme: "basic", Params: map[string]string{}},
+				{Scheme: "negotiate", Params: map[string]string{}},
+				{Scheme: "bearer", Params: map[string]string{"realm": "token"}},
+			},
+		},
+		{
+			name:      "unterminated",
+			values:    []string{`Bearer realm="token`},
+			expectErr: true,
+		},
+		{
+			name:      "invalid scheme",
+			values:    []string{`"Bearer"`},
+			expectErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			cs, err := ParseChallenges(tc.values...)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, received %v", cs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprint(cs) != fmt.Sprint(tc.expect) {
+				t.Errorf("expected %v, received %v", tc.expect, cs)
+			}
+		})
+	}
+}
+
+func TestClientToken(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeRegistry()
+	f.token, f.user, f.pass = "secret-token", "user", "pass"
+	c := newFakeClient(t, f, WithAuth(NewCredentialAuth("user", "pass")))
+	for i := 0; i < 2; i++ {
+		if _, err := c.ManifestHead(ctx, "repo", "latest"); !isNotFound(err) {
+			t.Fatalf("expected a 404 after auth, received %v", err)
+		}
+	}
+	if len(f.tokenRequests) != 1 {
+		t.Fatalf("expected 1 token request, received %d", len(f.tokenRequests))
+	}
+	q := f.tokenRequests[0].URL.Query()
+	if q.Get("service") != "fake" || q.Get("scope") != "repository:repo:pull" {
+		t.Errorf("unexpected token request query %v", q)
+	}
+	// push requests have a separate token
+	if _, err := c.ManifestPut(ctx, "repo", "latest", image.MediaTypeImageManifest, []byte(`{}`)); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	if len(f.tokenRequests) != 2 {
+		t.Errorf("expected 2 token requests, received %d", len(f.tokenRequests))
+	}
+
+	// invalid credentials return the error from the token server
+	c = newFakeClient(t, f, WithAuth(NewCredentialAuth("user", "wrong")))
+	if _, err := c.ManifestHead(ctx, "repo", "latest"); err == nil || !strings.Contains(err.Error(), "auth request") {
+		t.Errorf("expected a token request error, received %v", err)
+	}
+	// no auth returns the 401
+	c = newFakeClient(t, f)
+	re := &v1.ResponseError{}
+	if _, err := c.ManifestHead(ctx, "repo", "latest"); !errors.As(err, &re) || re.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected a 401, received %v", err)
+	}
+}
+
+func TestBlobPushChunked(t *testing.T) {
+	ctx := context.Background()
+	blob := []byte("0123456789abcdefghij")
+	desc := image.Descriptor{MediaType: v1.MediaTypeOctetStream, Digest: digest.FromBytes(blob), Size: int64(len(blob))}
+
+	t.Run("resume after 416", func(t *testing.T) {
+		f := newFakeRegistry()
+		f.truncateChunk = 2
+		c := newFakeClient(t, f, WithChunkSize(8))
+		if err := c.BlobPushChunked(ctx, "repo", desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push: %v", err)
+		}
+		if !bytes.Equal(f.blobs[desc.Digest], blob) {
+			t.Errorf("expected %s, received %s", blob, f.blobs[desc.Digest])
+		}
+		// 3 chunks of 8 bytes plus the rest of the truncated chunk
+		if f.chunks != 4 {
+			t.Errorf("expected 4 chunks, received %d", f.chunks)
+		}
+	})
+	t.Run("chunk min length", func(t *testing.T) {
+		f := newFakeRegistry()
+		f.chunkMinLength = 16
+		c := newFakeClient(t, f, WithChunkSize(8))
+		if err := c.BlobPushChunked(ctx, "repo", desc, bytes.NewReader(blob)); err != nil {
+			t.Fatalf("failed to push: %v", err)
+		}
+		if f.chunks != 2 {
+			t.Errorf("expected 2 chunks, received %d", f.chunks)
+		}
+	})
+	t.Run("chunk size limit", func(t *testing.T) {
+		f := newFakeRegistry()
+		f.chunkMinLength = maxChunkSize + 1
+		c := newFakeClient(t, f)
+		if err := c.BlobPushChunked(ctx, "repo", desc, bytes.NewReader(blob)); err == nil {
+			t.Errorf("expected an error for a chunk size over the limit")
+		}
+		if f.chunks != 0 || len(f.uploads) != 0 {
+			t.Errorf("expected no chunks and the session cancelled, received %d chunks and %d sessions", f.chunks, len(f.uploads))
+		}
+	})
+}
+
+func TestBlobGet(t *testing.T) {
+	ctx := context.Background()
+	blob := []byte("hello world")
+	dig := digest.FromBytes(blob)
+	f := newFakeRegistry()
+	c := newFakeClient(t, f)
+	if err := c.BlobPush(ctx, "repo", image.Descriptor{Digest: dig, Size: int64(len(blob))}, bytes.NewReader(blob)); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	rc, err := c.BlobGet(ctx, "repo", dig)
+	if err != nil {
+		t.Fatalf("failed to get blob: %v", err)
+	}
+	out, err := io.ReadAll(rc)
+	_ = rc.Close()
+	if err != nil || !bytes.Equal(out, blob) {
+		t.Errorf("expected %s, received %s, %v", blob, out, err)
+	}
+
+	f.blobs[dig] = []byte("hello there")
+	rc, err = c.BlobGet(ctx, "repo", dig)
+	if err != nil {
+		t.Fatalf("failed to get blob: %v", err)
+	}
+	_, err = io.ReadAll(rc)
+	_ = rc.Close()
+	if !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected error %v, received %v", ErrDigestMismatch, err)
+	}
+}
+
+func TestManifestReferrersTag(t *testing.T) {
+	ctx := context.Background()
+	subjectRaw, _ := json.Marshal(image.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: image.MediaTypeImageManifest,
+		Config:    image.DescriptorEmptyJSON,
+		Layers:    []image.Descriptor{image.DescriptorEmptyJSON},
+	})
+	subject := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromBytes(subjectRaw), Size: int64(len(subjectRaw))}
+	referrerRaw, _ := json.Marshal(image.Manifest{
+		Versioned:    specs.Versioned{SchemaVersion: 2},
+		MediaType:    image.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.example.sbom",
+		Config:       image.DescriptorEmptyJSON,
+		Layers:       []image.Descriptor{image.DescriptorEmptyJSON},
+		Subject:      &subject,
+	})
+	referrer := digest.FromBytes(referrerRaw)
+	tag := v1.ReferrersTag(subject.Digest)
+
+	for _, referrers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("referrers %t", referrers), func(t *testing.T) {
+			f := newFakeRegistry()
+			f.referrers = referrers
+			c := newFakeClient(t, f)
+			if _, err := c.ManifestPut(ctx, "repo", subject.Digest.String(), subject.MediaType, subjectRaw); err != nil {
+				t.Fatalf("failed to push subject: %v", err)
+			}
+			if _, err := c.ManifestPut(ctx, "repo", referrer.String(), image.MediaTypeImageManifest, referrerRaw); err != nil {
+				t.Fatalf("failed to push referrer: %v", err)
+			}
+			mf, ok := f.manifests[tag]
+			if referrers {
+				if ok {
+					t.Fatalf("referrers tag pushed to a registry with the referrers API")
+				}
+				if err := c.ManifestDelete(ctx, "repo", referrer); err != nil {
+					t.Fatalf("failed to delete referrer: %v", err)
+				}
+				if _, ok := f.manifests[tag]; ok {
+					t.Errorf("referrers tag pushed after delete")
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("referrers tag %s was not pushed", tag)
+			}
+			index, err := v1.ParseReferrersIndex(mf.mediaType, mf.raw)
+			if err != nil {
+				t.Fatalf("failed to parse referrers tag: %v", err)
+			}
+			if len(index.Manifests) != 1 || index.Manifests[0].Digest != referrer || index.Manifests[0].ArtifactType != "application/vnd.example.sbom" {
+				t.Errorf("unexpected referrers tag %s", mf.raw)
+			}
+			// a second push does not duplicate the entry
+			if _, err := c.ManifestPut(ctx, "repo", referrer.String(), image.MediaTypeImageManifest, referrerRaw); err != nil {
+				t.Fatalf("failed to push referrer: %v", err)
+			}
+			descs, err := c.Referrers(ctx, "repo", subject.Digest, "")
+			if err != nil || len(descs) != 1 {
+				t.Errorf("expected 1 referrer from the tag, received %v, %v", descs, err)
+			}
+
+			if err := c.ManifestDelete(ctx, "repo", referrer); err != nil {
+				t.Fatalf("failed to delete referrer: %v", err)
+			}
+			index, err = v1.ParseReferrersIndex(f.manifests[tag].mediaType, f.manifests[tag].raw)
+			if err != nil {
+				t.Fatalf("failed to parse referrers tag: %v", err)
+			}
+			if len(index.Manifests) != 0 {
+				t.Errorf("referrer was not removed from the tag: %v", index.Manifests)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// manifestMaxSize is the largest manifest that will be pulled, registries should accept manifests of at least 4MiB.
+const manifestMaxSize = 4 << 20
+
+// manifestAccept lists the media types requested when pulling a manifest.
+var manifestAccept = []string{
+	image.MediaTypeImageManifest,
+	image.MediaTypeImageIndex,
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// ManifestHead returns the descriptor of a manifest in the repository by tag or digest (end-3 with HEAD).
+// A missing manifest returns a *v1.ResponseError with a 404 status.
+func (c *Client) ManifestHead(ctx context.Context, repo, ref string) (image.Descriptor, error) {
+	resp, err := c.send(ctx, http.MethodHead, c.urls.Manifest(repo, ref), http.Header{"Accept": manifestAccept}, nil)
+	if err != nil {
+		return image.Descriptor{}, err
+	}
+	defer resp.Body.Close()
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return image.Descriptor{}, err
+	}
+	desc := image.Descriptor{
+		MediaType: mediaTypeBase(resp.Header.Get("Content-Type")),
+		Size:      resp.ContentLength,
+	}
+	refDig, refIsDigest := parseDigest(ref)
+	if h := resp.Header.Get(v1.ContentDigestHeader); h != "" {
+		dig, err := digest.Parse(h)
+		if err != nil {
+			return image.Descriptor{}, fmt.Errorf("invalid %s header %q: %w", v1.ContentDigestHeader, h, err)
+		}
+		if refIsDigest && dig != refDig {
+			return image.Descriptor{}, fmt.Errorf("%w: %s header %q, expected %s", ErrDigestMismatch, v1.ContentDigestHeader, h, refDig)
+		}
+		desc.Digest = dig
+	} else if refIsDigest {
+		desc.Digest = refDig
+	} else {
+		return image.Descriptor{}, fmt.Errorf("registry did not return the %s header for %s", v1.ContentDigestHeader, ref)
+	}
+	return desc, nil
+}
+
+// ManifestGet pulls a manifest from the repository by tag or digest (end-3).
+// The content is verified against the digest in the reference,
+// or against the Docker-Content-Digest header when pulling by tag.
+func (c *Client) ManifestGet(ctx context.Context, repo, ref string) (image.Descriptor, []byte, error) {
+	return c.manifestGet(ctx, repo, ref, manifestAccept)
+}
+
+// ManifestPut pushes a manifest to the repository by tag or digest (end-7a).
+// When the manifest has a subject and the registry does not return the OCI-Subject header,
+// the referrers tag of the subject is updated to include the manifest.
+func (c *Client) ManifestPut(ctx context.Context, repo, ref, mediaType string, raw []byte) (image.Descriptor, error) {
+	desc := image.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}
+	if refDig, ok := parseDigest(ref); ok {
+		if !refDig.Algorithm().Available() {
+			return image.Descriptor{}, fmt.Errorf("digest algorithm %s is not available", refDig.Algorithm())
+		}
+		desc.Digest = refDig.Algorithm().FromBytes(raw)
+		if desc.Digest != refDig {
+			return image.Descriptor{}, fmt.Errorf("%w: pushing %s to %s", ErrDigestMismatch, desc.Digest, refDig)
+		}
+	}
+	resp, err := c.send(ctx, http.MethodPut, c.urls.Manifest(repo, ref), http.Header{"Content-Type": {mediaType}}, raw)
+	if err != nil {
+		return image.Descriptor{}, err
+	}
+	defer drainClose(resp.Body)
+	if err := expectStatus(resp, http.StatusCreated); err != nil {
+		return image.Descriptor{}, err
+	}
+	if h := resp.Header.Get(v1.ContentDigestHeader); h != "" && h != desc.Digest.String() {
+		return image.Descriptor{}, fmt.Errorf("%w: %s header %q, expected %s", ErrDigestMismatch, v1.ContentDigestHeader, h, desc.Digest)
+	}
+	refDesc, subject, err := v1.ReferrerDescriptor(mediaType, raw)
+	if errors.Is(err, v1.ErrSubjectMissing) || resp.Header.Get(v1.SubjectHeader) != "" {
+		return desc, nil
+	}
+	if err != nil {
+		return desc, err
+	}
+	refDesc.Digest = desc.Digest
+	err = c.referrersTagUpdate(ctx, repo, subject.Digest, func(index *image.Index) bool {
+		return v1.ReferrersIndexAdd(index, refDesc)
+	})
+	if err != nil {
+		return desc, fmt.Errorf("failed to update the referrers tag for %s: %w", subject.Digest, err)
+	}
+	return desc, nil
+}
+
+// ManifestDelete deletes a manifest from the repository by digest (end-9).
+// When the manifest has a subject and the registry does not support the referrers API,
+// the manifest is removed from the referrers tag of the subject.
+func (c *Client) ManifestDelete(ctx context.Context, repo string, dig digest.Digest) error {
+	var subject *image.Descriptor
+	if desc, raw, err := c.ManifestGet(ctx, repo, dig.String()); err == nil {
+		if _, s, err := v1.ReferrerDescriptor(desc.MediaType, raw); err == nil {
+			subject = &s
+		}
+	}
+	resp, err := c.send(ctx, http.MethodDelete, c.urls.Manifest(repo, dig.String()), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	if err := expectStatus(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+	if subject == nil {
+		return nil
+	}
+	supported, err := c.referrersSupported(ctx, repo, subject.Digest)
+	if err != nil || supported {
+		return err
+	}
+	err = c.referrersTagUpdate(ctx, repo, subject.Digest, func(index *image.Index) bool {
+		return v1.ReferrersIndexRemove(index, dig)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update the referrers tag for %s: %w", subject.Digest, err)
+	}
+	return nil
+}
+
+func (c *Client) manifestGet(ctx context.Context, repo, ref string, accept []string) (image.Descriptor, []byte, error) {
+	resp, err := c.send(ctx, http.MethodGet, c.urls.Manifest(repo, ref), http.Header{"Accept": accept}, nil)
+	if err != nil {
+		return image.Descriptor{}, nil, err
+	}
+	defer drainClose(resp.Body)
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return image.Descriptor{}, nil, err
+	}
+	if resp.ContentLength > manifestMaxSize {
+		return image.Descriptor{}, nil, fmt.Errorf("manifest size %d exceeds the limit of %d", resp.ContentLength, manifestMaxSize)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, manifestMaxSize+1))
+	if err != nil {
+		return image.Descriptor{}, nil, err
+	}
+	if len(raw) > manifestMaxSize {
+		return image.Descriptor{}, nil, fmt.Errorf("manifest exceeds the limit of %d bytes", manifestMaxSize)
+	}
+	expect, ok := parseDigest(ref)
+	if !ok {
+		h := resp.Header.Get(v1.ContentDigestHeader)
+		if h == "" {
+			expect = digest.FromBytes(raw)
+		} else if expect, err = digest.Parse(h); err != nil {
+			return image.Descriptor{}, nil, fmt.Errorf("invalid %s header %q: %w", v1.ContentDigestHeader, h, err)
+		}
+	}
+	if !expect.Algorithm().Available() {
+		return image.Descriptor{}, nil, fmt.Errorf("digest algorithm %s is not available", expect.Algorithm())
+	}
+	if dig := expect.Algorithm().FromBytes(raw); dig != expect {
+		return image.Descriptor{}, nil, fmt.Errorf("%w: received %s, expected %s", ErrDigestMismatch, dig, expect)
+	}
+	return image.Descriptor{
+		MediaType: mediaTypeBase(resp.Header.Get("Content-Type")),
+		Digest:    expect,
+		Size:      int64(len(raw)),
+	}, raw, nil
+}
+
+// parseDigest returns the digest when the reference is a digest rather than a tag.
+func parseDigest(ref string) (digest.Digest, bool) {
+	if !strings.Contains(ref, ":") {
+		return "", false
+	}
+	dig, err := digest.Parse(ref)
+	return dig, err == nil
+}
+
+// mediaTypeBase strips any parameters from a media type.
+func mediaTypeBase(orig string) string {
+	base, _, _ := strings.Cut(orig, ";")
+	return strings.TrimSpace(strings.ToLower(base))
+}
@@ -0,0 +1,124 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// Referrers returns the manifests that refer to the subject (end-12a and end-12b).
+// The results are filtered by artifactType when set, even when the registry does not apply the filter.
+// When the registry responds with a 404, the referrers tag schema is used instead.
+func (c *Client) Referrers(ctx context.Context, repo string, subject digest.Digest, artifactType string) ([]image.Descriptor, error) {
+	ri, err := v1.NewReferrersIterator(c, c.urls.Referrers(repo, subject.String(), artifactType))
+	if err != nil {
+		return nil, err
+	}
+	descs := []image.Descriptor{}
+	for first := true; ; first = false {
+		index, err := ri.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return descs, nil
+		}
+		if err != nil {
+			if first && isNotFound(err) {
+				return c.referrersFromTag(ctx, repo, subject, artifactType)
+			}
+			return nil, err
+		}
+		descs = append(descs, filterArtifactType(index.Manifests, artifactType)...)
+	}
+}
+
+// referrersFromTag returns the manifests listed in the referrers tag of the subject.
+func (c *Client) referrersFromTag(ctx context.Context, repo string, subject digest.Digest, artifactType string) ([]image.Descriptor, error) {
+	index, err := c.referrersTagGet(ctx, repo, subject)
+	if err != nil {
+		return nil, err
+	}
+	return filterArtifactType(index.Manifests, artifactType), nil
+}
+
+// referrersSupported returns true when the registry responds to the referrers API for the subject.
+func (c *Client) referrersSupported(ctx context.Context, repo string, subject digest.Digest) (bool, error) {
+	resp, err := c.send(ctx, http.MethodGet, c.urls.Referrers(repo, subject.String(), ""), http.Header{"Accept": {image.MediaTypeImageIndex}}, nil)
+	if err != nil {
+		return false, err
+	}
+	defer drainClose(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err := expectStatus(resp, http.StatusOK); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// referrersTagGet pulls the referrers tag of the subject, returning an empty index when the tag does not exist.
+func (c *Client) referrersTagGet(ctx context.Context, repo string, subject digest.Digest) (image.Index, error) {
+	desc, raw, err := c.manifestGet(ctx, repo, v1.ReferrersTag(subject), []string{image.MediaTypeImageIndex})
+	if isNotFound(err) {
+		return v1.NewReferrersIndex(), nil
+	}
+	if err != nil {
+		return image.Index{}, err
+	}
+	return v1.ParseReferrersIndex(desc.MediaType, raw)
+}
+
+// referrersTagUpdate pulls the referrers tag of the subject, applies the update, and pushes the index when it was modified.
+func (c *Client) referrersTagUpdate(ctx context.Context, repo string, subject digest.Digest, update func(*image.Index) bool) error {
+	index, err := c.referrersTagGet(ctx, repo, subject)
+	if err != nil {
+		return err
+	}
+	if !update(&index) {
+		return nil
+	}
+	raw, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, http.MethodPut, c.urls.Manifest(repo, v1.ReferrersTag(subject)), http.Header{"Content-Type": {image.MediaTypeImageIndex}}, raw)
+	if err != nil {
+		return err
+	}
+	defer drainClose(resp.Body)
+	return expectStatus(resp, http.StatusCreated)
+}
+
+// filterArtifactType returns the descriptors with the artifactType, or every descriptor when artifactType is empty.
+func filterArtifactType(descs []image.Descriptor, artifactType string) []image.Descriptor {
+	if artifactType == "" {
+		return descs
+	}
+	filtered := []image.Descriptor{}
+	for _, d := range descs {
+		if d.ArtifactType == artifactType {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,404 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EndpointID is the ID of an endpoint in the spec's endpoints table.
+type EndpointID string
+
+const (
+	// EndpointPing is used to determine support for the spec (end-1).
+	EndpointPing EndpointID = "end-1"
+	// EndpointBlobGet pulls or checks for the existence of a blob (end-2).
+	EndpointBlobGet EndpointID = "end-2"
+	// EndpointManifestGet pulls or checks for the existence of a manifest (end-3).
+	EndpointManifestGet EndpointID = "end-3"
+	// EndpointBlobUploadStart opens a blob upload session (end-4a).
+	EndpointBlobUploadStart EndpointID = "end-4a"
+	// EndpointBlobUploadDigest pushes a blob in a single POST request (end-4b).
+	EndpointBlobUploadDigest EndpointID = "end-4b"
+	// EndpointBlobUploadAlgorithm opens a blob upload session for a digest algorithm (end-4c).
+	EndpointBlobUploadAlgorithm EndpointID = "end-4c"
+	// EndpointBlobUploadChunk pushes a chunk to a blob upload session (end-5).
+	EndpointBlobUploadChunk EndpointID = "end-5"
+	// EndpointBlobUploadFinish closes a blob upload session (end-6).
+	EndpointBlobUploadFinish EndpointID = "end-6"
+	// EndpointManifestPut pushes a manifest (end-7a).
+	EndpointManifestPut EndpointID = "end-7a"
+	// EndpointManifestPutTags pushes a manifest by digest with tag query parameters (end-7b).
+	EndpointManifestPutTags EndpointID = "end-7b"
+	// EndpointTagList lists the tags in a repository (end-8a).
+	EndpointTagList EndpointID = "end-8a"
+	// EndpointTagListPaginated lists the tags in a repository with the n and last parameters (end-8b).
+	EndpointTagListPaginated EndpointID = "end-8b"
+	// EndpointManifestDelete deletes a manifest or tag (end-9).
+	EndpointManifestDelete EndpointID = "end-9"
+	// EndpointBlobDelete deletes a blob (end-10).
+	EndpointBlobDelete EndpointID = "end-10"
+	// EndpointBlobMount mounts a blob from another repository (end-11).
+	EndpointBlobMount EndpointID = "end-11"
+	// EndpointReferrers lists the referrers to a digest (end-12a).
+	EndpointReferrers EndpointID = "end-12a"
+	// EndpointReferrersFiltered lists the referrers to a digest filtered by artifactType (end-12b).
+	EndpointReferrersFiltered EndpointID = "end-12b"
+	// EndpointBlobUploadStatus gets the status of a blob upload session (end-13).
+	EndpointBlobUploadStatus EndpointID = "end-13"
+	// EndpointBlobUploadCancel cancels a blob upload session (end-14).
+	EndpointBlobUploadCancel EndpointID = "end-14"
+)
+
+// Endpoint is an entry in the spec's endpoints table.
+type Endpoint struct {
+	ID EndpointID
+	// Methods are the HTTP methods for the endpoint.
+	Methods []string
+	// Path is the API endpoint template from the spec, e.g. "/v2/<name>/blobs/<digest>".
+	Path string
+	// Success and Failure are the documented response status codes.
+	Success []int
+	Failure []int
+}
+
+var endpoints = []Endpoint{
+	{EndpointPing, []string{http.MethodGet}, "/v2/", []int{200}, []int{404, 401}},
+	{EndpointBlobGet, []string{http.MethodGet, http.MethodHead}, "/v2/<name>/blobs/<digest>", []int{200}, []int{404}},
+	{EndpointManifestGet, []string{http.MethodGet, http.MethodHead}, "/v2/<name>/manifests/<tag-or-digest>", []int{200}, []int{404}},
+	{EndpointBlobUploadStart, []string{http.MethodPost}, "/v2/<name>/blobs/uploads/", []int{202}, []int{404}},
+	{EndpointBlobUploadDigest, []string{http.MethodPost}, "/v2/<name>/blobs/uploads/?digest=<digest>", []int{201, 202}, []int{404, 400}},
+	{EndpointBlobUploadAlgorithm, []string{http.MethodPost}, "/v2/<name>/blobs/uploads/?digest-algorithm=<algorithm>", []int{201, 202}, []int{404, 400}},
+	{EndpointBlobUploadChunk, []string{http.MethodPatch}, "<blob-push-location>", []int{202}, []int{404, 416}},
+	{EndpointBlobUploadFinish, []string{http.MethodPut}, "<blob-push-location>?digest=<digest>", []int{201}, []int{404, 400, 416}},
+	{EndpointManifestPut, []string{http.MethodPut}, "/v2/<name>/manifests/<tag-or-digest>", []int{201}, []int{404, 413}},
+	{EndpointManifestPutTags, []string{http.MethodPut}, "/v2/<name>/manifests/<digest>?tag=1&tag=2&tag=3", []int{201}, []int{404, 413}},
+	{EndpointTagList, []string{http.MethodGet}, "/v2/<name>/tags/list", []int{200}, []int{404}},
+	{EndpointTagListPaginated, []string{http.MethodGet}, "/v2/<name>/tags/list?n=<integer>&last=<tagname>", []int{200}, []int{404}},
+	{EndpointManifestDelete, []string{http.MethodDelete}, "/v2/<name>/manifests/<tag-or-digest>", []int{202}, []int{404, 400, 405}},
+	{EndpointBlobDelete, []string{http.MethodDelete}, "/v2/<name>/blobs/<digest>", []int{202}, []int{404, 400, 405}},
+	{EndpointBlobMount, []string{http.MethodPost}, "/v2/<name>/blobs/uploads/?mount=<digest>&from=<other_name>", []int{201, 202}, []int{404}},
+	{EndpointReferrers, []string{http.MethodGet}, "/v2/<name>/referrers/<digest>", []int{200}, []int{404, 400}},
+	{EndpointReferrersFiltered, []string{http.MethodGet}, "/v2/<name>/referrers/<digest>?artifactType=<artifactType>", []int{200}, []int{404, 400}},
+	{EndpointBlobUploadStatus, []string{http.MethodGet}, "<blob-push-location>", []int{204}, []int{404}},
+	{EndpointBlobUploadCancel, []string{http.MethodDelete}, "<blob-push-location>", []int{204}, []int{404, 400}},
+}
+
+// Endpoints returns a copy of the spec's endpoints table.
+func Endpoints() []Endpoint {
+	ret := make([]Endpoint, len(endpoints))
+	for i, e := range endpoints {
+		ret[i] = e.clone()
+	}
+	return ret
+}
+
+// LookupEndpoint returns the entry from the endpoints table for the ID.
+func LookupEndpoint(id EndpointID) (Endpoint, bool) {
+	for _, e := range endpoints {
+		if e.ID == id {
+			return e.clone(), true
+		}
+	}
+	return Endpoint{}, false
+}
+
+func (e Endpoint) clone() Endpoint {
+	e.Methods = append([]string{}, e.Methods...)
+	e.Success = append([]int{}, e.Success...)
+	e.Failure = append([]int{}, e.Failure...)
+	return e
+}
+
+// IsSuccess returns true when the status is a documented success code for the endpoint.
+func (e Endpoint) IsSuccess(status int) bool {
+	return containsInt(e.Success, status)
+}
+
+// IsFailure returns true when the status is a documented failure code for the endpoint.
+func (e Endpoint) IsFailure(status int) bool {
+	return containsInt(e.Failure, status)
+}
+
+func containsInt(list []int, i int) bool {
+	for _, v := range list {
+		if v == i {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// ErrEndpointUnknown is returned when a request does not match the path of any endpoint.
+	ErrEndpointUnknown = errors.New("request does not match any endpoint")
+	// ErrEndpointMethod is returned when the path matches an endpoint that does not support the request method.
+	ErrEndpointMethod = errors.New("request method is not supported by the endpoint")
+)
+
+// EndpointMatch is the result of classifying a request with MatchEndpoint.
+type EndpointMatch struct {
+	ID EndpointID
+	// Name is the repository <name>.
+	Name string
+	// Reference is the <tag-or-digest> of a manifest request.
+	Reference string
+	// Digest is the <digest> of a blob or referrers request, or the digest query parameter of an upload.
+	Digest string
+	// SessionID is the last path component of a <blob-push-location>.
+	SessionID string
+	// Query contains the parsed query parameters.
+	Query url.Values
+}
+
+const nameExpr = `[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:\/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)*`
+
+var (
+	reEndpointUploadStart = regexp.MustCompile(`^/v2/(` + nameExpr + `)/blobs/uploads/?$`)
+	reEndpointUpload      = regexp.MustCompile(`^/v2/(` + nameExpr + `)/blobs/uploads/([^/]+)$`)
+	reEndpointBlob        = regexp.MustCompile(`^/v2/(` + nameExpr + `)/blobs/([^/]+)$`)
+	reEndpointManifest    = regexp.MustCompile(`^/v2/(` + nameExpr + `)/manifests/([^/]+)$`)
+	reEndpointTagList     = regexp.MustCompile(`^/v2/(` + nameExpr + `)/tags/list$`)
+	reEndpointReferrers   = regexp.MustCompile(`^/v2/(` + nameExpr + `)/referrers/([^/]+)$`)
+)
+
+// MatchEndpoint classifies a request into an endpoint from the spec and extracts the path parameters.
+// The <blob-push-location> is defined by each registry, so only the conventional
+// "/v2/<name>/blobs/uploads/<session>" form is recognized for upload sessions.
+// ErrEndpointUnknown is returned for an unrecognized path, and ErrEndpointMethod for an unsupported method.
+func MatchEndpoint(req *http.Request) (EndpointMatch, error) {
+	m := EndpointMatch{Query: req.URL.Query()}
+	path := req.URL.Path
+	method := req.Method
+	has := func(key string) bool {
+		_, ok := m.Query[key]
+		return ok
+	}
+	if path == "/v2/" || path == "/v2" {
+		if method == http.MethodGet {
+			m.ID = EndpointPing
+			return m, nil
+		}
+		return m, ErrEndpointMethod
+	}
+	if match := reEndpointUploadStart.FindStringSubmatch(path); match != nil {
+		m.Name = match[1]
+		if method != http.MethodPost {
+			return m, ErrEndpointMethod
+		}
+		switch {
+		case has("mount"):
+			m.ID = EndpointBlobMount
+			m.Digest = m.Query.Get("mount")
+		case has("digest"):
+			m.ID = EndpointBlobUploadDigest
+			m.Digest = m.Query.Get("digest")
+		case has("digest-algorithm"):
+			m.ID = EndpointBlobUploadAlgorithm
+		default:
+			m.ID = EndpointBlobUploadStart
+		}
+		return m, nil
+	}
+	if match := reEndpointUpload.FindStringSubmatch(path); match != nil {
+		m.Name, m.SessionID = match[1], match[2]
+		switch method {
+		case http.MethodPatch:
+			m.ID = EndpointBlobUploadChunk
+		case http.MethodPut:
+			m.ID = EndpointBlobUploadFinish
+			m.Digest = m.Query.Get("digest")
+		case http.MethodGet:
+			m.ID = EndpointBlobUploadStatus
+		case http.MethodDelete:
+			m.ID = EndpointBlobUploadCancel
+		default:
+			return m, ErrEndpointMethod
+		}
+		return m, nil
+	}
+	if match := reEndpointBlob.FindStringSubmatch(path); match != nil {
+		m.Name, m.Digest = match[1], match[2]
+		switch method {
+		case http.MethodGet, http.MethodHead:
+			m.ID = EndpointBlobGet
+		case http.MethodDelete:
+			m.ID = EndpointBlobDelete
+		default:
+			return m, ErrEndpointMethod
+		}
+		return m, nil
+	}
+	if match := reEndpointManifest.FindStringSubmatch(path); match != nil {
+		m.Name, m.Reference = match[1], match[2]
+		switch method {
+		case http.MethodGet, http.MethodHead:
+			m.ID = EndpointManifestGet
+		case http.MethodPut:
+			if has("tag") {
+				m.ID = EndpointManifestPutTags
+			} else {
+				m.ID = EndpointManifestPut
+			}
+		case http.MethodDelete:
+			m.ID = EndpointManifestDelete
+		default:
+			return m, ErrEndpointMethod
+		}
+		return m, nil
+	}
+	if match := reEndpointTagList.FindStringSubmatch(path); match != nil {
+		m.Name = match[1]
+		if method != http.MethodGet {
+			return m, ErrEndpointMethod
+		}
+		if has("n") || has("last") {
+			m.ID = EndpointTagListPaginated
+		} else {
+			m.ID = EndpointTagList
+		}
+		return m, nil
+	}
+	if match := reEndpointReferrers.FindStringSubmatch(path); match != nil {
+		m.Name, m.Digest = match[1], match[2]
+		if method != http.MethodGet {
+			return m, ErrEndpointMethod
+		}
+		if has("artifactType") {
+			m.ID = EndpointReferrersFiltered
+		} else {
+			m.ID = EndpointReferrers
+		}
+		return m, nil
+	}
+	return m, ErrEndpointUnknown
+}
+
+// URLBuilder generates URLs for the endpoints of a registry.
+type URLBuilder struct {
+	base *url.URL
+}
+
+// NewURLBuilder returns a URLBuilder for the registry at base, e.g. "https://registry.example.org".
+// Any path in base is used as a prefix before "/v2/".
+func NewURLBuilder(base string) (*URLBuilder, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse registry url %q: %w", base, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("registry url %q must include a scheme and host", base)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	return &URLBuilder{base: u}, nil
+}
+
+func (b *URLBuilder) build(path string, query url.Values) string {
+	u := *b.base
+	u.Path = u.Path + path
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// Ping returns the URL for end-1.
+func (b *URLBuilder) Ping() string {
+	return b.build("/v2/", nil)
+}
+
+// Blob returns the URL for end-2 and end-10.
+func (b *URLBuilder) Blob(name, digest string) string {
+	return b.build("/v2/"+name+"/blobs/"+digest, nil)
+}
+
+// Manifest returns the URL for end-3, end-7a, and end-9.
+func (b *URLBuilder) Manifest(name, reference string) string {
+	return b.build("/v2/"+name+"/manifests/"+reference, nil)
+}
+
+// ManifestTags returns the URL for end-7b, pushing a manifest by digest with tags.
+func (b *URLBuilder) ManifestTags(name, digest string, tags ...string) string {
+	q := url.Values{}
+	if len(tags) > 0 {
+		q["tag"] = tags
+	}
+	return b.build("/v2/"+name+"/manifests/"+digest, q)
+}
+
+// BlobUpload returns the URL for end-4a.
+func (b *URLBuilder) BlobUpload(name string) string {
+	return b.build("/v2/"+name+"/blobs/uploads/", nil)
+}
+
+// BlobUploadDigest returns the URL for end-4b, pushing a blob in a single POST.
+func (b *URLBuilder) BlobUploadDigest(name, digest string) string {
+	return b.build("/v2/"+name+"/blobs/uploads/", url.Values{"digest": {digest}})
+}
+
+// BlobUploadAlgorithm returns the URL for end-4c, opening an upload session for a digest algorithm.
+func (b *URLBuilder) BlobUploadAlgorithm(name, algorithm string) string {
+	return b.build("/v2/"+name+"/blobs/uploads/", url.Values{"digest-algorithm": {algorithm}})
+}
+
+// BlobMount returns the URL for end-11. The from parameter is omitted when empty.
+func (b *URLBuilder) BlobMount(name, digest, from string) string {
+	q := url.Values{"mount": {digest}}
+	if from != "" {
+		q.Set("from", from)
+	}
+	return b.build("/v2/"+name+"/blobs/uploads/", q)
+}
+
+// TagList returns the URL for end-8a and end-8b.
+// The n parameter is omitted when negative, and last is omitted when empty.
+func (b *URLBuilder) TagList(name string, n int, last string) string {
+	q := url.Values{}
+	if n >= 0 {
+		q.Set("n", strconv.Itoa(n))
+	}
+	if last != "" {
+		q.Set("last", last)
+	}
+	return b.build("/v2/"+name+"/tags/list", q)
+}
+
+// Referrers returns the URL for end-12a and end-12b.
+// The artifactType parameter is omitted when empty.
+func (b *URLBuilder) Referrers(name, digest, artifactType string) string {
+	q := url.Values{}
+	if artifactType != "" {
+		q.Set("artifactType", artifactType)
+	}
+	return b.build("/v2/"+name+"/referrers/"+digest, q)
+}
+
+// ResolveLocation resolves a Location header, which may be relative, against the registry.
+func (b *URLBuilder) ResolveLocation(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse location %q: %w", location, err)
+	}
+	return b.base.ResolveReference(u).String(), nil
+}
@@ -0,0 +1,334 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestEndpointsSpec compares the endpoints table with the table in spec.md.
+func TestEndpointsSpec(t *testing.T) {
+	spec, err := os.ReadFile("../../spec.md")
+	if err != nil {
+		t.Fatalf("failed to read spec.md: %v", err)
+	}
+	re := regexp.MustCompile(`(?m)^\|\s*(end-[0-9]+[a-z]?)\s*\|(.*)\|(.*)\|(.*)\|(.*)\|\s*$`)
+	rows := re.FindAllStringSubmatch(string(spec), -1)
+	eps := Endpoints()
+	if len(rows) != len(eps) {
+		t.Fatalf("spec.md defines %d endpoints, Endpoints returns %d", len(rows), len(eps))
+	}
+	cells := func(s string) []string {
+		ret := []string{}
+		for _, v := range strings.Split(s, "/ ") {
+			ret = append(ret, strings.Trim(strings.TrimSpace(v), "`"))
+		}
+		return ret
+	}
+	codes := func(s string) []int {
+		ret := []int{}
+		for _, v := range strings.Split(s, "/") {
+			i, err := strconv.Atoi(strings.Trim(strings.TrimSpace(v), "`"))
+			if err != nil {
+				t.Fatalf("failed to parse status %q: %v", v, err)
+			}
+			ret = append(ret, i)
+		}
+		return ret
+	}
+	for i, row := range rows {
+		e := eps[i]
+		if string(e.ID) != row[1] {
+			t.Errorf("row %d: expected %s, received %s", i, row[1], e.ID)
+			continue
+		}
+		if methods := cells(row[2]); strings.Join(methods, ",") != strings.Join(e.Methods, ",") {
+			t.Errorf("%s: expected methods %v, received %v", e.ID, methods, e.Methods)
+		}
+		if path := strings.Trim(strings.TrimSpace(row[3]), "`"); path != e.Path {
+			t.Errorf("%s: expected path %s, received %s", e.ID, path, e.Path)
+		}
+		for _, c := range codes(row[4]) {
+			if !e.IsSuccess(c) {
+				t.Errorf("%s: %d is not a success code", e.ID, c)
+			}
+		}
+		for _, c := range codes(row[5]) {
+			if !e.IsFailure(c) {
+				t.Errorf("%s: %d is not a failure code", e.ID, c)
+			}
+		}
+	}
+}
+
+func TestMatchEndpoint(t *testing.T) {
+	dig := "sha256:" + strings.Repeat("a", 64)
+	tt := []struct {
+		name      string
+		method    string
+		path      string
+		expect    EndpointMatch
+		expectErr error
+	}{
+		{
+			name:   "end-1",
+			method: http.MethodGet,
+			path:   "/v2/",
+			expect: EndpointMatch{ID: EndpointPing},
+		},
+		{
+			name:   "end-2",
+			method: http.MethodHead,
+			path:   "/v2/org/repo/blobs/" + dig,
+			expect: EndpointMatch{ID: EndpointBlobGet, Name: "org/repo", Digest: dig},
+		},
+		{
+			name:   "end-3",
+			method: http.MethodGet,
+			path:   "/v2/org/repo/manifests/v1.0",
+			expect: EndpointMatch{ID: EndpointManifestGet, Name: "org/repo", Reference: "v1.0"},
+		},
+		{
+			name:   "end-4a",
+			method: http.MethodPost,
+			path:   "/v2/org/repo/blobs/uploads/",
+			expect: EndpointMatch{ID: EndpointBlobUploadStart, Name: "org/repo"},
+		},
+		{
+			name:   "end-4b",
+			method: http.MethodPost,
+			path:   "/v2/org/repo/blobs/uploads/?digest=" + dig,
+			expect: EndpointMatch{ID: EndpointBlobUploadDigest, Name: "org/repo", Digest: dig},
+		},
+		{
+			name:   "end-4c",
+			method: http.MethodPost,
+			path:   "/v2/org/repo/blobs/uploads/?digest-algorithm=sha512",
+			expect: EndpointMatch{ID: EndpointBlobUploadAlgorithm, Name: "org/repo"},
+		},
+		{
+			name:   "end-5",
+			method: http.MethodPatch,
+			path:   "/v2/org/repo/blobs/uploads/session-1",
+			expect: EndpointMatch{ID: EndpointBlobUploadChunk, Name: "org/repo", SessionID: "session-1"},
+		},
+		{
+			name:   "end-6",
+			method: http.MethodPut,
+			path:   "/v2/org/repo/blobs/uploads/session-1?digest=" + dig,
+			expect: EndpointMatch{ID: EndpointBlobUploadFinish, Name: "org/repo", SessionID: "session-1", Digest: dig},
+		},
+		{
+			name:   "end-7a",
+			method: http.MethodPut,
+			path:   "/v2/org/repo/manifests/latest",
+			expect: EndpointMatch{ID: EndpointManifestPut, Name: "org/repo", Reference: "latest"},
+		},
+		{
+			name:   "end-7b",
+			method: http.MethodPut,
+			path:   "/v2/org/repo/manifests/" + dig + "?tag=1&tag=2&tag=3",
+			expect: EndpointMatch{ID: EndpointManifestPutTags, Name: "org/repo", Reference: dig},
+		},
+		{
+			name:   "end-8a",
+			method: http.MethodGet,
+			path:   "/v2/org/repo/tags/list",
+			expect: EndpointMatch{ID: EndpointTagList, Name: "org/repo"},
+		},
+		{
+			name:   "end-8b",
+			method: http.MethodGet,
+			path:   "/v2/org/repo/tags/list?n=10&last=v1",
+			expect: EndpointMatch{ID: EndpointTagListPaginated, Name: "org/repo"},
+		},
+		{
+			name:   "end-9",
+			method: http.MethodDelete,
+			path:   "/v2/org/repo/manifests/" + dig,
+			expect: EndpointMatch{ID: EndpointManifestDelete, Name: "org/repo", Reference: dig},
+		},
+		{
+			name:   "end-10",
+			method: http.MethodDelete,
+			path:   "/v2/org/repo/blobs/" + dig,
+			expect: EndpointMatch{ID: EndpointBlobDelete, Name: "org/repo", Digest: dig},
+		},
+		{
+			name:   "end-11",
+			method: http.MethodPost,
+			path:   "/v2/org/repo/blobs/uploads/?mount=" + dig + "&from=other/repo",
+			expect: EndpointMatch{ID: EndpointBlobMount, Name: "org/repo", Digest: dig},
+		},
+		{
+			name:   "end-12a",
+			method: http.MethodGet,
+			path:   "/v2/org/repo/referrers/" + dig,
+			expect: EndpointMatch{ID: EndpointReferrers, Name: "org/repo", Digest: dig},
+		},
+		{
+			name:   "end-12b",
+			method: http.MethodGet,
+			path:   "/v2/org/repo/referrers/" + dig + "?artifactType=application%2Fvnd.example",
+			expect: EndpointMatch{ID: EndpointReferrersFiltered, Name: "org/repo", Digest: dig},
+		},
+		{
+			name:   "end-13",
+			method: http.MethodGet,
+			path:   "/v2/org/repo/blobs/uploads/session-1",
+			expect: EndpointMatch{ID: EndpointBlobUploadStatus, Name: "org/repo", SessionID: "session-1"},
+		},
+		{
+			name:   "end-14",
+			method: http.MethodDelete,
+			path:   "/v2/org/repo/blobs/uploads/session-1",
+			expect: EndpointMatch{ID: EndpointBlobUploadCancel, Name: "org/repo", SessionID: "session-1"},
+		},
+		{
+			name:   "name with separators",
+			method: http.MethodGet,
+			path:   "/v2/a.b/c__d/e---f/manifests/latest",
+			expect: EndpointMatch{ID: EndpointManifestGet, Name: "a.b/c__d/e---f", Reference: "latest"},
+		},
+		{
+			name:      "ping method",
+			method:    http.MethodPost,
+			path:      "/v2/",
+			expectErr: ErrEndpointMethod,
+		},
+		{
+			name:      "manifest method",
+			method:    http.MethodPatch,
+			path:      "/v2/org/repo/manifests/latest",
+			expectErr: ErrEndpointMethod,
+		},
+		{
+			name:      "tag list method",
+			method:    http.MethodDelete,
+			path:      "/v2/org/repo/tags/list",
+			expectErr: ErrEndpointMethod,
+		},
+		{
+			name:      "uppercase name",
+			method:    http.MethodGet,
+			path:      "/v2/Org/repo/manifests/latest",
+			expectErr: ErrEndpointUnknown,
+		},
+		{
+			name:      "catalog",
+			method:    http.MethodGet,
+			path:      "/v2/_catalog",
+			expectErr: ErrEndpointUnknown,
+		},
+	}
+	seen := map[EndpointID]bool{}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, "https://registry.example.com"+tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			m, err := MatchEndpoint(req)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("expected error %v, received %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			seen[m.ID] = true
+			if m.ID != tc.expect.ID || m.Name != tc.expect.Name || m.Reference != tc.expect.Reference ||
+				m.Digest != tc.expect.Digest || m.SessionID != tc.expect.SessionID {
+				t.Errorf("expected %+v, received %+v", tc.expect, m)
+			}
+			e, ok := LookupEndpoint(m.ID)
+			if !ok || !containsString(e.Methods, tc.method) {
+				t.Errorf("method %s is not listed for %s", tc.method, m.ID)
+			}
+		})
+	}
+	for _, e := range Endpoints() {
+		if !seen[e.ID] {
+			t.Errorf("endpoint %s was not matched by any test", e.ID)
+		}
+	}
+}
+
+func TestURLBuilder(t *testing.T) {
+	dig := "sha256:" + strings.Repeat("b", 64)
+	b, err := NewURLBuilder("https://registry.example.com/prefix/")
+	if err != nil {
+		t.Fatalf("failed to create builder: %v", err)
+	}
+	tt := []struct {
+		url    string
+		method string
+		expect EndpointID
+	}{
+		{url: b.Ping(), method: http.MethodGet, expect: EndpointPing},
+		{url: b.Blob("repo", dig), method: http.MethodGet, expect: EndpointBlobGet},
+		{url: b.Manifest("repo", "latest"), method: http.MethodPut, expect: EndpointManifestPut},
+		{url: b.ManifestTags("repo", dig, "a", "b"), method: http.MethodPut, expect: EndpointManifestPutTags},
+		{url: b.BlobUpload("repo"), method: http.MethodPost, expect: EndpointBlobUploadStart},
+		{url: b.BlobUploadDigest("repo", dig), method: http.MethodPost, expect: EndpointBlobUploadDigest},
+		{url: b.BlobUploadAlgorithm("repo", "sha512"), method: http.MethodPost, expect: EndpointBlobUploadAlgorithm},
+		{url: b.BlobMount("repo", dig, "other"), method: http.MethodPost, expect: EndpointBlobMount},
+		{url: b.TagList("repo", -1, ""), method: http.MethodGet, expect: EndpointTagList},
+		{url: b.TagList("repo", 10, "v1"), method: http.MethodGet, expect: EndpointTagListPaginated},
+		{url: b.Referrers("repo", dig, ""), method: http.MethodGet, expect: EndpointReferrers},
+		{url: b.Referrers("repo", dig, "application/vnd.example"), method: http.MethodGet, expect: EndpointReferrersFiltered},
+	}
+	for _, tc := range tt {
+		t.Run(string(tc.expect), func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatalf("failed to parse %s: %v", tc.url, err)
+			}
+			if !strings.HasPrefix(u.Path, "/prefix/v2/") {
+				t.Fatalf("prefix missing from %s", tc.url)
+			}
+			u.Path = strings.TrimPrefix(u.Path, "/prefix")
+			m, err := MatchEndpoint(&http.Request{Method: tc.method, URL: u})
+			if err != nil {
+				t.Fatalf("failed to match %s: %v", tc.url, err)
+			}
+			if m.ID != tc.expect {
+				t.Errorf("expected %s, received %s", tc.expect, m.ID)
+			}
+		})
+	}
+	loc, err := b.ResolveLocation("/v2/repo/blobs/uploads/session?state=1")
+	if err != nil || loc != "https://registry.example.com/v2/repo/blobs/uploads/session?state=1" {
+		t.Errorf("unexpected location %s: %v", loc, err)
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
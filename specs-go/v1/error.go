@@ -14,6 +14,16 @@
 
 package v1
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
 // ErrorResponse is returned by a registry on an invalid request.
 type ErrorResponse struct {
 	Errors []ErrorInfo `json:"errors"`
@@ -22,6 +32,14 @@ type ErrorResponse struct {
 // ErrRegistry is the string returned by and ErrorResponse error.
 var ErrRegistry = "distribution: registry returned error"
 
+// NewErrorResponse returns an ErrorResponse containing a single error for the code.
+// When message is empty, the description of the code from the spec is used.
+func NewErrorResponse(code ErrorCode, message string) *ErrorResponse {
+	return &ErrorResponse{
+		Errors: []ErrorInfo{NewErrorInfo(code, message)},
+	}
+}
+
 // Error implements the Error interface.
 func (er *ErrorResponse) Error() string {
 	return ErrRegistry
@@ -32,9 +50,354 @@ func (er *ErrorResponse) Detail() []ErrorInfo {
 	return er.Errors
 }
 
+// HTTPStatus returns the status code a registry should send with the response.
+// The status of the first error with a known code is used,
+// falling back to 400 Bad Request.
+func (er *ErrorResponse) HTTPStatus() int {
+	for _, ei := range er.Errors {
+		if status := ErrorCode(ei.Code).HTTPStatus(); status != 0 {
+			return status
+		}
+	}
+	return http.StatusBadRequest
+}
+
+// Is returns true when target is an ErrorCode found in any of the errors.
+// This allows errors.Is(err, ErrManifestUnknown) on a returned ErrorResponse.
+func (er *ErrorResponse) Is(target error) bool {
+	for _, ei := range er.Errors {
+		if ei.Is(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As sets target to the first error when target is an *ErrorCode or *ErrorInfo.
+func (er *ErrorResponse) As(target interface{}) bool {
+	if len(er.Errors) == 0 {
+		return false
+	}
+	switch t := target.(type) {
+	case *ErrorCode:
+		*t = ErrorCode(er.Errors[0].Code)
+		return true
+	case *ErrorInfo:
+		*t = er.Errors[0]
+		return true
+	}
+	return false
+}
+
 // ErrorInfo describes a server error returned from a registry.
 type ErrorInfo struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
-	Detail  string `json:"detail"`
+	// Detail is the detail field when it is a JSON string.
+	// Any other JSON value is stored as its JSON text.
+	Detail string `json:"detail"`
+	// DetailRaw is the detail field exactly as it was decoded, which may contain arbitrary JSON data.
+	// When set, it is written in place of Detail when marshaling.
+	DetailRaw json.RawMessage `json:"-"`
+}
+
+// errorInfoJSON is the JSON encoding of an ErrorInfo, with any JSON value in the detail field.
+type errorInfoJSON struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Detail  json.RawMessage `json:"detail,omitempty"`
+}
+
+// MarshalJSON encodes an ErrorInfo, writing DetailRaw when it is set, and otherwise a non-empty Detail as a JSON string.
+func (ei ErrorInfo) MarshalJSON() ([]byte, error) {
+	eij := errorInfoJSON{
+		Code:    ei.Code,
+		Message: ei.Message,
+		Detail:  ei.DetailRaw,
+	}
+	if len(eij.Detail) == 0 && ei.Detail != "" {
+		detail, err := json.Marshal(ei.Detail)
+		if err != nil {
+			return nil, err
+		}
+		eij.Detail = detail
+	}
+	return json.Marshal(eij)
+}
+
+// UnmarshalJSON decodes an ErrorInfo, accepting any JSON value in the detail field.
+func (ei *ErrorInfo) UnmarshalJSON(b []byte) error {
+	eij := errorInfoJSON{}
+	if err := json.Unmarshal(b, &eij); err != nil {
+		return err
+	}
+	*ei = ErrorInfo{
+		Code:    eij.Code,
+		Message: eij.Message,
+	}
+	detail := bytes.TrimSpace(eij.Detail)
+	if len(detail) == 0 || bytes.Equal(detail, []byte("null")) {
+		return nil
+	}
+	ei.DetailRaw = append(json.RawMessage{}, detail...)
+	if detail[0] == '"' {
+		return json.Unmarshal(detail, &ei.Detail)
+	}
+	ei.Detail = string(detail)
+	return nil
+}
+
+// NewErrorInfo returns an ErrorInfo for the code.
+// When message is empty, the description of the code from the spec is used.
+func NewErrorInfo(code ErrorCode, message string) ErrorInfo {
+	if message == "" {
+		message = code.Description()
+	}
+	return ErrorInfo{
+		Code:    string(code),
+		Message: message,
+	}
+}
+
+// Error implements the Error interface.
+func (ei ErrorInfo) Error() string {
+	if ei.Message == "" {
+		return ei.Code
+	}
+	return ei.Code + ": " + ei.Message
+}
+
+// Is returns true when target is an ErrorCode matching the Code.
+func (ei ErrorInfo) Is(target error) bool {
+	ec, ok := target.(ErrorCode)
+	return ok && string(ec) == ei.Code
+}
+
+// ErrorCode is the code field of an ErrorInfo.
+// The values defined by the spec are available as constants, each of which can be used as an error.
+type ErrorCode string
+
+const (
+	// ErrBlobUnknown is returned when a blob is unknown to the registry (code-1).
+	ErrBlobUnknown ErrorCode = "BLOB_UNKNOWN"
+	// ErrBlobUploadInvalid is returned when a blob upload is invalid (code-2).
+	ErrBlobUploadInvalid ErrorCode = "BLOB_UPLOAD_INVALID"
+	// ErrBlobUploadUnknown is returned when a blob upload is unknown to the registry (code-3).
+	ErrBlobUploadUnknown ErrorCode = "BLOB_UPLOAD_UNKNOWN"
+	// ErrDigestInvalid is returned when the provided digest did not match uploaded content (code-4).
+	ErrDigestInvalid ErrorCode = "DIGEST_INVALID"
+	// ErrManifestBlobUnknown is returned when a manifest references a manifest or blob unknown to the registry (code-5).
+	ErrManifestBlobUnknown ErrorCode = "MANIFEST_BLOB_UNKNOWN"
+	// ErrManifestInvalid is returned when a manifest is invalid (code-6).
+	ErrManifestInvalid ErrorCode = "MANIFEST_INVALID"
+	// ErrManifestUnknown is returned when a manifest is unknown to the registry (code-7).
+	ErrManifestUnknown ErrorCode = "MANIFEST_UNKNOWN"
+	// ErrNameInvalid is returned for an invalid repository name (code-8).
+	ErrNameInvalid ErrorCode = "NAME_INVALID"
+	// ErrNameUnknown is returned when a repository name is not known to the registry (code-9).
+	ErrNameUnknown ErrorCode = "NAME_UNKNOWN"
+	// ErrSizeInvalid is returned when the provided length did not match the content length (code-10).
+	ErrSizeInvalid ErrorCode = "SIZE_INVALID"
+	// ErrUnauthorized is returned when authentication is required (code-11).
+	ErrUnauthorized ErrorCode = "UNAUTHORIZED"
+	// ErrDenied is returned when the requested access to the resource is denied (code-12).
+	ErrDenied ErrorCode = "DENIED"
+	// ErrUnsupported is returned when the operation is unsupported (code-13).
+	ErrUnsupported ErrorCode = "UNSUPPORTED"
+	// ErrTooManyRequests is returned when a client has sent too many requests (code-14).
+	ErrTooManyRequests ErrorCode = "TOOMANYREQUESTS"
+)
+
+type errorCodeInfo struct {
+	description string
+	status      int
+}
+
+var errorCodes = map[ErrorCode]errorCodeInfo{
+	ErrBlobUnknown:         {"blob unknown to registry", http.StatusNotFound},
+	ErrBlobUploadInvalid:   {"blob upload invalid", http.StatusBadRequest},
+	ErrBlobUploadUnknown:   {"blob upload unknown to registry", http.StatusNotFound},
+	ErrDigestInvalid:       {"provided digest did not match uploaded content", http.StatusBadRequest},
+	ErrManifestBlobUnknown: {"manifest references a manifest or blob unknown to registry", http.StatusNotFound},
+	ErrManifestInvalid:     {"manifest invalid", http.StatusBadRequest},
+	ErrManifestUnknown:     {"manifest unknown to registry", http.StatusNotFound},
+	ErrNameInvalid:         {"invalid repository name", http.StatusBadRequest},
+	ErrNameUnknown:         {"repository name not known to registry", http.StatusNotFound},
+	ErrSizeInvalid:         {"provided length did not match content length", http.StatusBadRequest},
+	ErrUnauthorized:        {"authentication required", http.StatusUnauthorized},
+	ErrDenied:              {"requested access to the resource is denied", http.StatusForbidden},
+	ErrUnsupported:         {"the operation is unsupported", http.StatusMethodNotAllowed},
+	ErrTooManyRequests:     {"too many requests", http.StatusTooManyRequests},
+}
+
+// ErrorCodes returns every code defined by the spec, in the order of the error codes table.
+func ErrorCodes() []ErrorCode {
+	return []ErrorCode{
+		ErrBlobUnknown,
+		ErrBlobUploadInvalid,
+		ErrBlobUploadUnknown,
+		ErrDigestInvalid,
+		ErrManifestBlobUnknown,
+		ErrManifestInvalid,
+		ErrManifestUnknown,
+		ErrNameInvalid,
+		ErrNameUnknown,
+		ErrSizeInvalid,
+		ErrUnauthorized,
+		ErrDenied,
+		ErrUnsupported,
+		ErrTooManyRequests,
+	}
+}
+
+// Error implements the Error interface.
+func (ec ErrorCode) Error() string {
+	if d := ec.Description(); d != "" {
+		return string(ec) + ": " + d
+	}
+	return string(ec)
+}
+
+// Description returns the description of the code from the spec, or an empty string for an unknown code.
+func (ec ErrorCode) Description() string {
+	return errorCodes[ec].description
+}
+
+// HTTPStatus returns the expected HTTP status for the code, or 0 for an unknown code.
+func (ec ErrorCode) HTTPStatus() int {
+	return errorCodes[ec].status
+}
+
+// Known returns true when the code is one of the codes defined by the spec.
+func (ec ErrorCode) Known() bool {
+	_, ok := errorCodes[ec]
+	return ok
+}
+
+// Valid returns true when the code only contains uppercase alphabetic characters and underscores.
+// Registries may return codes that are valid but not known, such as the legacy Docker codes.
+func (ec ErrorCode) Valid() bool {
+	if ec == "" {
+		return false
+	}
+	for _, c := range ec {
+		if (c < 'A' || c > 'Z') && c != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// maxErrorBodySize limits how much of an error response body is read by ParseErrorResponse.
+const maxErrorBodySize = 1 << 20
+
+// ResponseError is an error response from a registry, returned by ParseErrorResponse.
+type ResponseError struct {
+	// Method and URL identify the request, when available.
+	Method string
+	URL    string
+	// StatusCode and Status are copied from the response.
+	StatusCode int
+	Status     string
+	// Header contains the response headers.
+	Header http.Header
+	// Body is the raw response body, truncated to 1MiB.
+	Body []byte
+	// BodyErr is set when the response body could not be read, in which case Body may be incomplete.
+	BodyErr error
+	// Response is the decoded JSON body, or nil when the body was empty or not an ErrorResponse.
+	Response *ErrorResponse
+}
+
+// ParseErrorResponse converts a 4XX or 5XX response into a *ResponseError.
+// A nil error is returned for any other status.
+// The spec allows the body of an error response to be in any format,
+// so a body that is empty or is not a JSON ErrorResponse results in a nil Response field.
+// The response body is read but not closed.
+func ParseErrorResponse(resp *http.Response) error {
+	if resp == nil || resp.StatusCode < 400 {
+		return nil
+	}
+	re := &ResponseError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+	}
+	if resp.Request != nil {
+		re.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			re.URL = resp.Request.URL.Redacted()
+		}
+	}
+	if resp.Body == nil {
+		return re
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	re.Body = body
+	if err != nil {
+		re.BodyErr = err
+		return re
+	}
+	if !isJSONBody(resp.Header.Get("Content-Type"), body) {
+		return re
+	}
+	er := ErrorResponse{}
+	if err := json.Unmarshal(body, &er); err == nil && len(er.Errors) > 0 {
+		re.Response = &er
+	}
+	return re
+}
+
+// isJSONBody returns true when the content type is JSON, or the body looks like a JSON object when no type is set.
+func isJSONBody(contentType string, body []byte) bool {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return false
+	}
+	if contentType != "" {
+		if mt, _, err := mime.ParseMediaType(contentType); err == nil && (mt == "application/json" || strings.HasSuffix(mt, "+json")) {
+			return true
+		}
+	}
+	return body[0] == '{'
+}
+
+// Error implements the Error interface.
+func (re *ResponseError) Error() string {
+	var sb strings.Builder
+	if re.Method != "" && re.URL != "" {
+		sb.WriteString(re.Method + " " + re.URL + ": ")
+	}
+	status := re.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", re.StatusCode, http.StatusText(re.StatusCode))
+	}
+	sb.WriteString(status)
+	if re.BodyErr != nil {
+		sb.WriteString(": failed to read the body: " + re.BodyErr.Error())
+	} else if re.Response != nil {
+		for i, ei := range re.Response.Errors {
+			if i == 0 {
+				sb.WriteString(": ")
+			} else {
+				sb.WriteString("; ")
+			}
+			sb.WriteString(ei.Error())
+		}
+	} else if body := strings.TrimSpace(string(re.Body)); body != "" {
+		if len(body) > 256 {
+			body = body[:256] + "..."
+		}
+		sb.WriteString(": " + body)
+	}
+	return sb.String()
+}
+
+// Unwrap returns the decoded ErrorResponse, allowing errors.Is and errors.As to inspect the error codes.
+// When the body could not be read, the read error is returned instead.
+func (re *ResponseError) Unwrap() error {
+	if re.Response == nil {
+		return re.BodyErr
+	}
+	return re.Response
 }
@@ -0,0 +1,89 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// discoverBodyMax limits the size of a discover response that will be read.
+const discoverBodyMax = 1 << 20
+
+// DiscoverURL returns the URL to discover registry-level extensions, or repository-level extensions when name is set.
+// The registry is the base URL, e.g. "https://registry.example.org".
+func DiscoverURL(registry, name string) (string, error) {
+	u, err := url.Parse(registry)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse registry url %q: %w", registry, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("registry url %q must include a scheme and host", registry)
+	}
+	path := strings.TrimSuffix(u.Path, "/") + "/v2/"
+	if name != "" {
+		path += name + "/"
+	}
+	u.Path = path + DiscoverEndpoint
+	u.RawPath = ""
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// Discover requests the extension list from the registry, with name selecting repository-level extensions.
+// The decoded list is returned even when it fails validation, along with an error wrapping ErrExtensionInvalid.
+// A registry that does not support extensions typically responds with a 404 or an UNSUPPORTED error,
+// which is returned as a *v1.ResponseError.
+func Discover(ctx context.Context, doer v1.Doer, registry, name string) (ExtensionList, error) {
+	discoverURL, err := DiscoverURL(registry, name)
+	if err != nil {
+		return ExtensionList{}, err
+	}
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoverURL, nil)
+	if err != nil {
+		return ExtensionList{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := doer.Do(req)
+	if err != nil {
+		return ExtensionList{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if err := v1.ParseErrorResponse(resp); err != nil {
+			return ExtensionList{}, err
+		}
+		return ExtensionList{}, fmt.Errorf("unexpected status %s from %s", resp.Status, req.URL.Redacted())
+	}
+	el := ExtensionList{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, discoverBodyMax)).Decode(&el); err != nil {
+		return ExtensionList{}, fmt.Errorf("failed to decode %s: %w", req.URL.Redacted(), err)
+	}
+	if err := el.Validate(); err != nil {
+		return el, err
+	}
+	return el, nil
+}
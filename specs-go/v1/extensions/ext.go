@@ -14,6 +14,14 @@
 
 package extensions
 
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
 // ExtensionList is the structure returned from discover endpoint defined in /extensions/_oci.md
 type ExtensionList struct {
 	Extensions []Extension `json:"extensions"`
@@ -27,3 +35,143 @@ type Extension struct {
 	Description string   `json:"description,omitempty"`
 	Endpoints   []string `json:"endpoints"`
 }
+
+const (
+	// DiscoverEndpoint is the endpoint of the _oci extension used to discover the other extensions.
+	DiscoverEndpoint = "_oci/ext/discover"
+)
+
+var (
+	// ErrExtensionInvalid is returned when an extension does not follow the rules in /extensions/README.md.
+	ErrExtensionInvalid = errors.New("extension is invalid")
+	// ErrExtensionReserved is returned when an extension other than _oci is listed under a reserved name.
+	ErrExtensionReserved = errors.New("extension name is reserved")
+
+	// segmentRegexp matches each of the <extension>, <component>, and <module> values.
+	segmentRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+	// reservedNames may not be used by extensions outside of the spec.
+	reservedNames = map[string]bool{
+		"_oci":     true,
+		"_catalog": true,
+	}
+)
+
+// ValidateName verifies the name is in the form "_<extension>".
+func ValidateName(name string) error {
+	if !strings.HasPrefix(name, "_") {
+		return fmt.Errorf("%w: name %q must begin with \"_\"", ErrExtensionInvalid, name)
+	}
+	if !segmentRegexp.MatchString(name[1:]) {
+		return fmt.Errorf("%w: name %q must match _%s", ErrExtensionInvalid, name, segmentRegexp.String())
+	}
+	return nil
+}
+
+// ParseEndpoint splits an endpoint in the form "_<extension>/<component>/<module>[?<key>=<value>&...]" into each value.
+// A leading "/v2/" or "/" is ignored, since registries commonly list the full path.
+// The query parameters are returned separately, and are empty when the endpoint has no query.
+func ParseEndpoint(endpoint string) (name, component, module string, query url.Values, err error) {
+	path, rawQuery, _ := strings.Cut(endpoint, "?")
+	query, err = url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", "", nil, fmt.Errorf("%w: endpoint %q has an invalid query: %v", ErrExtensionInvalid, endpoint, err)
+	}
+	parts := strings.Split(normalizeEndpoint(path), "/")
+	if len(parts) != 3 {
+		return "", "", "", nil, fmt.Errorf("%w: endpoint %q must be in the form _<extension>/<component>/<module>", ErrExtensionInvalid, endpoint)
+	}
+	if err := ValidateName(parts[0]); err != nil {
+		return "", "", "", nil, fmt.Errorf("%w: endpoint %q has an invalid extension name", ErrExtensionInvalid, endpoint)
+	}
+	for _, s := range parts[1:] {
+		if !segmentRegexp.MatchString(s) {
+			return "", "", "", nil, fmt.Errorf("%w: endpoint %q contains %q which must match %s", ErrExtensionInvalid, endpoint, s, segmentRegexp.String())
+		}
+	}
+	return parts[0], parts[1], parts[2], query, nil
+}
+
+// Validate verifies the required fields are set and each endpoint belongs to the extension.
+func (e Extension) Validate() error {
+	if err := ValidateName(e.Name); err != nil {
+		return err
+	}
+	if e.URL == "" {
+		return fmt.Errorf("%w: extension %s is missing the url", ErrExtensionInvalid, e.Name)
+	}
+	if u, err := url.Parse(e.URL); err != nil || !u.IsAbs() {
+		return fmt.Errorf("%w: extension %s url %q must be an absolute URL", ErrExtensionInvalid, e.Name, e.URL)
+	}
+	if e.Endpoints == nil {
+		return fmt.Errorf("%w: extension %s is missing the endpoints", ErrExtensionInvalid, e.Name)
+	}
+	for _, endpoint := range e.Endpoints {
+		name, _, _, _, err := ParseEndpoint(endpoint)
+		if err != nil {
+			return err
+		}
+		if name != e.Name {
+			return fmt.Errorf("%w: endpoint %q is not part of extension %s", ErrExtensionInvalid, endpoint, e.Name)
+		}
+	}
+	return nil
+}
+
+// HasEndpoint returns true when the extension lists the endpoint, e.g. "_oci/ext/discover".
+// A leading "/v2/" or "/" is ignored on both the listed and requested endpoints.
+func (e Extension) HasEndpoint(endpoint string) bool {
+	want := normalizeEndpoint(endpoint)
+	for _, listed := range e.Endpoints {
+		if normalizeEndpoint(listed) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate verifies each extension and that extension names are unique.
+// Reserved names other than _oci are rejected with ErrExtensionReserved.
+func (l ExtensionList) Validate() error {
+	if l.Extensions == nil {
+		return fmt.Errorf("%w: extensions field is missing", ErrExtensionInvalid)
+	}
+	seen := map[string]bool{}
+	for _, e := range l.Extensions {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("%w: extension %s is listed more than once", ErrExtensionInvalid, e.Name)
+		}
+		seen[e.Name] = true
+		if reservedNames[e.Name] && e.Name != "_oci" {
+			return fmt.Errorf("%w: %s", ErrExtensionReserved, e.Name)
+		}
+	}
+	return nil
+}
+
+// Lookup returns the extension with the name, e.g. "_oci".
+func (l ExtensionList) Lookup(name string) (Extension, bool) {
+	for _, e := range l.Extensions {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Extension{}, false
+}
+
+// Supports returns true when the extension is listed with the endpoint.
+// An empty endpoint only checks for the extension.
+func (l ExtensionList) Supports(name, endpoint string) bool {
+	e, ok := l.Lookup(name)
+	if !ok {
+		return false
+	}
+	return endpoint == "" || e.HasEndpoint(endpoint)
+}
+
+func normalizeEndpoint(endpoint string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(endpoint, "/v2/"), "/")
+}
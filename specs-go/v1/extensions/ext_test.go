@@ -0,0 +1,189 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+func TestValidateName(t *testing.T) {
+	tt := []struct {
+		name      string
+		expectErr bool
+	}{
+		{name: "_oci"},
+		{name: "_catalog"},
+		{name: "_example"},
+		{name: "_ex-ample.v2"},
+		{name: "_a1_b2"},
+		{name: "", expectErr: true},
+		{name: "_", expectErr: true},
+		{name: "oci", expectErr: true},
+		{name: "__oci", expectErr: true},
+		{name: "_Example", expectErr: true},
+		{name: "_ex--ample", expectErr: true},
+		{name: "_example-", expectErr: true},
+		{name: "_ex/ample", expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateName(tc.name)
+			if tc.expectErr && !errors.Is(err, ErrExtensionInvalid) {
+				t.Errorf("expected error %v, received %v", ErrExtensionInvalid, err)
+			} else if !tc.expectErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tt := []struct {
+		endpoint        string
+		expectName      string
+		expectComponent string
+		expectModule    string
+		expectQuery     string
+		expectErr       bool
+	}{
+		{endpoint: "_oci/ext/discover", expectName: "_oci", expectComponent: "ext", expectModule: "discover"},
+		{endpoint: "/v2/_oci/ext/discover", expectName: "_oci", expectComponent: "ext", expectModule: "discover"},
+		{endpoint: "/_example/sub.component/mod-1", expectName: "_example", expectComponent: "sub.component", expectModule: "mod-1"},
+		{endpoint: "", expectErr: true},
+		{endpoint: "_oci/ext", expectErr: true},
+		{endpoint: "_oci/ext/discover/extra", expectErr: true},
+		{endpoint: "oci/ext/discover", expectErr: true},
+		{endpoint: "_oci//discover", expectErr: true},
+		{endpoint: "_oci/Ext/discover", expectErr: true},
+		{endpoint: "_oci/ext/discover?x=1", expectName: "_oci", expectComponent: "ext", expectModule: "discover", expectQuery: "x=1"},
+		{endpoint: "/v2/_example/sub.component/mod-1?a=b&c=d%2Fe", expectName: "_example", expectComponent: "sub.component", expectModule: "mod-1", expectQuery: "a=b&c=d%2Fe"},
+		{endpoint: "_oci/ext/discover?x=%zz", expectErr: true},
+		{endpoint: "_oci/ext?x=1", expectErr: true},
+		{endpoint: "/v2/v2/_oci/ext/discover", expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			name, component, module, query, err := ParseEndpoint(tc.endpoint)
+			if tc.expectErr {
+				if !errors.Is(err, ErrExtensionInvalid) {
+					t.Errorf("expected error %v, received %v", ErrExtensionInvalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tc.expectName || component != tc.expectComponent || module != tc.expectModule {
+				t.Errorf("expected %s %s %s, received %s %s %s", tc.expectName, tc.expectComponent, tc.expectModule, name, component, module)
+			}
+			if query.Encode() != tc.expectQuery {
+				t.Errorf("expected query %s, received %s", tc.expectQuery, query.Encode())
+			}
+		})
+	}
+}
+
+func TestExtensionListValidate(t *testing.T) {
+	oci := Extension{Name: "_oci", URL: "https://example.com/oci", Endpoints: []string{"_oci/ext/discover"}}
+	tt := []struct {
+		name      string
+		list      ExtensionList
+		expectErr error
+	}{
+		{name: "empty", list: ExtensionList{Extensions: []Extension{}}},
+		{name: "oci", list: ExtensionList{Extensions: []Extension{oci}}},
+		{name: "missing extensions", list: ExtensionList{}, expectErr: ErrExtensionInvalid},
+		{name: "duplicate", list: ExtensionList{Extensions: []Extension{oci, oci}}, expectErr: ErrExtensionInvalid},
+		{
+			name:      "reserved",
+			list:      ExtensionList{Extensions: []Extension{{Name: "_catalog", URL: "https://example.com", Endpoints: []string{}}}},
+			expectErr: ErrExtensionReserved,
+		},
+		{
+			name:      "relative url",
+			list:      ExtensionList{Extensions: []Extension{{Name: "_example", URL: "/docs", Endpoints: []string{}}}},
+			expectErr: ErrExtensionInvalid,
+		},
+		{
+			name:      "missing endpoints",
+			list:      ExtensionList{Extensions: []Extension{{Name: "_example", URL: "https://example.com"}}},
+			expectErr: ErrExtensionInvalid,
+		},
+		{
+			name:      "endpoint from another extension",
+			list:      ExtensionList{Extensions: []Extension{{Name: "_example", URL: "https://example.com", Endpoints: []string{"_oci/ext/discover"}}}},
+			expectErr: ErrExtensionInvalid,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.list.Validate()
+			if tc.expectErr != nil && !errors.Is(err, tc.expectErr) {
+				t.Errorf("expected error %v, received %v", tc.expectErr, err)
+			} else if tc.expectErr == nil && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	l := ExtensionList{Extensions: []Extension{oci}}
+	if !l.Supports("_oci", "") || !l.Supports("_oci", "/v2/_oci/ext/discover") || l.Supports("_oci", "_oci/ext/other") || l.Supports("_example", "") {
+		t.Errorf("unexpected result from Supports")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/_oci/ext/discover":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"extensions":[{"name":"_oci","url":"https://example.com/oci","endpoints":["_oci/ext/discover"]}]}`))
+		case "/v2/repo/_oci/ext/discover":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"extensions":[{"name":"oci","url":"https://example.com/oci","endpoints":[]}]}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[{"code":"UNSUPPORTED","message":"unsupported"}]}`))
+		}
+	}))
+	defer s.Close()
+	ctx := context.Background()
+
+	el, err := Discover(ctx, s.Client(), s.URL, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !el.Supports("_oci", DiscoverEndpoint) {
+		t.Errorf("discover endpoint missing from %v", el)
+	}
+	el, err = Discover(ctx, s.Client(), s.URL+"/", "repo")
+	if !errors.Is(err, ErrExtensionInvalid) || len(el.Extensions) != 1 {
+		t.Errorf("expected the invalid list with error %v, received %v, %v", ErrExtensionInvalid, el, err)
+	}
+	_, err = Discover(ctx, s.Client(), s.URL, "missing")
+	re := &v1.ResponseError{}
+	if !errors.As(err, &re) || re.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 ResponseError, received %v", err)
+	}
+	if _, err := Discover(ctx, s.Client(), "registry.example.com", ""); err == nil {
+		t.Errorf("expected an error for a registry without a scheme")
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	specs "github.com/opencontainers/distribution-spec/specs-go"
+)
+
+// TagHeader lists the tags that were pushed with end-7b.
+const TagHeader = "OCI-Tag"
+
+// Feature is the ID of an entry in the feature table, describing when endpoints and headers were added to the spec.
+type Feature string
+
+const (
+	// FeaturePull includes the endpoints to check for and pull content.
+	FeaturePull Feature = "pull"
+	// FeaturePush includes the endpoints to push blobs and manifests.
+	FeaturePush Feature = "push"
+	// FeatureTagList includes the endpoints to list tags with pagination.
+	FeatureTagList Feature = "tag-list"
+	// FeatureDelete includes the endpoints to delete manifests, tags, and blobs.
+	FeatureDelete Feature = "delete"
+	// FeatureBlobMount includes the endpoint to mount a blob from another repository.
+	FeatureBlobMount Feature = "blob-mount"
+	// FeatureBlobUploadStatus includes the endpoint to get the status of a blob upload session.
+	FeatureBlobUploadStatus Feature = "blob-upload-status"
+	// FeatureBlobMountAnonymous allows a blob mount without the from parameter.
+	FeatureBlobMountAnonymous Feature = "blob-mount-anonymous"
+	// FeatureReferrers includes the referrers API and the OCI-Subject header returned when a manifest with a subject is pushed.
+	FeatureReferrers Feature = "referrers"
+	// FeatureChunkMinLength includes the header for the minimum chunk size of a blob upload session.
+	FeatureChunkMinLength Feature = "chunk-min-length"
+	// FeatureWarning includes the Warning header on any response.
+	FeatureWarning Feature = "warning"
+	// FeatureManifestPutTags includes the endpoint to push a manifest by digest with tag query parameters.
+	FeatureManifestPutTags Feature = "manifest-put-tags"
+	// FeatureBlobUploadAlgorithm includes the endpoint to open a blob upload session for a digest algorithm.
+	FeatureBlobUploadAlgorithm Feature = "blob-upload-algorithm"
+	// FeatureBlobUploadCancel includes the endpoint to cancel a blob upload session.
+	FeatureBlobUploadCancel Feature = "blob-upload-cancel"
+	// FeatureDigestHeader requires the Docker-Content-Digest header on blob and manifest responses.
+	FeatureDigestHeader Feature = "digest-header"
+)
+
+// FeatureInfo is an entry in the feature table.
+type FeatureInfo struct {
+	ID Feature
+	// Since is the first version of the spec to include the feature.
+	// It is unset for features that have not been released.
+	Since specs.SpecVersion
+	// Endpoints and Headers are added to the spec by the feature.
+	Endpoints []EndpointID
+	Headers   []string
+	// Unreleased features are only included in a development version at or above the current version of the spec.
+	Unreleased bool
+}
+
+var (
+	specV1_0 = specs.SpecVersion{Major: 1, Minor: 0}
+	specV1_1 = specs.SpecVersion{Major: 1, Minor: 1}
+)
+
+var features = []FeatureInfo{
+	{FeaturePull, specV1_0, []EndpointID{EndpointPing, EndpointBlobGet, EndpointManifestGet}, nil, false},
+	{FeaturePush, specV1_0, []EndpointID{EndpointBlobUploadStart, EndpointBlobUploadDigest, EndpointBlobUploadChunk, EndpointBlobUploadFinish, EndpointManifestPut}, []string{UploadUUIDHeader}, false},
+	{FeatureTagList, specV1_0, []EndpointID{EndpointTagList, EndpointTagListPaginated}, []string{LinkHeader}, false},
+	{FeatureDelete, specV1_0, []EndpointID{EndpointManifestDelete, EndpointBlobDelete}, nil, false},
+	{FeatureBlobMount, specV1_0, []EndpointID{EndpointBlobMount}, nil, false},
+	{FeatureBlobUploadStatus, specV1_1, []EndpointID{EndpointBlobUploadStatus}, nil, false},
+	{FeatureBlobMountAnonymous, specV1_1, nil, nil, false},
+	{FeatureReferrers, specV1_1, []EndpointID{EndpointReferrers, EndpointReferrersFiltered}, []string{SubjectHeader, FiltersAppliedHeader}, false},
+	{FeatureChunkMinLength, specV1_1, nil, []string{ChunkMinLengthHeader}, false},
+	{FeatureWarning, specV1_1, nil, []string{WarningHeader}, false},
+	{FeatureManifestPutTags, specs.SpecVersion{}, []EndpointID{EndpointManifestPutTags}, []string{TagHeader}, true},
+	{FeatureBlobUploadAlgorithm, specs.SpecVersion{}, []EndpointID{EndpointBlobUploadAlgorithm}, nil, true},
+	{FeatureBlobUploadCancel, specs.SpecVersion{}, []EndpointID{EndpointBlobUploadCancel}, nil, true},
+	{FeatureDigestHeader, specs.SpecVersion{}, nil, []string{ContentDigestHeader}, true},
+}
+
+// Features returns a copy of the feature table.
+func Features() []FeatureInfo {
+	ret := make([]FeatureInfo, len(features))
+	for i, f := range features {
+		ret[i] = f.clone()
+	}
+	return ret
+}
+
+// LookupFeature returns the entry from the feature table for the ID.
+func LookupFeature(id Feature) (FeatureInfo, bool) {
+	for _, f := range features {
+		if f.ID == id {
+			return f.clone(), true
+		}
+	}
+	return FeatureInfo{}, false
+}
+
+// FeaturesFor returns the entries from the feature table included in a version of the spec.
+func FeaturesFor(v specs.SpecVersion) []FeatureInfo {
+	ret := []FeatureInfo{}
+	for _, f := range features {
+		if f.includedIn(v) {
+			ret = append(ret, f.clone())
+		}
+	}
+	return ret
+}
+
+// FeatureSupported reports if a version of the spec includes the feature.
+func FeatureSupported(v specs.SpecVersion, id Feature) bool {
+	f, ok := LookupFeature(id)
+	return ok && f.includedIn(v)
+}
+
+// EndpointSince returns the first version of the spec to include the endpoint.
+// Endpoints from unreleased features return the current development version.
+func EndpointSince(id EndpointID) (specs.SpecVersion, bool) {
+	for _, f := range features {
+		for _, e := range f.Endpoints {
+			if e != id {
+				continue
+			}
+			if f.Unreleased {
+				return specs.Current(), true
+			}
+			return f.Since, true
+		}
+	}
+	return specs.SpecVersion{}, false
+}
+
+// includedIn reports if a version of the spec includes the feature.
+func (f FeatureInfo) includedIn(v specs.SpecVersion) bool {
+	if f.Unreleased {
+		return v.Dev && v.AtLeast(specs.Current())
+	}
+	return v.AtLeast(f.Since)
+}
+
+func (f FeatureInfo) clone() FeatureInfo {
+	f.Endpoints = append([]EndpointID{}, f.Endpoints...)
+	f.Headers = append([]string{}, f.Headers...)
+	return f
+}
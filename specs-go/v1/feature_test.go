@@ -0,0 +1,105 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"testing"
+
+	specs "github.com/opencontainers/distribution-spec/specs-go"
+)
+
+func TestFeatureSupported(t *testing.T) {
+	tt := []struct {
+		version string
+		feature Feature
+		expect  bool
+	}{
+		{version: "1.0", feature: FeaturePull, expect: true},
+		{version: "1.0", feature: FeatureBlobMount, expect: true},
+		{version: "1.0", feature: FeatureReferrers, expect: false},
+		{version: "1.0.1", feature: FeatureBlobUploadStatus, expect: false},
+		{version: "1.1", feature: FeatureReferrers, expect: true},
+		{version: "1.1", feature: FeatureWarning, expect: true},
+		{version: "1.1", feature: FeatureManifestPutTags, expect: false},
+		{version: "1.1", feature: FeatureBlobUploadCancel, expect: false},
+		{version: "1.1", feature: FeatureDigestHeader, expect: false},
+		{version: "1.1+dev", feature: FeatureReferrers, expect: true},
+		{version: "1.1+dev", feature: FeatureManifestPutTags, expect: false},
+		{version: "1.1.1", feature: FeatureReferrers, expect: true},
+		{version: "1.1.1", feature: FeatureManifestPutTags, expect: false},
+		{version: "1.1.1", feature: FeatureBlobUploadAlgorithm, expect: false},
+		{version: "1.1.1", feature: FeatureBlobUploadCancel, expect: false},
+		{version: "1.1.1", feature: FeatureDigestHeader, expect: false},
+		{version: "1.1.1+dev", feature: FeatureManifestPutTags, expect: true},
+		{version: "1.1.1+dev", feature: FeatureBlobUploadAlgorithm, expect: true},
+		{version: "1.1.1+dev", feature: FeatureBlobUploadCancel, expect: true},
+		{version: "1.1.1+dev", feature: FeatureDigestHeader, expect: true},
+		{version: specs.Version, feature: FeatureDigestHeader, expect: true},
+		{version: "1.2", feature: FeatureManifestPutTags, expect: false},
+		{version: "1.2+dev", feature: FeatureManifestPutTags, expect: true},
+		{version: specs.Version, feature: Feature("unknown"), expect: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.version+" "+string(tc.feature), func(t *testing.T) {
+			v, err := specs.ParseVersion(tc.version)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result := FeatureSupported(v, tc.feature); result != tc.expect {
+				t.Errorf("expected %t, received %t", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	seen := map[EndpointID]bool{}
+	for _, f := range Features() {
+		if lf, ok := LookupFeature(f.ID); !ok || lf.Since != f.Since || lf.Unreleased != f.Unreleased {
+			t.Errorf("lookup of %s failed", f.ID)
+		}
+		expectSince := f.Since
+		if f.Unreleased {
+			expectSince = specs.Current()
+		}
+		for _, e := range f.Endpoints {
+			if seen[e] {
+				t.Errorf("endpoint %s is listed in more than one feature", e)
+			}
+			seen[e] = true
+			if since, ok := EndpointSince(e); !ok || since != expectSince {
+				t.Errorf("expected endpoint %s since %s, received %s", e, expectSince, since)
+			}
+		}
+	}
+	// every endpoint in the spec is in the feature table
+	for _, e := range Endpoints() {
+		if !seen[e.ID] {
+			t.Errorf("endpoint %s is missing from the feature table", e.ID)
+		}
+	}
+	v11, _ := specs.ParseVersion("1.1")
+	v111, _ := specs.ParseVersion("1.1.1")
+	dev := specs.Current()
+	if len(FeaturesFor(v11)) != len(FeaturesFor(v111)) || len(FeaturesFor(v111)) >= len(FeaturesFor(dev)) || len(FeaturesFor(dev)) != len(Features()) {
+		t.Errorf("unexpected features for 1.1, 1.1.1, and %s", dev)
+	}
+	// the returned entries are copies
+	f, _ := LookupFeature(FeaturePull)
+	f.Endpoints[0] = EndpointBlobDelete
+	if f2, _ := LookupFeature(FeaturePull); f2.Endpoints[0] != EndpointPing {
+		t.Errorf("feature table was modified through a returned entry")
+	}
+}
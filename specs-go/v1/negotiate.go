@@ -0,0 +1,282 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrAcceptInvalid is returned when an Accept header cannot be parsed.
+	ErrAcceptInvalid = errors.New("accept header syntax is invalid")
+	// ErrNotAcceptable is returned when none of the available media types are allowed by the Accept header.
+	ErrNotAcceptable = errors.New("no available media type is acceptable")
+)
+
+// MediaRange is a single entry from an Accept header, defined in RFC 9110 (section 12.5.1).
+type MediaRange struct {
+	// Type and Subtype are lower case, and may be "*" for a wildcard.
+	Type    string
+	Subtype string
+	// Params are the media type parameters, excluding the weight, with lower case names.
+	Params map[string]string
+	// Q is the weight between 0 and 1, where 0 marks the media type as not acceptable.
+	Q float64
+}
+
+// String formats the media range as an Accept header value.
+func (mr MediaRange) String() string {
+	var sb strings.Builder
+	sb.WriteString(mr.Type + "/" + mr.Subtype)
+	for _, k := range sortedKeys(mr.Params) {
+		sb.WriteString(";" + k + "=" + quoteValue(mr.Params[k]))
+	}
+	if mr.Q != 1 {
+		sb.WriteString(";q=" + strconv.FormatFloat(mr.Q, 'f', -1, 64))
+	}
+	return sb.String()
+}
+
+// Match reports if the media type is included in the range,
+// along with a precedence that is higher for more specific ranges.
+// Parameters in the range must be present with the same value in the media type.
+func (mr MediaRange) Match(mediaType string) (int, bool) {
+	mt, err := parseMediaType(mediaType)
+	if err != nil {
+		return 0, false
+	}
+	precedence := 0
+	switch {
+	case mr.Type == "*" && mr.Subtype == "*":
+	case mr.Type == mt.Type && mr.Subtype == "*":
+		precedence = 1
+	case mr.Type == mt.Type && mr.Subtype == mt.Subtype:
+		precedence = 2
+	default:
+		return 0, false
+	}
+	for k, v := range mr.Params {
+		if mtv, ok := mt.Params[k]; !ok || !strings.EqualFold(mtv, v) {
+			return 0, false
+		}
+	}
+	return precedence*1000 + len(mr.Params), true
+}
+
+// ParseAccept parses each Accept header value into a combined list of media ranges, preserving the order.
+func ParseAccept(values ...string) ([]MediaRange, error) {
+	ranges := []MediaRange{}
+	for _, value := range values {
+		p := headerParser{s: value}
+		for {
+			p.skipListSep()
+			if p.done() {
+				break
+			}
+			mr, err := p.mediaRange(true)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v (%q)", ErrAcceptInvalid, err, value)
+			}
+			ranges = append(ranges, mr)
+			p.skipOWS()
+			if !p.done() && p.peek() != ',' {
+				return nil, fmt.Errorf("%w: unexpected character at offset %d (%q)", ErrAcceptInvalid, p.i, value)
+			}
+		}
+	}
+	return ranges, nil
+}
+
+// NegotiateMediaType selects the media type to return from the available list using the Accept header values.
+// The available list is in the order preferred by the registry,
+// typically the media type of the stored manifest followed by any types it can be converted to.
+//
+// Each available media type is given the weight of the most specific matching media range.
+// The highest weight is selected, with ties going to the media type matched by the earliest range in the Accept header,
+// and then to the earliest entry in the available list.
+// Without an Accept header, the first available media type is returned.
+// ErrNotAcceptable is returned when no media type has a non-zero weight,
+// registries may respond with a 406 or fall back to their default representation.
+func NegotiateMediaType(accept []string, available []string) (string, error) {
+	if len(available) == 0 {
+		return "", ErrNotAcceptable
+	}
+	ranges, err := ParseAccept(accept...)
+	if err != nil {
+		return "", err
+	}
+	if len(ranges) == 0 {
+		return available[0], nil
+	}
+	best := ""
+	bestQ := 0.0
+	bestPos := len(ranges)
+	for _, mt := range available {
+		q, pos := 0.0, -1
+		precedence := -1
+		for i, mr := range ranges {
+			if p, ok := mr.Match(mt); ok && p > precedence {
+				precedence, q, pos = p, mr.Q, i
+			}
+		}
+		if pos < 0 || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && pos < bestPos) {
+			best, bestQ, bestPos = mt, q, pos
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%w: accept %q, available %q", ErrNotAcceptable, strings.Join(accept, ", "), available)
+	}
+	return best, nil
+}
+
+// parseMediaType parses a Content-Type value, which cannot contain wildcards or a weight.
+func parseMediaType(value string) (MediaRange, error) {
+	p := headerParser{s: value}
+	p.skipOWS()
+	mr, err := p.mediaRange(false)
+	if err != nil {
+		return mr, err
+	}
+	p.skipOWS()
+	if !p.done() {
+		return mr, fmt.Errorf("unexpected character at offset %d", p.i)
+	}
+	return mr, nil
+}
+
+// mediaRange parses the grammar from RFC 9110 (section 12.5.1):
+//
+//	media-range = ( "*/*" / ( type "/" "*" ) / ( type "/" subtype ) ) parameters
+//	weight      = OWS ";" OWS "q=" qvalue
+//
+// Any accept-ext parameters after the weight are ignored.
+func (p *headerParser) mediaRange(accept bool) (MediaRange, error) {
+	mr := MediaRange{Params: map[string]string{}, Q: 1}
+	mr.Type = strings.ToLower(p.token())
+	if mr.Type == "" {
+		return mr, fmt.Errorf("missing type at offset %d", p.i)
+	}
+	if p.done() || p.peek() != '/' {
+		return mr, fmt.Errorf("expected \"/\" at offset %d", p.i)
+	}
+	p.i++
+	mr.Subtype = strings.ToLower(p.token())
+	if mr.Subtype == "" {
+		return mr, fmt.Errorf("missing subtype at offset %d", p.i)
+	}
+	if (mr.Type == "*" || mr.Subtype == "*") && !accept {
+		return mr, fmt.Errorf("wildcards are not allowed in a media type")
+	}
+	if mr.Type == "*" && mr.Subtype != "*" {
+		return mr, fmt.Errorf("a wildcard type requires a wildcard subtype")
+	}
+	weighted := false
+	for {
+		p.skipOWS()
+		if p.done() || p.peek() != ';' {
+			break
+		}
+		p.i++
+		p.skipOWS()
+		name := strings.ToLower(p.token())
+		if name == "" {
+			return mr, fmt.Errorf("missing parameter name at offset %d", p.i)
+		}
+		if p.done() || p.peek() != '=' {
+			return mr, fmt.Errorf("expected \"=\" at offset %d", p.i)
+		}
+		p.i++
+		var value string
+		if !p.done() && p.peek() == '"' {
+			v, err := p.quotedString()
+			if err != nil {
+				return mr, err
+			}
+			value = v
+		} else {
+			value = p.token()
+		}
+		switch {
+		case weighted:
+			// accept-ext
+		case accept && name == "q":
+			q, err := parseQValue(value)
+			if err != nil {
+				return mr, err
+			}
+			mr.Q = q
+			weighted = true
+		default:
+			mr.Params[name] = value
+		}
+	}
+	return mr, nil
+}
+
+func (p *headerParser) token() string {
+	start := p.i
+	for !p.done() && isTokenChar(p.peek()) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// parseQValue parses a weight with up to three decimal places.
+func parseQValue(value string) (float64, error) {
+	whole, frac, hasFrac := strings.Cut(value, ".")
+	if (whole != "0" && whole != "1") || len(frac) > 3 || strings.Trim(frac, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid weight %q", value)
+	}
+	if whole == "1" && strings.Trim(frac, "0") != "" {
+		return 0, fmt.Errorf("invalid weight %q", value)
+	}
+	if hasFrac && frac == "" {
+		frac = "0"
+	}
+	q, err := strconv.ParseFloat(whole+"."+frac+"0", 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid weight %q", value)
+	}
+	return q, nil
+}
+
+// quoteValue returns the parameter value as a token, or a quoted-string when needed.
+func quoteValue(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return quoteString(s)
+		}
+	}
+	return s
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
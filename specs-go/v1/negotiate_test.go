@@ -0,0 +1,225 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"testing"
+
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	mtDockerV1       = "application/vnd.docker.distribution.manifest.v1+json"
+	mtDockerV1Signed = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	mtDockerV2       = "application/vnd.docker.distribution.manifest.v2+json"
+	mtDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// TestNegotiateContentNegotiationDoc mirrors the table in content-negotiation.md.
+// The registry in that table falls back to a signed v1 manifest when nothing else is acceptable,
+// and prefers it when the client accepts every type.
+func TestNegotiateContentNegotiationDoc(t *testing.T) {
+	multi := []string{mtDockerV1Signed, mtDockerV2, mtDockerList}
+	single := []string{mtDockerV1Signed, mtDockerV2}
+	tt := []struct {
+		name      string
+		accept    []string
+		available []string
+		expect    string
+	}{
+		{name: "multi not present", available: multi, expect: mtDockerV1Signed},
+		{name: "multi any", accept: []string{"*/*"}, available: multi, expect: mtDockerV1Signed},
+		{name: "multi json", accept: []string{"application/json"}, available: multi, expect: mtDockerV1Signed},
+		{name: "multi v1", accept: []string{mtDockerV1}, available: multi, expect: mtDockerV1Signed},
+		{name: "multi v2", accept: []string{mtDockerV2}, available: multi, expect: mtDockerV2},
+		{name: "multi list", accept: []string{mtDockerList}, available: multi, expect: mtDockerList},
+		{name: "multi list v2 v1", accept: []string{mtDockerList, mtDockerV2, mtDockerV1}, available: multi, expect: mtDockerList},
+		{name: "multi v2 list v1", accept: []string{mtDockerV2, mtDockerList, mtDockerV1}, available: multi, expect: mtDockerV2},
+		{name: "single not present", available: single, expect: mtDockerV1Signed},
+		{name: "single any", accept: []string{"*/*"}, available: single, expect: mtDockerV1Signed},
+		{name: "single json", accept: []string{"application/json"}, available: single, expect: mtDockerV1Signed},
+		{name: "single v1", accept: []string{mtDockerV1}, available: single, expect: mtDockerV1Signed},
+		{name: "single v2", accept: []string{mtDockerV2}, available: single, expect: mtDockerV2},
+		{name: "single list", accept: []string{mtDockerList}, available: single, expect: mtDockerV1Signed},
+		{name: "single list v2 v1", accept: []string{mtDockerList, mtDockerV2, mtDockerV1}, available: single, expect: mtDockerV2},
+		{name: "single v2 list v1", accept: []string{mtDockerV2, mtDockerList, mtDockerV1}, available: single, expect: mtDockerV2},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := NegotiateMediaType(tc.accept, tc.available)
+			if errors.Is(err, ErrNotAcceptable) {
+				result = mtDockerV1Signed
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expect {
+				t.Errorf("expected %s, received %s", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	oci := []string{image.MediaTypeImageIndex, image.MediaTypeImageManifest}
+	tt := []struct {
+		name      string
+		accept    []string
+		available []string
+		expect    string
+		expectErr error
+	}{
+		{
+			name:      "stored type only",
+			accept:    []string{image.MediaTypeImageManifest},
+			available: []string{image.MediaTypeImageManifest},
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "separate header values",
+			accept:    []string{image.MediaTypeImageManifest, image.MediaTypeImageIndex},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "comma separated list",
+			accept:    []string{image.MediaTypeImageManifest + ", " + image.MediaTypeImageIndex},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "weight",
+			accept:    []string{image.MediaTypeImageIndex + ";q=0.5, " + image.MediaTypeImageManifest + ";q=0.8"},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "type wildcard",
+			accept:    []string{"application/*"},
+			available: oci,
+			expect:    image.MediaTypeImageIndex,
+		},
+		{
+			name:      "wildcard with lower weight",
+			accept:    []string{"*/*;q=0.1", image.MediaTypeImageManifest},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "specific range overrides wildcard",
+			accept:    []string{"*/*", image.MediaTypeImageIndex + ";q=0"},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "case insensitive",
+			accept:    []string{"Application/VND.OCI.Image.Manifest.V1+JSON"},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "parameter match",
+			accept:    []string{"application/json; charset=utf-8"},
+			available: []string{"application/json; charset=UTF-8"},
+			expect:    "application/json; charset=UTF-8",
+		},
+		{
+			name:      "parameter mismatch",
+			accept:    []string{"application/json;charset=utf-16"},
+			available: []string{"application/json;charset=utf-8"},
+			expectErr: ErrNotAcceptable,
+		},
+		{
+			name:      "accept-ext ignored",
+			accept:    []string{image.MediaTypeImageManifest + ";q=0.5;ext=\"x, y\""},
+			available: oci,
+			expect:    image.MediaTypeImageManifest,
+		},
+		{
+			name:      "not acceptable",
+			accept:    []string{mtDockerV2},
+			available: oci,
+			expectErr: ErrNotAcceptable,
+		},
+		{
+			name:      "zero weight",
+			accept:    []string{"*/*;q=0"},
+			available: oci,
+			expectErr: ErrNotAcceptable,
+		},
+		{
+			name:      "nothing available",
+			available: []string{},
+			expectErr: ErrNotAcceptable,
+		},
+		{
+			name:      "empty header",
+			accept:    []string{""},
+			available: oci,
+			expect:    image.MediaTypeImageIndex,
+		},
+		{
+			name:      "invalid weight",
+			accept:    []string{image.MediaTypeImageManifest + ";q=2"},
+			available: oci,
+			expectErr: ErrAcceptInvalid,
+		},
+		{
+			name:      "invalid wildcard",
+			accept:    []string{"*/json"},
+			available: oci,
+			expectErr: ErrAcceptInvalid,
+		},
+		{
+			name:      "missing subtype",
+			accept:    []string{"application"},
+			available: oci,
+			expectErr: ErrAcceptInvalid,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := NegotiateMediaType(tc.accept, tc.available)
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected error %v, received %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result != tc.expect {
+				t.Errorf("expected %s, received %s", tc.expect, result)
+			}
+		})
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	ranges, err := ParseAccept(`text/plain; q=0.5, application/json;charset="utf-8", */*;q=0.01`)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	expect := []string{"text/plain;q=0.5", "application/json;charset=utf-8", "*/*;q=0.01"}
+	if len(ranges) != len(expect) {
+		t.Fatalf("expected %d ranges, received %d", len(expect), len(ranges))
+	}
+	for i := range expect {
+		if ranges[i].String() != expect[i] {
+			t.Errorf("range %d: expected %s, received %s", i, expect[i], ranges[i].String())
+		}
+	}
+}
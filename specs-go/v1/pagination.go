@@ -0,0 +1,403 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// LinkHeader is the name of the HTTP header used for pagination.
+	LinkHeader = "Link"
+	// LinkRelNext is the relation type of the link to the next page.
+	LinkRelNext = "next"
+)
+
+// ErrLinkInvalid is returned when a Link header cannot be parsed.
+var ErrLinkInvalid = errors.New("link header is invalid")
+
+// Doer sends HTTP requests, and is implemented by *http.Client.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Link is a single link from an RFC 5988 Link header.
+type Link struct {
+	// URL is the target of the link, which may be relative.
+	URL string
+	// Rel contains each relation type from the rel parameter.
+	Rel []string
+	// Params contains the other parameters, with lowercase names.
+	Params map[string]string
+}
+
+// HasRel returns true when the link includes the relation type.
+func (l Link) HasRel(rel string) bool {
+	for _, r := range l.Rel {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the link as a Link header value.
+func (l Link) String() string {
+	s := "<" + l.URL + ">"
+	if len(l.Rel) > 0 {
+		s += "; rel=" + quoteString(strings.Join(l.Rel, " "))
+	}
+	keys := make([]string, 0, len(l.Params))
+	for k := range l.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s += "; " + k + "=" + quoteString(l.Params[k])
+	}
+	return s
+}
+
+// ParseLinks parses the values of Link headers, each of which may contain a comma separated list of links.
+func ParseLinks(values ...string) ([]Link, error) {
+	links := []Link{}
+	for _, value := range values {
+		p := headerParser{s: value}
+		for {
+			p.skipListSep()
+			if p.done() {
+				break
+			}
+			l, err := parseLink(&p)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s (%q)", ErrLinkInvalid, err.Error(), value)
+			}
+			links = append(links, l)
+		}
+	}
+	return links, nil
+}
+
+// parseLink parses `"<" URI-Reference ">" *( OWS ";" OWS link-param )`.
+func parseLink(p *headerParser) (Link, error) {
+	l := Link{Params: map[string]string{}}
+	if p.peek() != '<' {
+		return l, fmt.Errorf("expected \"<\" at offset %d", p.i)
+	}
+	end := strings.IndexByte(p.s[p.i:], '>')
+	if end < 0 {
+		return l, fmt.Errorf("missing \">\"")
+	}
+	l.URL = strings.TrimSpace(p.s[p.i+1 : p.i+end])
+	p.i += end + 1
+	for {
+		p.skipOWS()
+		if p.done() || p.peek() == ',' {
+			return l, nil
+		}
+		if p.peek() != ';' {
+			return l, fmt.Errorf("expected \";\" at offset %d", p.i)
+		}
+		p.i++
+		p.skipOWS()
+		start := p.i
+		for !p.done() && isTokenChar(p.peek()) {
+			p.i++
+		}
+		name := strings.ToLower(p.s[start:p.i])
+		if name == "" {
+			return l, fmt.Errorf("missing parameter name at offset %d", p.i)
+		}
+		value := ""
+		p.skipOWS()
+		if !p.done() && p.peek() == '=' {
+			p.i++
+			p.skipOWS()
+			if !p.done() && p.peek() == '"' {
+				v, err := p.quotedString()
+				if err != nil {
+					return l, err
+				}
+				value = v
+			} else {
+				start := p.i
+				for !p.done() && isTokenChar(p.peek()) {
+					p.i++
+				}
+				value = p.s[start:p.i]
+			}
+		}
+		if name == "rel" {
+			// only the first rel parameter is used, per RFC 5988
+			if l.Rel == nil {
+				l.Rel = strings.Fields(value)
+			}
+			continue
+		}
+		l.Params[name] = value
+	}
+}
+
+func isTokenChar(c byte) bool {
+	return c > 0x20 && c < 0x7f && !strings.ContainsRune("()<>@,;:\\\"/[]?={}", rune(c))
+}
+
+// NextLink returns the resolved URL of the rel="next" link in the response, or nil when there is no next page.
+// Relative links are resolved against the request URL.
+func NextLink(resp *http.Response) (*url.URL, error) {
+	links, err := ParseLinks(resp.Header.Values(LinkHeader)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range links {
+		if !l.HasRel(LinkRelNext) {
+			continue
+		}
+		u, err := url.Parse(l.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrLinkInvalid, err)
+		}
+		if resp.Request != nil && resp.Request.URL != nil {
+			u = resp.Request.URL.ResolveReference(u)
+		}
+		return u, nil
+	}
+	return nil, nil
+}
+
+// SetNextLink sets the Link header to the next page.
+// The link may be relative, such as "/v2/<name>/tags/list?n=10&last=tag".
+func SetNextLink(h http.Header, next string) {
+	h.Set(LinkHeader, Link{URL: next, Rel: []string{LinkRelNext}}.String())
+}
+
+// TagListPage returns the page of tags for the n and last query parameters of end-8b.
+// The tags are sorted, any tags up to and including last are skipped,
+// and at most n tags are returned when n is not negative.
+// The returned bool is true when more tags remain after the page.
+func TagListPage(tags []string, n int, last string) ([]string, bool) {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	if last != "" {
+		i := sort.Search(len(sorted), func(i int) bool { return sorted[i] > last })
+		sorted = sorted[i:]
+	}
+	if n < 0 || n >= len(sorted) {
+		return sorted, false
+	}
+	if n == 0 {
+		// a Link header must not be included when n is zero
+		return []string{}, false
+	}
+	return sorted[:n], true
+}
+
+// TagListNextLink returns the relative URL to the page after the tags returned by TagListPage.
+func TagListNextLink(name string, n int, page []string) string {
+	q := url.Values{}
+	q.Set("n", strconv.Itoa(n))
+	if len(page) > 0 {
+		q.Set("last", page[len(page)-1])
+	}
+	return (&url.URL{Path: "/v2/" + name + "/tags/list", RawQuery: q.Encode()}).String()
+}
+
+// pageIterator requests each page of a paginated response.
+type pageIterator struct {
+	doer    Doer
+	next    *url.URL
+	header  http.Header
+	visited map[string]bool
+}
+
+func newPageIterator(doer Doer, start string, header http.Header) (*pageIterator, error) {
+	u, err := url.Parse(start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url %q: %w", start, err)
+	}
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+	return &pageIterator{
+		doer:    doer,
+		next:    u,
+		header:  header,
+		visited: map[string]bool{},
+	}, nil
+}
+
+// fetch requests the next page, decoding the body into v, and returns the response with a closed body.
+func (pi *pageIterator) fetch(ctx context.Context, v interface{}) (*http.Response, error) {
+	if pi.next == nil {
+		return nil, io.EOF
+	}
+	u := pi.next
+	if pi.visited[u.String()] {
+		return nil, fmt.Errorf("pagination loop detected, %s was already requested", u.Redacted())
+	}
+	pi.visited[u.String()] = true
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range pi.header {
+		req.Header[k] = append([]string{}, vals...)
+	}
+	resp, err := pi.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := ParseErrorResponse(resp); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, u.Redacted())
+	}
+	if resp.Body == nil {
+		return nil, fmt.Errorf("response from %s has no body", u.Redacted())
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", u.Redacted(), err)
+	}
+	pi.next, err = NextLink(resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// TagIterator walks each page of a tag listing (end-8a and end-8b).
+type TagIterator struct {
+	pi *pageIterator
+	n  int
+}
+
+// NewTagIterator returns an iterator over the tags at tagListURL, e.g. "https://registry.example.org/v2/<name>/tags/list".
+// When n is positive, it is sent as the page size.
+// Pages are followed with the Link header, falling back to the last parameter for registries that do not send a Link header.
+func NewTagIterator(doer Doer, tagListURL string, n int) (*TagIterator, error) {
+	pi, err := newPageIterator(doer, tagListURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		q := pi.next.Query()
+		q.Set("n", strconv.Itoa(n))
+		pi.next.RawQuery = q.Encode()
+	}
+	return &TagIterator{pi: pi, n: n}, nil
+}
+
+// Next returns the next page of tags, or io.EOF when every page has been returned.
+func (ti *TagIterator) Next(ctx context.Context) (TagList, error) {
+	tl := TagList{}
+	u := ti.pi.next
+	if _, err := ti.pi.fetch(ctx, &tl); err != nil {
+		return TagList{}, err
+	}
+	if ti.pi.next == nil && ti.n > 0 && len(tl.Tags) == ti.n {
+		// without a Link header, a full page may be followed by more tags
+		next := *u
+		q := next.Query()
+		q.Set("n", strconv.Itoa(ti.n))
+		q.Set("last", tl.Tags[len(tl.Tags)-1])
+		next.RawQuery = q.Encode()
+		ti.pi.next = &next
+	}
+	return tl, nil
+}
+
+// All returns the tags from every remaining page.
+func (ti *TagIterator) All(ctx context.Context) ([]string, error) {
+	tags := []string{}
+	for {
+		tl, err := ti.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return tags, nil
+		}
+		if err != nil {
+			return tags, err
+		}
+		tags = append(tags, tl.Tags...)
+	}
+}
+
+// ReferrersIterator walks each page of a referrers response (end-12a and end-12b).
+type ReferrersIterator struct {
+	pi             *pageIterator
+	filtersApplied []string
+}
+
+// NewReferrersIterator returns an iterator over the referrers at referrersURL, e.g. "https://registry.example.org/v2/<name>/referrers/<digest>".
+func NewReferrersIterator(doer Doer, referrersURL string) (*ReferrersIterator, error) {
+	pi, err := newPageIterator(doer, referrersURL, http.Header{"Accept": {image.MediaTypeImageIndex}})
+	if err != nil {
+		return nil, err
+	}
+	return &ReferrersIterator{pi: pi}, nil
+}
+
+// Next returns the next page of referrers, or io.EOF when every page has been returned.
+func (ri *ReferrersIterator) Next(ctx context.Context) (image.Index, error) {
+	index := image.Index{}
+	resp, err := ri.pi.fetch(ctx, &index)
+	if err != nil {
+		return image.Index{}, err
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, image.MediaTypeImageIndex) {
+		return image.Index{}, fmt.Errorf("unexpected content type %q for referrers response", ct)
+	}
+	ri.filtersApplied = nil
+	for _, f := range strings.Split(resp.Header.Get(FiltersAppliedHeader), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			ri.filtersApplied = append(ri.filtersApplied, f)
+		}
+	}
+	return index, nil
+}
+
+// FiltersApplied returns the filters from the OCI-Filters-Applied header of the last page.
+func (ri *ReferrersIterator) FiltersApplied() []string {
+	return ri.filtersApplied
+}
+
+// All returns the descriptors from every remaining page.
+func (ri *ReferrersIterator) All(ctx context.Context) ([]image.Descriptor, error) {
+	descs := []image.Descriptor{}
+	for {
+		index, err := ri.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return descs, nil
+		}
+		if err != nil {
+			return descs, err
+		}
+		descs = append(descs, index.Manifests...)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	tt := []struct {
+		name      string
+		values    []string
+		expect    []Link
+		expectErr bool
+	}{
+		{
+			name:   "empty",
+			expect: []Link{},
+		},
+		{
+			name:   "next",
+			values: []string{`</v2/repo/tags/list?n=10&last=b>; rel="next"`},
+			expect: []Link{{URL: "/v2/repo/tags/list?n=10&last=b", Rel: []string{"next"}}},
+		},
+		{
+			name:   "unquoted rel and params",
+			values: []string{`<https://example.com/page2>;rel=next;title="Page 2"`},
+			expect: []Link{{URL: "https://example.com/page2", Rel: []string{"next"}, Params: map[string]string{"title": "Page 2"}}},
+		},
+		{
+			name:   "list and multiple rel",
+			values: []string{`</a>; rel="prev", </b>; rel="next last"`, `</c>`},
+			expect: []Link{
+				{URL: "/a", Rel: []string{"prev"}},
+				{URL: "/b", Rel: []string{"next", "last"}},
+				{URL: "/c"},
+			},
+		},
+		{
+			name:   "first rel wins",
+			values: []string{`</a>; rel="next"; rel="prev"`},
+			expect: []Link{{URL: "/a", Rel: []string{"next"}}},
+		},
+		{
+			name:      "missing brackets",
+			values:    []string{`/v2/repo/tags/list; rel="next"`},
+			expectErr: true,
+		},
+		{
+			name:      "unterminated target",
+			values:    []string{`</v2/repo/tags/list; rel="next"`},
+			expectErr: true,
+		},
+		{
+			name:      "missing separator",
+			values:    []string{`</a> rel="next"`},
+			expectErr: true,
+		},
+		{
+			name:      "unterminated quote",
+			values:    []string{`</a>; rel="next`},
+			expectErr: true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			links, err := ParseLinks(tc.values...)
+			if tc.expectErr {
+				if !errors.Is(err, ErrLinkInvalid) {
+					t.Errorf("expected error %v, received %v", ErrLinkInvalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(links) != len(tc.expect) {
+				t.Fatalf("expected %d links, received %v", len(tc.expect), links)
+			}
+			for i, l := range links {
+				e := tc.expect[i]
+				if l.URL != e.URL || strings.Join(l.Rel, " ") != strings.Join(e.Rel, " ") || len(l.Params) != len(e.Params) {
+					t.Errorf("link %d: expected %+v, received %+v", i, e, l)
+				}
+				for k, v := range e.Params {
+					if l.Params[k] != v {
+						t.Errorf("link %d: expected param %s=%s, received %s", i, k, v, l.Params[k])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/repo/tags/list?n=2", nil)
+	resp := &http.Response{Header: http.Header{}, Request: req}
+	SetNextLink(resp.Header, "/v2/repo/tags/list?last=b&n=2")
+	u, err := NextLink(resp)
+	if err != nil || u == nil || u.String() != "https://registry.example.com/v2/repo/tags/list?last=b&n=2" {
+		t.Errorf("unexpected next link %v: %v", u, err)
+	}
+	resp.Header.Set(LinkHeader, `</v2/repo/tags/list?n=2>; rel="prev"`)
+	if u, err := NextLink(resp); err != nil || u != nil {
+		t.Errorf("expected no next link, received %v: %v", u, err)
+	}
+}
+
+func TestTagListPage(t *testing.T) {
+	tags := []string{"c", "a", "B", "d", "b"}
+	tt := []struct {
+		name       string
+		n          int
+		last       string
+		expect     []string
+		expectMore bool
+	}{
+		{name: "all", n: -1, expect: []string{"B", "a", "b", "c", "d"}},
+		{name: "zero", n: 0, expect: []string{}},
+		{name: "first page", n: 2, expect: []string{"B", "a"}, expectMore: true},
+		{name: "middle page", n: 2, last: "a", expect: []string{"b", "c"}, expectMore: true},
+		{name: "exact final page", n: 2, last: "b", expect: []string{"c", "d"}},
+		{name: "n equals length", n: 5, expect: []string{"B", "a", "b", "c", "d"}},
+		{name: "n exceeds length", n: 10, expect: []string{"B", "a", "b", "c", "d"}},
+		{name: "last not listed", n: 2, last: "bb", expect: []string{"c", "d"}},
+		{name: "last past the end", n: 2, last: "z", expect: []string{}},
+		{name: "last before the start", n: 1, last: "A", expect: []string{"B"}, expectMore: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			page, more := TagListPage(tags, tc.n, tc.last)
+			if strings.Join(page, ",") != strings.Join(tc.expect, ",") || page == nil {
+				t.Errorf("expected %v, received %v", tc.expect, page)
+			}
+			if more != tc.expectMore {
+				t.Errorf("expected more %t, received %t", tc.expectMore, more)
+			}
+		})
+	}
+	if tags[0] != "c" {
+		t.Errorf("input was modified: %v", tags)
+	}
+	if link := TagListNextLink("org/repo", 2, []string{"a", "b"}); link != "/v2/org/repo/tags/list?last=b&n=2" {
+		t.Errorf("unexpected next link %s", link)
+	}
+}
+
+// tagListServer serves the tags with TagListPage, optionally without Link headers or with a Link back to the first page.
+func tagListServer(t *testing.T, tags []string, noLink, loop bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := -1
+		if nStr := r.URL.Query().Get("n"); nStr != "" {
+			n, _ = strconv.Atoi(nStr)
+		}
+		page, more := TagListPage(tags, n, r.URL.Query().Get("last"))
+		switch {
+		case loop:
+			SetNextLink(w.Header(), "/v2/repo/tags/list?n="+strconv.Itoa(n))
+		case more && !noLink:
+			SetNextLink(w.Header(), TagListNextLink("repo", n, page))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(TagList{Name: "repo", Tags: page})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTagIterator(t *testing.T) {
+	tags := []string{"a", "b", "c", "d", "e", "f", "g"}
+	tt := []struct {
+		name      string
+		n         int
+		noLink    bool
+		loop      bool
+		expectErr bool
+	}{
+		{name: "unpaginated", n: 0},
+		{name: "link", n: 3},
+		{name: "link exact pages", n: 7},
+		{name: "without link", n: 3, noLink: true},
+		{name: "without link exact pages", n: 7, noLink: true},
+		{name: "loop", n: 3, loop: true, expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := tagListServer(t, tags, tc.noLink, tc.loop)
+			ti, err := NewTagIterator(srv.Client(), srv.URL+"/v2/repo/tags/list", tc.n)
+			if err != nil {
+				t.Fatalf("failed to create iterator: %v", err)
+			}
+			all, err := ti.All(context.Background())
+			if tc.expectErr {
+				if err == nil || !strings.Contains(err.Error(), "loop") {
+					t.Errorf("expected a loop error, received %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(all, ",") != strings.Join(tags, ",") {
+				t.Errorf("expected %v, received %v", tags, all)
+			}
+			if _, err := ti.Next(context.Background()); !errors.Is(err, io.EOF) {
+				t.Errorf("expected EOF after the last page, received %v", err)
+			}
+		})
+	}
+}
+
+// doerFunc implements Doer with a function.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestIteratorNilBody(t *testing.T) {
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Header: http.Header{}, Request: req}, nil
+	})
+	ti, err := NewTagIterator(doer, "https://registry.example.org/v2/repo/tags/list", 0)
+	if err != nil {
+		t.Fatalf("failed to create iterator: %v", err)
+	}
+	if _, err := ti.Next(context.Background()); err == nil || !strings.Contains(err.Error(), "no body") {
+		t.Errorf("expected a missing body error, received %v", err)
+	}
+	ri, err := NewReferrersIterator(doer, "https://registry.example.org/v2/repo/referrers/sha256:"+strings.Repeat("0", 64))
+	if err != nil {
+		t.Fatalf("failed to create iterator: %v", err)
+	}
+	if _, err := ri.Next(context.Background()); err == nil || !strings.Contains(err.Error(), "no body") {
+		t.Errorf("expected a missing body error, received %v", err)
+	}
+}
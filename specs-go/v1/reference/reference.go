@@ -0,0 +1,303 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reference implements the grammar for repository names, tags, and digests used in the distribution-spec.
+package reference
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// TagMaxLength is the maximum length of a tag.
+	TagMaxLength = 128
+	// NameTotalMaxLength is the limit many clients impose on the registry host, a "/", and the repository name combined.
+	NameTotalMaxLength = 255
+)
+
+var (
+	// NameRegexp matches a repository name, the <name> in the spec.
+	NameRegexp = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(\/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+	// TagRegexp matches a tag, the <tag-or-digest> in the spec when used as a tag.
+	TagRegexp = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+	// DigestRegexp matches the syntax of a digest, defined by the OCI Image Spec.
+	DigestRegexp = regexp.MustCompile(`^[a-z0-9]+([+._-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+
+	registryRegexp = regexp.MustCompile(`^(?:(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*|\[[a-fA-F0-9:.]+\])(?::[0-9]+)?$`)
+)
+
+var (
+	// ErrNameInvalid is returned for an invalid repository name.
+	ErrNameInvalid = errors.New("invalid repository name")
+	// ErrTagInvalid is returned for an invalid tag.
+	ErrTagInvalid = errors.New("invalid tag")
+	// ErrDigestInvalid is returned for a digest with an invalid syntax.
+	ErrDigestInvalid = errors.New("invalid digest")
+	// ErrRegistryInvalid is returned for an invalid registry host.
+	ErrRegistryInvalid = errors.New("invalid registry")
+	// ErrReferenceInvalid is returned for a reference that cannot be split into its components.
+	ErrReferenceInvalid = errors.New("invalid reference")
+)
+
+// InvalidError describes why a value does not match the grammar.
+// Err is one of the Err*Invalid errors, identifying the component that failed.
+type InvalidError struct {
+	Value  string
+	Err    error
+	Reason string
+	// Offset is the index into Value where the problem was found, or -1 when it applies to the whole value.
+	Offset int
+}
+
+// Error implements the Error interface.
+func (e *InvalidError) Error() string {
+	if e.Offset >= 0 {
+		return fmt.Sprintf("%v %q: %s at offset %d", e.Err, e.Value, e.Reason, e.Offset)
+	}
+	return fmt.Sprintf("%v %q: %s", e.Err, e.Value, e.Reason)
+}
+
+// Unwrap returns the component that failed.
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateName verifies a repository name matches the <name> grammar.
+func ValidateName(name string) error {
+	if name == "" {
+		return &InvalidError{Value: name, Err: ErrNameInvalid, Reason: "name is empty", Offset: -1}
+	}
+	start := 0
+	for start <= len(name) {
+		end := strings.IndexByte(name[start:], '/')
+		if end < 0 {
+			end = len(name)
+		} else {
+			end += start
+		}
+		if err := validateNameComponent(name, start, end); err != nil {
+			return err
+		}
+		start = end + 1
+	}
+	return nil
+}
+
+// validateNameComponent checks name[start:end] against `[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*`.
+func validateNameComponent(name string, start, end int) error {
+	invalid := func(reason string, offset int) error {
+		return &InvalidError{Value: name, Err: ErrNameInvalid, Reason: reason, Offset: offset}
+	}
+	if start == end {
+		return invalid("path component is empty", start)
+	}
+	sepStart := -1
+	for i := start; i < end; i++ {
+		c := name[i]
+		switch {
+		case isLowerAlnum(c):
+			if sepStart >= 0 {
+				if err := validateSeparator(name, sepStart, i); err != nil {
+					return err
+				}
+				sepStart = -1
+			}
+		case c == '.' || c == '_' || c == '-':
+			if i == start {
+				return invalid(fmt.Sprintf("path component must start with a lowercase letter or digit, found %q", c), i)
+			}
+			if sepStart < 0 {
+				sepStart = i
+			}
+		case c >= 'A' && c <= 'Z':
+			return invalid(fmt.Sprintf("uppercase character %q is not allowed", c), i)
+		default:
+			return invalid(fmt.Sprintf("character %q is not allowed", c), i)
+		}
+	}
+	if sepStart >= 0 {
+		return invalid("path component must end with a lowercase letter or digit", end-1)
+	}
+	return nil
+}
+
+// validateSeparator checks name[start:end] is one of ".", "_", "__", or one or more "-".
+func validateSeparator(name string, start, end int) error {
+	sep := name[start:end]
+	switch {
+	case sep == "." || sep == "_" || sep == "__":
+		return nil
+	case strings.Trim(sep, "-") == "":
+		return nil
+	}
+	return &InvalidError{
+		Value:  name,
+		Err:    ErrNameInvalid,
+		Reason: fmt.Sprintf("separator %q is not allowed, use \".\", \"_\", \"__\", or one or more \"-\"", sep),
+		Offset: start,
+	}
+}
+
+func isLowerAlnum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// ValidateTag verifies a tag matches the <tag-or-digest> grammar for tags.
+func ValidateTag(tag string) error {
+	invalid := func(reason string, offset int) error {
+		return &InvalidError{Value: tag, Err: ErrTagInvalid, Reason: reason, Offset: offset}
+	}
+	if tag == "" {
+		return invalid("tag is empty", -1)
+	}
+	if len(tag) > TagMaxLength {
+		return invalid(fmt.Sprintf("tag is %d characters, exceeding the limit of %d", len(tag), TagMaxLength), -1)
+	}
+	if c := tag[0]; c == '.' || c == '-' {
+		return invalid(fmt.Sprintf("tag must not start with %q", c), 0)
+	}
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !isLowerAlnum(c) && !(c >= 'A' && c <= 'Z') && c != '_' && c != '.' && c != '-' {
+			return invalid(fmt.Sprintf("character %q is not allowed", c), i)
+		}
+	}
+	return nil
+}
+
+// ValidateDigest verifies the syntax of a digest.
+// The algorithm is not checked against a list of supported algorithms.
+func ValidateDigest(digest string) error {
+	invalid := func(reason string, offset int) error {
+		return &InvalidError{Value: digest, Err: ErrDigestInvalid, Reason: reason, Offset: offset}
+	}
+	i := strings.IndexByte(digest, ':')
+	if i < 0 {
+		return invalid("missing \":\" between the algorithm and encoded sections", -1)
+	}
+	if i == 0 {
+		return invalid("algorithm is empty", 0)
+	}
+	if i == len(digest)-1 {
+		return invalid("encoded section is empty", i)
+	}
+	if !DigestRegexp.MatchString(digest) {
+		for j := 0; j < len(digest); j++ {
+			c := digest[j]
+			if j < i && !isLowerAlnum(c) && !strings.ContainsRune("+._-", rune(c)) {
+				return invalid(fmt.Sprintf("character %q is not allowed in the algorithm", c), j)
+			}
+			if j > i && !isLowerAlnum(c) && !(c >= 'A' && c <= 'Z') && !strings.ContainsRune("=_-", rune(c)) {
+				return invalid(fmt.Sprintf("character %q is not allowed in the encoded section", c), j)
+			}
+		}
+		return invalid("algorithm components must be separated by a single \"+\", \".\", \"_\", or \"-\"", -1)
+	}
+	return nil
+}
+
+// ValidateRegistry verifies a registry host, with an optional port.
+func ValidateRegistry(registry string) error {
+	if !registryRegexp.MatchString(registry) {
+		return &InvalidError{Value: registry, Err: ErrRegistryInvalid, Reason: "expected a hostname or IP address with an optional port", Offset: -1}
+	}
+	return nil
+}
+
+// Reference is a parsed reference in the form [registry/]name[:tag][@digest].
+type Reference struct {
+	// Registry is the registry host, with an optional port. It is empty when the reference has no registry.
+	Registry string
+	// Repository is the <name> of the repository.
+	Repository string
+	// Tag is optional, and may be set along with a Digest.
+	Tag string
+	// Digest is optional, and takes precedence over the Tag when pulling content.
+	Digest string
+}
+
+// Parse splits a reference in the form [registry/]name[:tag][@digest] into its components, validating each.
+// The first path component is treated as the registry when it contains a "." or ":", or is "localhost".
+func Parse(s string) (Reference, error) {
+	r := Reference{}
+	if s == "" {
+		return r, &InvalidError{Value: s, Err: ErrReferenceInvalid, Reason: "reference is empty", Offset: -1}
+	}
+	rest := s
+	if i := strings.IndexByte(rest, '@'); i >= 0 {
+		r.Digest = rest[i+1:]
+		rest = rest[:i]
+		if err := ValidateDigest(r.Digest); err != nil {
+			return Reference{}, err
+		}
+	}
+	if i := strings.LastIndexByte(rest, ':'); i > strings.LastIndexByte(rest, '/') {
+		r.Tag = rest[i+1:]
+		rest = rest[:i]
+		if err := ValidateTag(r.Tag); err != nil {
+			return Reference{}, err
+		}
+	}
+	if first, remainder, ok := strings.Cut(rest, "/"); ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		r.Registry = first
+		rest = remainder
+		if err := ValidateRegistry(r.Registry); err != nil {
+			return Reference{}, err
+		}
+	}
+	r.Repository = rest
+	if err := ValidateName(r.Repository); err != nil {
+		return Reference{}, err
+	}
+	if name := r.Name(); len(name) > NameTotalMaxLength {
+		return Reference{}, &InvalidError{
+			Value:  name,
+			Err:    ErrNameInvalid,
+			Reason: fmt.Sprintf("registry and repository name are %d characters, exceeding the limit of %d", len(name), NameTotalMaxLength),
+			Offset: -1,
+		}
+	}
+	return r, nil
+}
+
+// Name returns the registry and repository, separated by a "/" when the registry is set.
+func (r Reference) Name() string {
+	if r.Registry == "" {
+		return r.Repository
+	}
+	return r.Registry + "/" + r.Repository
+}
+
+// TagOrDigest returns the value used for the <tag-or-digest> in a manifest request, preferring the digest.
+func (r Reference) TagOrDigest() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// String returns the reference in the form [registry/]name[:tag][@digest].
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
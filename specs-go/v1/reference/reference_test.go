@@ -0,0 +1,221 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reference
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// checkInvalid verifies err is an InvalidError for the component with the expected offset.
+func checkInvalid(t *testing.T, err error, expectErr error, expectOffset int) {
+	t.Helper()
+	if expectErr == nil {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		return
+	}
+	ie := &InvalidError{}
+	if !errors.Is(err, expectErr) || !errors.As(err, &ie) {
+		t.Fatalf("expected error %v, received %v", expectErr, err)
+	}
+	if ie.Offset != expectOffset {
+		t.Errorf("expected offset %d, received %d: %v", expectOffset, ie.Offset, err)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tt := []struct {
+		name         string
+		expectErr    error
+		expectOffset int
+	}{
+		{name: "repo"},
+		{name: "org/repo"},
+		{name: "a.b/c_d/e__f/g-h/i---j/0"},
+		{name: "", expectErr: ErrNameInvalid, expectOffset: -1},
+		{name: "Repo", expectErr: ErrNameInvalid, expectOffset: 0},
+		{name: "org/rEpo", expectErr: ErrNameInvalid, expectOffset: 5},
+		{name: "a//b", expectErr: ErrNameInvalid, expectOffset: 2},
+		{name: "a/", expectErr: ErrNameInvalid, expectOffset: 2},
+		{name: "/a", expectErr: ErrNameInvalid, expectOffset: 0},
+		{name: "a/_b", expectErr: ErrNameInvalid, expectOffset: 2},
+		{name: "ab-", expectErr: ErrNameInvalid, expectOffset: 2},
+		{name: "a..b", expectErr: ErrNameInvalid, expectOffset: 1},
+		{name: "a___b", expectErr: ErrNameInvalid, expectOffset: 1},
+		{name: "a.-b", expectErr: ErrNameInvalid, expectOffset: 1},
+		{name: "a b", expectErr: ErrNameInvalid, expectOffset: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateName(tc.name)
+			checkInvalid(t, err, tc.expectErr, tc.expectOffset)
+			if match := NameRegexp.MatchString(tc.name); match != (tc.expectErr == nil) {
+				t.Errorf("NameRegexp match is %t", match)
+			}
+		})
+	}
+}
+
+func TestValidateTag(t *testing.T) {
+	tt := []struct {
+		name         string
+		tag          string
+		expectErr    error
+		expectOffset int
+	}{
+		{name: "simple", tag: "latest"},
+		{name: "mixed", tag: "_V1.2-rc.3"},
+		{name: "max length", tag: "a" + strings.Repeat("b", TagMaxLength-1)},
+		{name: "too long", tag: "a" + strings.Repeat("b", TagMaxLength), expectErr: ErrTagInvalid, expectOffset: -1},
+		{name: "empty", tag: "", expectErr: ErrTagInvalid, expectOffset: -1},
+		{name: "leading dash", tag: "-latest", expectErr: ErrTagInvalid, expectOffset: 0},
+		{name: "leading period", tag: ".latest", expectErr: ErrTagInvalid, expectOffset: 0},
+		{name: "slash", tag: "v1/latest", expectErr: ErrTagInvalid, expectOffset: 2},
+		{name: "plus", tag: "v1+build", expectErr: ErrTagInvalid, expectOffset: 2},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			checkInvalid(t, ValidateTag(tc.tag), tc.expectErr, tc.expectOffset)
+			if match := TagRegexp.MatchString(tc.tag); match != (tc.expectErr == nil) {
+				t.Errorf("TagRegexp match is %t", match)
+			}
+		})
+	}
+}
+
+func TestValidateDigest(t *testing.T) {
+	tt := []struct {
+		name         string
+		digest       string
+		expectErr    error
+		expectOffset int
+	}{
+		{name: "sha256", digest: "sha256:" + strings.Repeat("a", 64)},
+		{name: "multi component algorithm", digest: "multihash+base58:QmRZxt2b1FVZPNqd8hsiykDL3TdBDeTSPX9Kv46HmX4Gx8"},
+		{name: "missing separator", digest: "sha256", expectErr: ErrDigestInvalid, expectOffset: -1},
+		{name: "missing algorithm", digest: ":abc", expectErr: ErrDigestInvalid, expectOffset: 0},
+		{name: "missing encoded", digest: "sha256:", expectErr: ErrDigestInvalid, expectOffset: 6},
+		{name: "uppercase algorithm", digest: "SHA256:abc", expectErr: ErrDigestInvalid, expectOffset: 0},
+		{name: "invalid encoded", digest: "sha256:ab+c", expectErr: ErrDigestInvalid, expectOffset: 9},
+		{name: "trailing separator", digest: "sha256+:abc", expectErr: ErrDigestInvalid, expectOffset: -1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			checkInvalid(t, ValidateDigest(tc.digest), tc.expectErr, tc.expectOffset)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	dig := "sha256:" + strings.Repeat("0", 64)
+	registry := "registry.example.com"
+	tt := []struct {
+		name         string
+		ref          string
+		expect       Reference
+		expectErr    error
+		expectOffset int
+	}{
+		{
+			name:   "name only",
+			ref:    "repo",
+			expect: Reference{Repository: "repo"},
+		},
+		{
+			name:   "registry and tag",
+			ref:    "registry.example.com/org/repo:v1",
+			expect: Reference{Registry: registry, Repository: "org/repo", Tag: "v1"},
+		},
+		{
+			name:   "localhost with port, tag, and digest",
+			ref:    "localhost:5000/repo:v1@" + dig,
+			expect: Reference{Registry: "localhost:5000", Repository: "repo", Tag: "v1", Digest: dig},
+		},
+		{
+			name:   "localhost without port",
+			ref:    "localhost/repo",
+			expect: Reference{Registry: "localhost", Repository: "repo"},
+		},
+		{
+			name:   "first component without a registry",
+			ref:    "org/repo@" + dig,
+			expect: Reference{Repository: "org/repo", Digest: dig},
+		},
+		{
+			name:   "ipv6 registry",
+			ref:    "[::1]:5000/repo",
+			expect: Reference{Registry: "[::1]:5000", Repository: "repo"},
+		},
+		{
+			name:   "name at the length limit",
+			ref:    registry + "/" + strings.Repeat("a", NameTotalMaxLength-len(registry)-1),
+			expect: Reference{Registry: registry, Repository: strings.Repeat("a", NameTotalMaxLength-len(registry)-1)},
+		},
+		{
+			name:         "name over the length limit",
+			ref:          registry + "/" + strings.Repeat("a", NameTotalMaxLength-len(registry)),
+			expectErr:    ErrNameInvalid,
+			expectOffset: -1,
+		},
+		{
+			name:         "empty",
+			ref:          "",
+			expectErr:    ErrReferenceInvalid,
+			expectOffset: -1,
+		},
+		{
+			name:         "invalid name",
+			ref:          "registry.example.com/Repo:v1",
+			expectErr:    ErrNameInvalid,
+			expectOffset: 0,
+		},
+		{
+			name:         "invalid tag",
+			ref:          "repo:-v1",
+			expectErr:    ErrTagInvalid,
+			expectOffset: 0,
+		},
+		{
+			name:         "invalid digest",
+			ref:          "repo@sha256:",
+			expectErr:    ErrDigestInvalid,
+			expectOffset: 6,
+		},
+		{
+			name:         "invalid registry",
+			ref:          "bad_host.example.com/repo",
+			expectErr:    ErrRegistryInvalid,
+			expectOffset: -1,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := Parse(tc.ref)
+			checkInvalid(t, err, tc.expectErr, tc.expectOffset)
+			if tc.expectErr != nil {
+				return
+			}
+			if r != tc.expect {
+				t.Errorf("expected %+v, received %+v", tc.expect, r)
+			}
+			if r.String() != tc.ref {
+				t.Errorf("expected string %s, received %s", tc.ref, r.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	imagespecs "github.com/opencontainers/image-spec/specs-go"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// SubjectHeader is returned by a registry that processed the subject field of a pushed manifest.
+	SubjectHeader = "OCI-Subject"
+	// FiltersAppliedHeader lists the filters a registry applied to a referrers response.
+	FiltersAppliedHeader = "OCI-Filters-Applied"
+
+	// referrersTagAlgorithmMax is the length the algorithm is truncated to in the referrers tag schema.
+	referrersTagAlgorithmMax = 32
+	// referrersTagEncodedMax is the length the encoded section is truncated to in the referrers tag schema.
+	referrersTagEncodedMax = 64
+)
+
+var (
+	// ErrReferrersIndexInvalid is returned when the referrers tag does not contain an image index.
+	ErrReferrersIndexInvalid = errors.New("referrers tag does not contain an image index")
+	// ErrSubjectMissing is returned when a manifest does not have a subject field.
+	ErrSubjectMissing = errors.New("manifest does not have a subject")
+)
+
+// ReferrersTag returns the tag for the subject digest defined by the referrers tag schema.
+// The algorithm is truncated to 32 characters, the encoded section to 64 characters,
+// and characters not allowed in a tag are replaced with "-".
+func ReferrersTag(subject digest.Digest) string {
+	alg, enc, _ := strings.Cut(string(subject), ":")
+	if len(alg) > referrersTagAlgorithmMax {
+		alg = alg[:referrersTagAlgorithmMax]
+	}
+	if len(enc) > referrersTagEncodedMax {
+		enc = enc[:referrersTagEncodedMax]
+	}
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, alg+"-"+enc)
+}
+
+// NewReferrersIndex returns an empty image index for the referrers response or the referrers tag.
+func NewReferrersIndex() image.Index {
+	return image.Index{
+		Versioned: imagespecs.Versioned{SchemaVersion: 2},
+		MediaType: image.MediaTypeImageIndex,
+		Manifests: []image.Descriptor{},
+	}
+}
+
+// ParseReferrersIndex decodes the content of a referrers tag.
+// ErrReferrersIndexInvalid is returned when the content is not an image index,
+// which clients should report as a failure rather than overwriting the tag.
+func ParseReferrersIndex(mediaType string, raw []byte) (image.Index, error) {
+	if mediaType != image.MediaTypeImageIndex {
+		return image.Index{}, fmt.Errorf("%w: received media type %q", ErrReferrersIndexInvalid, mediaType)
+	}
+	index := image.Index{}
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return image.Index{}, fmt.Errorf("%w: %v", ErrReferrersIndexInvalid, err)
+	}
+	if index.MediaType != "" && index.MediaType != image.MediaTypeImageIndex {
+		return image.Index{}, fmt.Errorf("%w: received mediaType field %q", ErrReferrersIndexInvalid, index.MediaType)
+	}
+	if index.Manifests == nil {
+		index.Manifests = []image.Descriptor{}
+	}
+	return index, nil
+}
+
+// ReferrerDescriptor returns the descriptor used to list a manifest in a referrers response,
+// along with the subject of the manifest.
+// The artifactType is copied from the manifest, falling back to the config media type for an image manifest,
+// and the annotations are copied from the manifest.
+// ErrSubjectMissing is returned when the manifest has no subject.
+func ReferrerDescriptor(mediaType string, raw []byte) (image.Descriptor, image.Descriptor, error) {
+	// fields from both an image manifest and index, to handle content without a mediaType field
+	m := struct {
+		MediaType    string              `json:"mediaType,omitempty"`
+		ArtifactType string              `json:"artifactType,omitempty"`
+		Config       *image.Descriptor   `json:"config,omitempty"`
+		Subject      *image.Descriptor   `json:"subject,omitempty"`
+		Annotations  map[string]string   `json:"annotations,omitempty"`
+		Manifests    *[]image.Descriptor `json:"manifests,omitempty"`
+	}{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return image.Descriptor{}, image.Descriptor{}, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if m.MediaType != "" && mediaType != "" && m.MediaType != mediaType {
+		return image.Descriptor{}, image.Descriptor{}, fmt.Errorf("manifest mediaType field %q does not match %q", m.MediaType, mediaType)
+	}
+	if mediaType == "" {
+		mediaType = m.MediaType
+	}
+	if m.Subject == nil {
+		return image.Descriptor{}, image.Descriptor{}, ErrSubjectMissing
+	}
+	desc := image.Descriptor{
+		MediaType:    mediaType,
+		Digest:       digest.FromBytes(raw),
+		Size:         int64(len(raw)),
+		ArtifactType: m.ArtifactType,
+		Annotations:  m.Annotations,
+	}
+	if desc.ArtifactType == "" && m.Config != nil && mediaType != image.MediaTypeImageIndex && m.Manifests == nil {
+		desc.ArtifactType = m.Config.MediaType
+	}
+	return desc, *m.Subject, nil
+}
+
+// ReferrersIndexAdd adds the descriptor to the referrers index if a descriptor with the same digest is not already listed.
+// It returns true when the index was modified and needs to be pushed.
+func ReferrersIndexAdd(index *image.Index, desc image.Descriptor) bool {
+	for _, d := range index.Manifests {
+		if d.Digest == desc.Digest {
+			return false
+		}
+	}
+	index.Manifests = append(index.Manifests, desc)
+	return true
+}
+
+// ReferrersIndexRemove removes every descriptor with the digest from the referrers index.
+// It returns true when the index was modified and needs to be pushed.
+func ReferrersIndexRemove(index *image.Index, dig digest.Digest) bool {
+	manifests := make([]image.Descriptor, 0, len(index.Manifests))
+	for _, d := range index.Manifests {
+		if d.Digest != dig {
+			manifests = append(manifests, d)
+		}
+	}
+	if len(manifests) == len(index.Manifests) {
+		return false
+	}
+	index.Manifests = manifests
+	return true
+}
+
+// ReferrersQuery describes a request to the referrers API (end-12a, end-12b), used to build the response.
+type ReferrersQuery struct {
+	// Name and Subject are the repository and digest from the request path.
+	Name    string
+	Subject digest.Digest
+	// ArtifactType filters the descriptors when set.
+	ArtifactType string
+	// Last is the digest of the final descriptor on the previous page, from the next link.
+	Last string
+	// PageSize is the most descriptors to return in a response, zero or less returns every descriptor.
+	PageSize int
+}
+
+// NewReferrersQuery returns the query for a referrers request using the artifactType and last query parameters.
+func NewReferrersQuery(name string, subject digest.Digest, query url.Values, pageSize int) ReferrersQuery {
+	return ReferrersQuery{
+		Name:         name,
+		Subject:      subject,
+		ArtifactType: query.Get("artifactType"),
+		Last:         query.Get("last"),
+		PageSize:     pageSize,
+	}
+}
+
+// ReferrersResponse is the body and headers for a response from the referrers API.
+type ReferrersResponse struct {
+	Index image.Index
+	// FiltersApplied lists the filters for the OCI-Filters-Applied header.
+	FiltersApplied []string
+	// Next is the relative URL of the next page, or empty on the last page.
+	Next string
+}
+
+// SetHeaders sets the OCI-Filters-Applied and Link headers for the response.
+func (rr ReferrersResponse) SetHeaders(h http.Header) {
+	if len(rr.FiltersApplied) > 0 {
+		h.Set(FiltersAppliedHeader, strings.Join(rr.FiltersApplied, ","))
+	}
+	if rr.Next != "" {
+		SetNextLink(h, rr.Next)
+	}
+}
+
+// ReferrerManifest is a manifest in the repository that may be listed in a referrers response.
+type ReferrerManifest struct {
+	MediaType string
+	Raw       []byte
+}
+
+// BuildReferrersResponse returns the referrers response for the query from the manifests in the repository.
+// Manifests without a subject, or with a different subject, are skipped.
+// Descriptors are generated with ReferrerDescriptor and paginated with ReferrersPage.
+func BuildReferrersResponse(q ReferrersQuery, manifests []ReferrerManifest) (ReferrersResponse, error) {
+	descs := []image.Descriptor{}
+	for _, m := range manifests {
+		desc, subject, err := ReferrerDescriptor(m.MediaType, m.Raw)
+		if errors.Is(err, ErrSubjectMissing) {
+			continue
+		} else if err != nil {
+			return ReferrersResponse{}, err
+		}
+		if subject.Digest == q.Subject {
+			descs = append(descs, desc)
+		}
+	}
+	return ReferrersPage(q, descs), nil
+}
+
+// ReferrersPage returns the referrers response for the query from the descriptors of each referrer.
+// Descriptors are filtered by the artifactType, duplicate digests are removed,
+// and the remainder are sorted by digest so the last digest on a page can be used to request the next page.
+func ReferrersPage(q ReferrersQuery, descs []image.Descriptor) ReferrersResponse {
+	rr := ReferrersResponse{Index: NewReferrersIndex()}
+	if q.ArtifactType != "" {
+		rr.FiltersApplied = []string{"artifactType"}
+	}
+	seen := map[digest.Digest]bool{}
+	for _, d := range descs {
+		if seen[d.Digest] || (q.ArtifactType != "" && d.ArtifactType != q.ArtifactType) {
+			continue
+		}
+		seen[d.Digest] = true
+		if q.Last == "" || string(d.Digest) > q.Last {
+			rr.Index.Manifests = append(rr.Index.Manifests, d)
+		}
+	}
+	sort.Slice(rr.Index.Manifests, func(i, j int) bool {
+		return rr.Index.Manifests[i].Digest < rr.Index.Manifests[j].Digest
+	})
+	if q.PageSize > 0 && len(rr.Index.Manifests) > q.PageSize {
+		rr.Index.Manifests = rr.Index.Manifests[:q.PageSize]
+		query := url.Values{}
+		if q.ArtifactType != "" {
+			query.Set("artifactType", q.ArtifactType)
+		}
+		query.Set("last", string(rr.Index.Manifests[q.PageSize-1].Digest))
+		rr.Next = (&url.URL{Path: "/v2/" + q.Name + "/referrers/" + string(q.Subject), RawQuery: query.Encode()}).String()
+	}
+	return rr
+}
@@ -0,0 +1,297 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/opencontainers/distribution-spec/specs-go/v1/reference"
+)
+
+// TestReferrersTag uses the examples from the referrers tag schema in spec.md.
+func TestReferrersTag(t *testing.T) {
+	tt := []struct {
+		subject digest.Digest
+		expect  string
+	}{
+		{
+			subject: "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			expect:  "sha256-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			subject: "sha512:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+			expect:  "sha512-aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		},
+		{
+			subject: "test+algorithm+using+algorithm+separators+and+lots+of+characters+to+excercise+overall+truncation:alsoSome=InTheEncodedSectionToShowHyphenReplacementAndLotsAndLotsOfCharactersToExcerciseEncodedTruncation",
+			expect:  "test-algorithm-using-algorithm-s-alsoSome-InTheEncodedSectionToShowHyphenReplacementAndLotsAndLot",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expect, func(t *testing.T) {
+			tag := ReferrersTag(tc.subject)
+			if tag != tc.expect {
+				t.Errorf("expected %s, received %s", tc.expect, tag)
+			}
+			if err := reference.ValidateTag(tag); err != nil {
+				t.Errorf("referrers tag is not a valid tag: %v", err)
+			}
+		})
+	}
+}
+
+func TestReferrersIndexAddRemove(t *testing.T) {
+	d1 := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromString("one"), Size: 3, ArtifactType: "application/vnd.example.one"}
+	d2 := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromString("two"), Size: 3, ArtifactType: "application/vnd.example.two"}
+	index := NewReferrersIndex()
+	if !ReferrersIndexAdd(&index, d1) || !ReferrersIndexAdd(&index, d2) {
+		t.Fatalf("adding new descriptors did not modify the index")
+	}
+	// a second add with the same digest is ignored, even when other fields differ
+	d1Changed := d1
+	d1Changed.ArtifactType = "application/vnd.example.changed"
+	if ReferrersIndexAdd(&index, d1) || ReferrersIndexAdd(&index, d1Changed) {
+		t.Errorf("adding an existing digest modified the index")
+	}
+	if len(index.Manifests) != 2 || index.Manifests[0].ArtifactType != d1.ArtifactType {
+		t.Fatalf("unexpected manifests after adding: %v", index.Manifests)
+	}
+	if !ReferrersIndexRemove(&index, d1.Digest) {
+		t.Errorf("removing a listed digest did not modify the index")
+	}
+	if ReferrersIndexRemove(&index, d1.Digest) {
+		t.Errorf("removing a digest twice modified the index")
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != d2.Digest {
+		t.Errorf("unexpected manifests after removing: %v", index.Manifests)
+	}
+	// duplicates in a pulled index are all removed
+	index.Manifests = append(index.Manifests, d2, d1)
+	if !ReferrersIndexRemove(&index, d2.Digest) || len(index.Manifests) != 1 || index.Manifests[0].Digest != d1.Digest {
+		t.Errorf("unexpected manifests after removing duplicates: %v", index.Manifests)
+	}
+}
+
+// referrersDescs returns a descriptor for each artifactType, and the digests sorted in the order of a referrers response.
+func referrersDescs(artifactTypes ...string) ([]image.Descriptor, []digest.Digest) {
+	descs := []image.Descriptor{}
+	sorted := []digest.Digest{}
+	for i, at := range artifactTypes {
+		d := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromString(string(rune('a' + i))), Size: 1, ArtifactType: at}
+		descs = append(descs, d)
+		sorted = append(sorted, d.Digest)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return descs, sorted
+}
+
+func referrersDigests(rr ReferrersResponse) []digest.Digest {
+	ret := []digest.Digest{}
+	for _, d := range rr.Index.Manifests {
+		ret = append(ret, d.Digest)
+	}
+	return ret
+}
+
+func TestReferrersPage(t *testing.T) {
+	subject := digest.FromString("subject")
+	descs, sorted := referrersDescs("application/vnd.example.a", "application/vnd.example.b", "application/vnd.example.a", "application/vnd.example.b", "application/vnd.example.a")
+	tt := []struct {
+		name         string
+		descs        []image.Descriptor
+		artifactType string
+		last         string
+		pageSize     int
+		expect       []digest.Digest
+		expectNext   bool
+	}{
+		{name: "empty", descs: []image.Descriptor{}, pageSize: 2, expect: []digest.Digest{}},
+		{name: "nil", pageSize: 2, expect: []digest.Digest{}},
+		{name: "no page size", descs: descs, expect: sorted},
+		{name: "exactly page size", descs: descs, pageSize: 5, expect: sorted},
+		{name: "page size plus one", descs: descs, pageSize: 4, expect: sorted[:4], expectNext: true},
+		{name: "first page", descs: descs, pageSize: 2, expect: sorted[:2], expectNext: true},
+		{name: "middle page", descs: descs, pageSize: 2, last: string(sorted[1]), expect: sorted[2:4], expectNext: true},
+		{name: "last page", descs: descs, pageSize: 2, last: string(sorted[3]), expect: sorted[4:]},
+		{name: "last page exactly page size", descs: descs, pageSize: 2, last: string(sorted[2]), expect: sorted[3:]},
+		{name: "last not present", descs: descs, pageSize: 2, last: string(sorted[1]) + "0", expect: sorted[2:4], expectNext: true},
+		{name: "last before every digest", descs: descs, pageSize: 10, last: "a", expect: sorted},
+		{name: "last after every digest", descs: descs, pageSize: 2, last: "sha256:g", expect: []digest.Digest{}},
+		{name: "duplicates", descs: append(append([]image.Descriptor{}, descs...), descs...), expect: sorted},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			q := ReferrersQuery{Name: "repo", Subject: subject, ArtifactType: tc.artifactType, Last: tc.last, PageSize: tc.pageSize}
+			rr := ReferrersPage(q, tc.descs)
+			if rr.Index.Manifests == nil || rr.Index.MediaType != image.MediaTypeImageIndex || rr.Index.SchemaVersion != 2 {
+				t.Errorf("unexpected index %+v", rr.Index)
+			}
+			if received := referrersDigests(rr); len(received) != len(tc.expect) || (len(received) > 0 && !equalDigests(received, tc.expect)) {
+				t.Errorf("expected %v, received %v", tc.expect, received)
+			}
+			if !tc.expectNext {
+				if rr.Next != "" {
+					t.Errorf("unexpected next page %s", rr.Next)
+				}
+				return
+			}
+			u, err := url.Parse(rr.Next)
+			if err != nil {
+				t.Fatalf("failed to parse next %s: %v", rr.Next, err)
+			}
+			if u.Path != "/v2/repo/referrers/"+subject.String() || u.Query().Get("last") != string(tc.expect[len(tc.expect)-1]) {
+				t.Errorf("unexpected next page %s", rr.Next)
+			}
+		})
+	}
+}
+
+func equalDigests(a, b []digest.Digest) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReferrersPageFilter(t *testing.T) {
+	subject := digest.FromString("subject")
+	descs, _ := referrersDescs("application/vnd.example.a", "application/vnd.example.b", "application/vnd.example.a", "", "application/vnd.example.a")
+	filtered := []digest.Digest{}
+	for _, d := range descs {
+		if d.ArtifactType == "application/vnd.example.a" {
+			filtered = append(filtered, d.Digest)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i] < filtered[j] })
+
+	// follow each page with the Link header
+	query := url.Values{"artifactType": {"application/vnd.example.a"}}
+	received := []digest.Digest{}
+	for pages := 0; ; pages++ {
+		if pages > len(descs) {
+			t.Fatalf("too many pages")
+		}
+		rr := ReferrersPage(NewReferrersQuery("repo", subject, query, 2), descs)
+		h := http.Header{}
+		rr.SetHeaders(h)
+		if h.Get(FiltersAppliedHeader) != "artifactType" || len(rr.FiltersApplied) != 1 {
+			t.Errorf("expected %s header artifactType, received %q", FiltersAppliedHeader, h.Get(FiltersAppliedHeader))
+		}
+		for _, d := range rr.Index.Manifests {
+			if d.ArtifactType != "application/vnd.example.a" {
+				t.Errorf("unexpected artifactType %s", d.ArtifactType)
+			}
+		}
+		received = append(received, referrersDigests(rr)...)
+		req, _ := http.NewRequest(http.MethodGet, "https://registry.example.com/v2/repo/referrers/"+subject.String(), nil)
+		next, err := NextLink(&http.Response{Header: h, Request: req})
+		if err != nil {
+			t.Fatalf("failed to parse the Link header %q: %v", h.Get(LinkHeader), err)
+		}
+		if next == nil {
+			if pages != 1 {
+				t.Errorf("expected 2 pages, received %d", pages+1)
+			}
+			break
+		}
+		if next.Host != "registry.example.com" || next.Path != "/v2/repo/referrers/"+subject.String() {
+			t.Errorf("unexpected next link %s", next)
+		}
+		query = next.Query()
+		if query.Get("artifactType") != "application/vnd.example.a" {
+			t.Errorf("next link is missing the artifactType: %s", next)
+		}
+	}
+	if !equalDigests(received, filtered) {
+		t.Errorf("expected %v, received %v", filtered, received)
+	}
+
+	// no filter, no headers
+	rr := ReferrersPage(NewReferrersQuery("repo", subject, url.Values{}, 0), descs)
+	h := http.Header{}
+	rr.SetHeaders(h)
+	if len(h) != 0 || rr.FiltersApplied != nil {
+		t.Errorf("unexpected headers %v", h)
+	}
+}
+
+func TestBuildReferrersResponse(t *testing.T) {
+	subject := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromString("subject"), Size: 7}
+	other := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromString("other"), Size: 5}
+	manifest := func(artifactType string, s *image.Descriptor) ReferrerManifest {
+		m := image.Manifest{
+			MediaType:    image.MediaTypeImageManifest,
+			ArtifactType: artifactType,
+			Config:       image.DescriptorEmptyJSON,
+			Layers:       []image.Descriptor{image.DescriptorEmptyJSON},
+			Subject:      s,
+		}
+		m.SchemaVersion = 2
+		raw, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("failed to marshal manifest: %v", err)
+		}
+		return ReferrerManifest{MediaType: image.MediaTypeImageManifest, Raw: raw}
+	}
+	manifests := []ReferrerManifest{
+		manifest("application/vnd.example.a", &subject),
+		manifest("application/vnd.example.b", &subject),
+		manifest("application/vnd.example.a", &other),
+		manifest("application/vnd.example.a", nil),
+	}
+	rr, err := BuildReferrersResponse(ReferrersQuery{Name: "repo", Subject: subject.Digest}, manifests)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rr.Index.Manifests) != 2 {
+		t.Fatalf("expected 2 referrers, received %v", rr.Index.Manifests)
+	}
+	for _, d := range rr.Index.Manifests {
+		found := false
+		for _, m := range manifests[:2] {
+			if d.Digest == digest.FromBytes(m.Raw) && d.Size == int64(len(m.Raw)) && d.MediaType == image.MediaTypeImageManifest {
+				found = true
+			}
+		}
+		if !found || d.ArtifactType == "" {
+			t.Errorf("unexpected descriptor %+v", d)
+		}
+	}
+	rr, err = BuildReferrersResponse(ReferrersQuery{Name: "repo", Subject: subject.Digest, ArtifactType: "application/vnd.example.b"}, manifests)
+	if err != nil || len(rr.Index.Manifests) != 1 || rr.Index.Manifests[0].ArtifactType != "application/vnd.example.b" {
+		t.Errorf("unexpected filtered response %v: %v", rr.Index.Manifests, err)
+	}
+	rr, err = BuildReferrersResponse(ReferrersQuery{Name: "repo", Subject: digest.FromString("none")}, manifests)
+	if err != nil || len(rr.Index.Manifests) != 0 {
+		t.Errorf("unexpected response for a subject without referrers %v: %v", rr.Index.Manifests, err)
+	}
+	_, err = BuildReferrersResponse(ReferrersQuery{Name: "repo", Subject: subject.Digest}, append(manifests, ReferrerManifest{MediaType: image.MediaTypeImageManifest, Raw: []byte("{")}))
+	if err == nil || errors.Is(err, ErrSubjectMissing) {
+		t.Errorf("expected an error for an invalid manifest, received %v", err)
+	}
+}
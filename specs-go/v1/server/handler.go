@@ -0,0 +1,614 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+	"github.com/opencontainers/distribution-spec/specs-go/v1/reference"
+)
+
+const (
+	// defaultManifestMaxSize is the default limit for pushed manifests, the spec recommends supporting at least 4MiB.
+	defaultManifestMaxSize = 4 << 20
+)
+
+// Handler implements the distribution API with a Registry.
+type Handler struct {
+	reg             Registry
+	chunkMinLength  int64
+	manifestMaxSize int64
+	referrersPage   int
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithChunkMinLength sets the OCI-Chunk-Min-Length header returned when an upload session is opened.
+func WithChunkMinLength(size int64) Option {
+	return func(h *Handler) {
+		h.chunkMinLength = size
+	}
+}
+
+// WithManifestMaxSize sets the largest manifest that may be pushed, larger manifests are rejected with a 413.
+func WithManifestMaxSize(size int64) Option {
+	return func(h *Handler) {
+		h.manifestMaxSize = size
+	}
+}
+
+// WithReferrersPageSize sets the most descriptors returned in a referrers response, additional descriptors are paginated.
+func WithReferrersPageSize(size int) Option {
+	return func(h *Handler) {
+		h.referrersPage = size
+	}
+}
+
+// NewHandler returns a Handler serving the content of reg.
+func NewHandler(reg Registry, opts ...Option) *Handler {
+	h := &Handler{
+		reg:             reg,
+		manifestMaxSize: defaultManifestMaxSize,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// ServeHTTP routes the request to the endpoint from the spec.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m, err := v1.MatchEndpoint(r)
+	if errors.Is(err, v1.ErrEndpointMethod) {
+		writeError(w, v1.NewErrorResponse(v1.ErrUnsupported, fmt.Sprintf("method %s is not supported", r.Method)))
+		return
+	} else if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if m.ID != v1.EndpointPing {
+		if err := reference.ValidateName(m.Name); err != nil {
+			writeError(w, v1.NewErrorResponse(v1.ErrNameInvalid, err.Error()))
+			return
+		}
+	}
+	switch m.ID {
+	case v1.EndpointPing:
+		writeJSON(w, http.StatusOK, "application/json", struct{}{})
+	case v1.EndpointBlobGet:
+		h.blobGet(w, r, m)
+	case v1.EndpointBlobDelete:
+		h.blobDelete(w, r, m)
+	case v1.EndpointBlobUploadStart, v1.EndpointBlobUploadAlgorithm:
+		h.uploadStart(w, r, m)
+	case v1.EndpointBlobUploadDigest:
+		h.uploadMonolithic(w, r, m)
+	case v1.EndpointBlobMount:
+		h.blobMount(w, r, m)
+	case v1.EndpointBlobUploadChunk:
+		h.uploadChunk(w, r, m)
+	case v1.EndpointBlobUploadFinish:
+		h.uploadFinish(w, r, m)
+	case v1.EndpointBlobUploadStatus:
+		h.uploadStatus(w, r, m)
+	case v1.EndpointBlobUploadCancel:
+		h.uploadCancel(w, r, m)
+	case v1.EndpointManifestGet:
+		h.manifestGet(w, r, m)
+	case v1.EndpointManifestPut, v1.EndpointManifestPutTags:
+		h.manifestPut(w, r, m)
+	case v1.EndpointManifestDelete:
+		h.manifestDelete(w, r, m)
+	case v1.EndpointTagList, v1.EndpointTagListPaginated:
+		h.tagList(w, r, m)
+	case v1.EndpointReferrers, v1.EndpointReferrersFiltered:
+		h.referrers(w, r, m)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) blobGet(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	dig, ok := parseDigest(w, m.Digest)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodHead {
+		desc, err := h.reg.BlobStat(r.Context(), m.Name, dig)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		setContentHeaders(w, v1.MediaTypeOctetStream, desc)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	rc, desc, err := h.reg.BlobOpen(r.Context(), m.Name, dig)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer rc.Close()
+	setContentHeaders(w, v1.MediaTypeOctetStream, desc)
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, rc)
+}
+
+func (h *Handler) blobDelete(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	dig, ok := parseDigest(w, m.Digest)
+	if !ok {
+		return
+	}
+	bd, ok := h.reg.(BlobDeleter)
+	if !ok {
+		writeError(w, v1.NewErrorResponse(v1.ErrUnsupported, "blob deletion is disabled"))
+		return
+	}
+	if err := bd.BlobDelete(r.Context(), m.Name, dig); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) uploadStart(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	if m.ID == v1.EndpointBlobUploadAlgorithm {
+		alg := digest.Algorithm(m.Query.Get("digest-algorithm"))
+		if !alg.Available() {
+			writeError(w, v1.NewErrorResponse(v1.ErrDigestInvalid, fmt.Sprintf("digest algorithm %q is not supported", alg)))
+			return
+		}
+	}
+	id, err := h.reg.UploadCreate(r.Context(), m.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeUploadSession(w, m.Name, id, -1, http.StatusAccepted)
+}
+
+func (h *Handler) uploadMonolithic(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	dig, ok := parseDigest(w, m.Digest)
+	if !ok {
+		return
+	}
+	id, err := h.reg.UploadCreate(r.Context(), m.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := h.reg.UploadWrite(r.Context(), m.Name, id, r.Body); err != nil {
+		_ = h.reg.UploadCancel(r.Context(), m.Name, id)
+		writeError(w, err)
+		return
+	}
+	desc, err := h.reg.UploadCommit(r.Context(), m.Name, id, dig)
+	if err != nil {
+		_ = h.reg.UploadCancel(r.Context(), m.Name, id)
+		writeError(w, err)
+		return
+	}
+	writeBlobCreated(w, m.Name, desc)
+}
+
+func (h *Handler) blobMount(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	dig, ok := parseDigest(w, m.Digest)
+	if !ok {
+		return
+	}
+	from := m.Query.Get("from")
+	if from != "" {
+		if err := reference.ValidateName(from); err != nil {
+			writeError(w, v1.NewErrorResponse(v1.ErrNameInvalid, err.Error()))
+			return
+		}
+	}
+	if bm, ok := h.reg.(BlobMounter); ok {
+		if desc, err := bm.BlobMount(r.Context(), m.Name, from, dig); err == nil {
+			writeBlobCreated(w, m.Name, desc)
+			return
+		}
+	}
+	// a failed mount falls back to an upload session
+	h.uploadStart(w, r, m)
+}
+
+func (h *Handler) uploadChunk(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	offset, ok := h.uploadWrite(w, r, m)
+	if !ok {
+		return
+	}
+	h.writeUploadSession(w, m.Name, m.SessionID, offset, http.StatusAccepted)
+}
+
+func (h *Handler) uploadFinish(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	dig, ok := parseDigest(w, m.Digest)
+	if !ok {
+		return
+	}
+	if r.ContentLength != 0 {
+		if _, ok := h.uploadWrite(w, r, m); !ok {
+			return
+		}
+	}
+	desc, err := h.reg.UploadCommit(r.Context(), m.Name, m.SessionID, dig)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeBlobCreated(w, m.Name, desc)
+}
+
+// uploadWrite verifies the Content-Range of a chunk and writes the body to the session.
+// The new offset is returned, with false indicating an error response was sent.
+func (h *Handler) uploadWrite(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) (int64, bool) {
+	offset, err := h.reg.UploadStatus(r.Context(), m.Name, m.SessionID)
+	if err != nil {
+		writeError(w, err)
+		return 0, false
+	}
+	if cr := r.Header.Get("Content-Range"); cr != "" {
+		start, end, err := parseContentRange(cr)
+		if err != nil {
+			writeError(w, v1.NewErrorResponse(v1.ErrBlobUploadInvalid, err.Error()))
+			return 0, false
+		}
+		if start != offset {
+			writeErrorStatus(w, http.StatusRequestedRangeNotSatisfiable,
+				v1.NewErrorResponse(v1.ErrBlobUploadInvalid, fmt.Sprintf("chunk begins at %d, expected %d", start, offset)))
+			return 0, false
+		}
+		if r.ContentLength >= 0 && end-start+1 != r.ContentLength {
+			writeError(w, v1.NewErrorResponse(v1.ErrBlobUploadInvalid, fmt.Sprintf("Content-Range %q does not match Content-Length %d", cr, r.ContentLength)))
+			return 0, false
+		}
+	}
+	offset, err = h.reg.UploadWrite(r.Context(), m.Name, m.SessionID, r.Body)
+	if err != nil {
+		writeError(w, err)
+		return 0, false
+	}
+	return offset, true
+}
+
+func (h *Handler) uploadStatus(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	offset, err := h.reg.UploadStatus(r.Context(), m.Name, m.SessionID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	h.writeUploadSession(w, m.Name, m.SessionID, offset, http.StatusNoContent)
+}
+
+func (h *Handler) uploadCancel(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	if err := h.reg.UploadCancel(r.Context(), m.Name, m.SessionID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) manifestGet(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	if !validateRef(w, m.Reference) {
+		return
+	}
+	desc, raw, err := h.reg.ManifestGet(r.Context(), m.Name, m.Reference)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setContentHeaders(w, desc.MediaType, desc)
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(raw)
+	}
+}
+
+func (h *Handler) manifestPut(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	if !validateRef(w, m.Reference) {
+		return
+	}
+	tags := m.Query["tag"]
+	refDig, refIsDigest := refDigest(m.Reference)
+	if len(tags) > 0 && !refIsDigest {
+		writeError(w, v1.NewErrorResponse(v1.ErrManifestInvalid, "tag parameters require pushing by digest"))
+		return
+	}
+	for _, tag := range tags {
+		if err := reference.ValidateTag(tag); err != nil {
+			writeError(w, v1.NewErrorResponse(v1.ErrManifestInvalid, err.Error()))
+			return
+		}
+	}
+	if r.ContentLength > h.manifestMaxSize {
+		writeManifestTooLarge(w, h.manifestMaxSize)
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, h.manifestMaxSize+1))
+	if err != nil {
+		writeError(w, v1.NewErrorResponse(v1.ErrManifestInvalid, err.Error()))
+		return
+	}
+	if int64(len(raw)) > h.manifestMaxSize {
+		writeManifestTooLarge(w, h.manifestMaxSize)
+		return
+	}
+	mediaType, err := manifestMediaType(r.Header.Get("Content-Type"), raw)
+	if err != nil {
+		writeError(w, v1.NewErrorResponse(v1.ErrManifestInvalid, err.Error()))
+		return
+	}
+	desc := image.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(raw),
+		Size:      int64(len(raw)),
+	}
+	if refIsDigest {
+		if !refDig.Algorithm().Available() {
+			writeError(w, v1.NewErrorResponse(v1.ErrDigestInvalid, fmt.Sprintf("digest algorithm %q is not supported", refDig.Algorithm())))
+			return
+		}
+		desc.Digest = refDig.Algorithm().FromBytes(raw)
+		if desc.Digest != refDig {
+			writeError(w, v1.NewErrorResponse(v1.ErrDigestInvalid, fmt.Sprintf("manifest digest is %s", desc.Digest)))
+			return
+		}
+	}
+	if err := h.reg.ManifestPut(r.Context(), m.Name, m.Reference, desc, raw); err != nil {
+		writeError(w, err)
+		return
+	}
+	for _, tag := range tags {
+		if err := h.reg.ManifestPut(r.Context(), m.Name, tag, desc, raw); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if _, ok := h.reg.(ReferrersLister); ok {
+		if _, subject, err := v1.ReferrerDescriptor(mediaType, raw); err == nil {
+			w.Header().Set(v1.SubjectHeader, subject.Digest.String())
+		}
+	}
+	if len(tags) > 0 {
+		w.Header().Set(v1.TagHeader, strings.Join(tags, ", "))
+	}
+	w.Header().Set("Location", manifestLocation(m.Name, desc.Digest))
+	w.Header().Set(v1.ContentDigestHeader, desc.Digest.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *Handler) manifestDelete(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	if !validateRef(w, m.Reference) {
+		return
+	}
+	md, ok := h.reg.(ManifestDeleter)
+	if !ok {
+		writeError(w, v1.NewErrorResponse(v1.ErrUnsupported, "manifest deletion is disabled"))
+		return
+	}
+	if err := md.ManifestDelete(r.Context(), m.Name, m.Reference); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) tagList(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	n := -1
+	if nStr := m.Query.Get("n"); nStr != "" {
+		var err error
+		n, err = strconv.Atoi(nStr)
+		if err != nil || n < 0 {
+			writeErrorStatus(w, http.StatusBadRequest, v1.NewErrorResponse(v1.ErrUnsupported, fmt.Sprintf("invalid value for n: %q", nStr)))
+			return
+		}
+	}
+	tags, err := h.reg.TagList(r.Context(), m.Name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, more := v1.TagListPage(tags, n, m.Query.Get("last"))
+	if more {
+		v1.SetNextLink(w.Header(), v1.TagListNextLink(m.Name, n, page))
+	}
+	writeJSON(w, http.StatusOK, "application/json", v1.TagList{Name: m.Name, Tags: page})
+}
+
+func (h *Handler) referrers(w http.ResponseWriter, r *http.Request, m v1.EndpointMatch) {
+	dig, ok := parseDigest(w, m.Digest)
+	if !ok {
+		return
+	}
+	rl, ok := h.reg.(ReferrersLister)
+	if !ok {
+		writeErrorStatus(w, http.StatusNotFound, v1.NewErrorResponse(v1.ErrUnsupported, "the referrers API is not supported"))
+		return
+	}
+	descs, err := rl.Referrers(r.Context(), m.Name, dig)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rr := v1.ReferrersPage(v1.NewReferrersQuery(m.Name, dig, m.Query, h.referrersPage), descs)
+	rr.SetHeaders(w.Header())
+	writeJSON(w, http.StatusOK, image.MediaTypeImageIndex, rr.Index)
+}
+
+// writeUploadSession sends the Location of an upload session, and the Range when offset is not negative.
+func (h *Handler) writeUploadSession(w http.ResponseWriter, name, id string, offset int64, status int) {
+	w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+url.PathEscape(id))
+	w.Header().Set(v1.UploadUUIDHeader, id)
+	if offset >= 0 {
+		// an empty session is reported as "0-0" by convention, the spec has no range for zero bytes,
+		// and v1.ParseUploadRange reads "0-0" as offset 0
+		end := offset - 1
+		if end < 0 {
+			end = 0
+		}
+		w.Header().Set("Range", v1.FormatContentRange(0, end))
+	}
+	if h.chunkMinLength > 0 && status == http.StatusAccepted {
+		w.Header().Set(v1.ChunkMinLengthHeader, strconv.FormatInt(h.chunkMinLength, 10))
+	}
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(status)
+}
+
+func writeBlobCreated(w http.ResponseWriter, name string, desc image.Descriptor) {
+	w.Header().Set("Location", "/v2/"+name+"/blobs/"+desc.Digest.String())
+	w.Header().Set(v1.ContentDigestHeader, desc.Digest.String())
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func writeManifestTooLarge(w http.ResponseWriter, limit int64) {
+	writeErrorStatus(w, http.StatusRequestEntityTooLarge,
+		v1.NewErrorResponse(v1.ErrSizeInvalid, fmt.Sprintf("manifest exceeds the limit of %d bytes", limit)))
+}
+
+func setContentHeaders(w http.ResponseWriter, mediaType string, desc image.Descriptor) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.FormatInt(desc.Size, 10))
+	w.Header().Set(v1.ContentDigestHeader, desc.Digest.String())
+}
+
+func manifestLocation(name string, dig digest.Digest) string {
+	return "/v2/" + name + "/manifests/" + dig.String()
+}
+
+// writeError sends the ErrorResponse for err with the status of the error code.
+func writeError(w http.ResponseWriter, err error) {
+	writeErrorStatus(w, 0, err)
+}
+
+// writeErrorStatus sends the ErrorResponse for err, with status overriding the status of the error code when not 0.
+func writeErrorStatus(w http.ResponseWriter, status int, err error) {
+	er := &v1.ErrorResponse{}
+	ec := v1.ErrorCode("")
+	switch {
+	case errors.As(err, &er):
+	case errors.As(err, &ec):
+		msg := ""
+		if !errors.Is(ec, err) {
+			msg = err.Error()
+		}
+		er = v1.NewErrorResponse(ec, msg)
+	default:
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if status == 0 {
+		status = er.HTTPStatus()
+	}
+	writeJSON(w, status, "application/json", er)
+}
+
+func writeJSON(w http.ResponseWriter, status int, mediaType string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(raw)))
+	w.WriteHeader(status)
+	_, _ = w.Write(raw)
+}
+
+// parseDigest returns the digest, sending a DIGEST_INVALID error when it is not valid.
+func parseDigest(w http.ResponseWriter, s string) (digest.Digest, bool) {
+	dig, err := digest.Parse(s)
+	if err != nil {
+		writeError(w, v1.NewErrorResponse(v1.ErrDigestInvalid, fmt.Sprintf("invalid digest %q: %v", s, err)))
+		return "", false
+	}
+	return dig, true
+}
+
+// validateRef verifies a <tag-or-digest>, sending an error response when it is not valid.
+func validateRef(w http.ResponseWriter, ref string) bool {
+	if strings.Contains(ref, ":") {
+		_, ok := parseDigest(w, ref)
+		return ok
+	}
+	if err := reference.ValidateTag(ref); err != nil {
+		writeError(w, v1.NewErrorResponse(v1.ErrManifestInvalid, err.Error()))
+		return false
+	}
+	return true
+}
+
+func refDigest(ref string) (digest.Digest, bool) {
+	if !strings.Contains(ref, ":") {
+		return "", false
+	}
+	dig, err := digest.Parse(ref)
+	return dig, err == nil
+}
+
+// manifestMediaType returns the media type of a pushed manifest from the Content-Type header,
+// falling back to the mediaType field, and verifies the two match when both are set.
+func manifestMediaType(contentType string, raw []byte) (string, error) {
+	m := struct {
+		MediaType string `json:"mediaType,omitempty"`
+	}{}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return "", fmt.Errorf("manifest is not valid JSON: %v", err)
+	}
+	base, _, _ := strings.Cut(contentType, ";")
+	base = strings.TrimSpace(base)
+	switch {
+	case base == "" && m.MediaType == "":
+		return "", fmt.Errorf("manifest media type is missing")
+	case base == "":
+		return m.MediaType, nil
+	case m.MediaType != "" && m.MediaType != base:
+		return "", fmt.Errorf("Content-Type %q does not match the mediaType field %q", base, m.MediaType)
+	}
+	return base, nil
+}
+
+// parseContentRange parses a Content-Range header from a chunk in the form "<start>-<end>".
+func parseContentRange(value string) (int64, int64, error) {
+	startStr, endStr, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("Content-Range %q must be in the form <start>-<end>", value)
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("Content-Range %q has an invalid start", value)
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("Content-Range %q has an invalid end", value)
+	}
+	return start, end, nil
+}
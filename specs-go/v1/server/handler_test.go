@@ -0,0 +1,432 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+
+	v1 "github.com/opencontainers/distribution-spec/specs-go/v1"
+)
+
+// memRegistry is an in-memory Registry, supporting deletes and the referrers API.
+type memRegistry struct {
+	mu        sync.Mutex
+	blobs     map[digest.Digest][]byte
+	uploads   map[string][]byte
+	nextID    int
+	manifests map[string]memManifest
+	tags      map[string]digest.Digest
+}
+
+type memManifest struct {
+	desc image.Descriptor
+	raw  []byte
+}
+
+func newMemRegistry() *memRegistry {
+	return &memRegistry{
+		blobs:     map[digest.Digest][]byte{},
+		uploads:   map[string][]byte{},
+		manifests: map[string]memManifest{},
+		tags:      map[string]digest.Digest{},
+	}
+}
+
+func (m *memRegistry) BlobStat(ctx context.Context, repo string, dig digest.Digest) (image.Descriptor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	blob, ok := m.blobs[dig]
+	if !ok {
+		return image.Descriptor{}, v1.ErrBlobUnknown
+	}
+	return image.Descriptor{MediaType: v1.MediaTypeOctetStream, Digest: dig, Size: int64(len(blob))}, nil
+}
+
+func (m *memRegistry) BlobOpen(ctx context.Context, repo string, dig digest.Digest) (io.ReadCloser, image.Descriptor, error) {
+	desc, err := m.BlobStat(ctx, repo, dig)
+	if err != nil {
+		return nil, desc, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return io.NopCloser(bytes.NewReader(m.blobs[dig])), desc, nil
+}
+
+func (m *memRegistry) UploadCreate(ctx context.Context, repo string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	id := strconv.Itoa(m.nextID)
+	m.uploads[id] = []byte{}
+	return id, nil
+}
+
+func (m *memRegistry) UploadStatus(ctx context.Context, repo, id string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, ok := m.uploads[id]
+	if !ok {
+		return 0, v1.ErrBlobUploadUnknown
+	}
+	return int64(len(buf)), nil
+}
+
+func (m *memRegistry) UploadWrite(ctx context.Context, repo, id string, r io.Reader) (int64, error) {
+	chunk, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, ok := m.uploads[id]
+	if !ok {
+		return 0, v1.ErrBlobUploadUnknown
+	}
+	m.uploads[id] = append(buf, chunk...)
+	return int64(len(m.uploads[id])), nil
+}
+
+func (m *memRegistry) UploadCommit(ctx context.Context, repo, id string, dig digest.Digest) (image.Descriptor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, ok := m.uploads[id]
+	if !ok {
+		return image.Descriptor{}, v1.ErrBlobUploadUnknown
+	}
+	if dig.Algorithm().FromBytes(buf) != dig {
+		return image.Descriptor{}, v1.ErrDigestInvalid
+	}
+	delete(m.uploads, id)
+	m.blobs[dig] = buf
+	return image.Descriptor{MediaType: v1.MediaTypeOctetStream, Digest: dig, Size: int64(len(buf))}, nil
+}
+
+func (m *memRegistry) UploadCancel(ctx context.Context, repo, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.uploads[id]; !ok {
+		return v1.ErrBlobUploadUnknown
+	}
+	delete(m.uploads, id)
+	return nil
+}
+
+func (m *memRegistry) ManifestGet(ctx context.Context, repo, ref string) (image.Descriptor, []byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dig, ok := m.tags[ref]
+	if !ok {
+		dig = digest.Digest(ref)
+	}
+	mm, ok := m.manifests[dig.String()]
+	if !ok {
+		return image.Descriptor{}, nil, v1.ErrManifestUnknown
+	}
+	return mm.desc, mm.raw, nil
+}
+
+func (m *memRegistry) ManifestPut(ctx context.Context, repo, ref string, desc image.Descriptor, raw []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.manifests[desc.Digest.String()] = memManifest{desc: desc, raw: raw}
+	if ref != desc.Digest.String() {
+		m.tags[ref] = desc.Digest
+	}
+	return nil
+}
+
+func (m *memRegistry) ManifestDelete(ctx context.Context, repo, ref string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tags[ref]; ok {
+		delete(m.tags, ref)
+		return nil
+	}
+	if _, ok := m.manifests[ref]; !ok {
+		return v1.ErrManifestUnknown
+	}
+	delete(m.manifests, ref)
+	for tag, dig := range m.tags {
+		if dig.String() == ref {
+			delete(m.tags, tag)
+		}
+	}
+	return nil
+}
+
+func (m *memRegistry) TagList(ctx context.Context, repo string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags := []string{}
+	for tag := range m.tags {
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (m *memRegistry) Referrers(ctx context.Context, repo string, subject digest.Digest) ([]image.Descriptor, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	descs := []image.Descriptor{}
+	for _, mm := range m.manifests {
+		desc, s, err := v1.ReferrerDescriptor(mm.desc.MediaType, mm.raw)
+		if err == nil && s.Digest == subject {
+			descs = append(descs, desc)
+		}
+	}
+	sort.Slice(descs, func(i, j int) bool { return descs[i].Digest < descs[j].Digest })
+	return descs, nil
+}
+
+// send sends a request to the server, failing the test on any error other than the response status.
+func send(t *testing.T, method, u string, header http.Header, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	for k, vals := range header {
+		req.Header[k] = vals
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func expectResponse(t *testing.T, resp *http.Response, status int, code v1.ErrorCode) {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s: expected status %d, received %d", resp.Request.Method, resp.Request.URL, status, resp.StatusCode)
+	}
+	if code == "" {
+		return
+	}
+	err := v1.ParseErrorResponse(resp)
+	if !errors.Is(err, code) {
+		t.Errorf("%s %s: expected error %s, received %v", resp.Request.Method, resp.Request.URL, code, err)
+	}
+}
+
+func TestHandlerUpload(t *testing.T) {
+	ctx := context.Background()
+	reg := newMemRegistry()
+	s := httptest.NewServer(NewHandler(reg, WithChunkMinLength(2)))
+	defer s.Close()
+	urls, err := v1.NewURLBuilder(s.URL)
+	if err != nil {
+		t.Fatalf("failed to create url builder: %v", err)
+	}
+	blob := []byte("0123456789")
+	dig := digest.FromBytes(blob)
+
+	resp := send(t, http.MethodPost, urls.BlobUpload("repo"), nil, nil)
+	us, err := v1.NewUploadSession(resp)
+	if err != nil {
+		t.Fatalf("failed to start upload: %v", err)
+	}
+	if us.ChunkMinLength != 2 || us.UUID == "" {
+		t.Errorf("unexpected session %+v", us)
+	}
+
+	// resume the empty session from the status
+	req, _ := us.NewStatusRequest(ctx)
+	resp = send(t, req.Method, req.URL.String(), nil, nil)
+	if resp.Header.Get("Range") != "0-0" {
+		t.Errorf("expected Range 0-0, received %q", resp.Header.Get("Range"))
+	}
+	us, err = v1.ResumeUploadSession(resp)
+	if err != nil {
+		t.Fatalf("failed to resume upload: %v", err)
+	}
+	if us.Offset != 0 {
+		t.Fatalf("expected offset 0, received %d", us.Offset)
+	}
+
+	// chunks, including one at the wrong offset
+	patch := func(chunk []byte) *http.Response {
+		req, _ := us.NewChunkRequest(ctx, bytes.NewReader(chunk), int64(len(chunk)))
+		return send(t, req.Method, req.URL.String(), req.Header, chunk)
+	}
+	resp = patch(blob[:4])
+	if err := us.HandleChunkResponse(resp, 4); err != nil {
+		t.Fatalf("failed to send the first chunk: %v", err)
+	}
+	if us.Offset != 4 || resp.Header.Get("Range") != "0-3" {
+		t.Errorf("expected offset 4 and Range 0-3, received %d and %q", us.Offset, resp.Header.Get("Range"))
+	}
+	us.Offset = 6
+	resp = patch(blob[6:8])
+	if err := us.HandleChunkResponse(resp, 2); !errors.Is(err, v1.ErrUploadRangeNotSatisfiable) {
+		t.Fatalf("expected error %v, received %v", v1.ErrUploadRangeNotSatisfiable, err)
+	}
+	req, _ = us.NewStatusRequest(ctx)
+	resp = send(t, req.Method, req.URL.String(), nil, nil)
+	if err := us.HandleStatusResponse(resp); err != nil || us.Offset != 4 {
+		t.Fatalf("expected offset 4 from the status, received %d: %v", us.Offset, err)
+	}
+	resp = patch(blob[4:8])
+	if err := us.HandleChunkResponse(resp, 4); err != nil {
+		t.Fatalf("failed to send the second chunk: %v", err)
+	}
+
+	// the last chunk is sent with the PUT
+	req, _ = us.NewFinishRequest(ctx, dig.String(), bytes.NewReader(blob[8:]), 2)
+	resp = send(t, req.Method, req.URL.String(), req.Header, blob[8:])
+	loc, err := us.HandleFinishResponse(resp, dig.String())
+	if err != nil {
+		t.Fatalf("failed to finish upload: %v", err)
+	}
+	resp = send(t, http.MethodGet, loc.String(), nil, nil)
+	expectResponse(t, resp, http.StatusOK, "")
+	out, _ := io.ReadAll(resp.Body)
+	if !bytes.Equal(out, blob) || resp.Header.Get(v1.ContentDigestHeader) != dig.String() {
+		t.Errorf("expected %s with digest %s, received %s with digest %s", blob, dig, out, resp.Header.Get(v1.ContentDigestHeader))
+	}
+
+	// the session is closed
+	req, _ = us.NewStatusRequest(ctx)
+	expectResponse(t, send(t, req.Method, req.URL.String(), nil, nil), http.StatusNotFound, v1.ErrBlobUploadUnknown)
+
+	// a mismatched digest and a cancelled session
+	resp = send(t, http.MethodPost, urls.BlobUpload("repo"), nil, nil)
+	us, err = v1.NewUploadSession(resp)
+	if err != nil {
+		t.Fatalf("failed to start upload: %v", err)
+	}
+	req, _ = us.NewFinishRequest(ctx, digest.FromString("other").String(), bytes.NewReader(blob), int64(len(blob)))
+	expectResponse(t, send(t, req.Method, req.URL.String(), req.Header, blob), http.StatusBadRequest, v1.ErrDigestInvalid)
+	req, _ = us.NewCancelRequest(ctx)
+	expectResponse(t, send(t, req.Method, req.URL.String(), nil, nil), http.StatusNoContent, "")
+	if len(reg.uploads) != 0 {
+		t.Errorf("expected no open sessions, received %d", len(reg.uploads))
+	}
+
+	// monolithic upload
+	other := []byte("monolithic")
+	resp = send(t, http.MethodPost, urls.BlobUploadDigest("repo", digest.FromBytes(other).String()), http.Header{"Content-Type": {v1.MediaTypeOctetStream}}, other)
+	expectResponse(t, resp, http.StatusCreated, "")
+	if resp.Header.Get("Location") != "/v2/repo/blobs/"+digest.FromBytes(other).String() {
+		t.Errorf("unexpected Location %q", resp.Header.Get("Location"))
+	}
+	expectResponse(t, send(t, http.MethodHead, urls.Blob("repo", digest.FromString("missing").String()), nil, nil), http.StatusNotFound, "")
+}
+
+func TestHandlerManifest(t *testing.T) {
+	reg := newMemRegistry()
+	s := httptest.NewServer(NewHandler(reg, WithReferrersPageSize(1), WithManifestMaxSize(1024)))
+	defer s.Close()
+	urls, err := v1.NewURLBuilder(s.URL)
+	if err != nil {
+		t.Fatalf("failed to create url builder: %v", err)
+	}
+	ctHeader := http.Header{"Content-Type": {image.MediaTypeImageManifest}}
+	subjectRaw, _ := json.Marshal(image.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: image.MediaTypeImageManifest,
+		Config:    image.DescriptorEmptyJSON,
+		Layers:    []image.Descriptor{image.DescriptorEmptyJSON},
+	})
+	subject := image.Descriptor{MediaType: image.MediaTypeImageManifest, Digest: digest.FromBytes(subjectRaw), Size: int64(len(subjectRaw))}
+
+	resp := send(t, http.MethodPut, urls.Manifest("repo", "v1"), ctHeader, subjectRaw)
+	expectResponse(t, resp, http.StatusCreated, "")
+	if resp.Header.Get(v1.ContentDigestHeader) != subject.Digest.String() || resp.Header.Get("Location") != "/v2/repo/manifests/"+subject.Digest.String() {
+		t.Errorf("unexpected headers %v", resp.Header)
+	}
+	resp = send(t, http.MethodHead, urls.Manifest("repo", "v1"), nil, nil)
+	expectResponse(t, resp, http.StatusOK, "")
+	if resp.Header.Get("Content-Type") != image.MediaTypeImageManifest || resp.ContentLength != subject.Size {
+		t.Errorf("unexpected HEAD headers %v", resp.Header)
+	}
+	resp = send(t, http.MethodGet, urls.Manifest("repo", subject.Digest.String()), nil, nil)
+	expectResponse(t, resp, http.StatusOK, "")
+	if out, _ := io.ReadAll(resp.Body); !bytes.Equal(out, subjectRaw) {
+		t.Errorf("expected %s, received %s", subjectRaw, out)
+	}
+
+	// invalid pushes
+	expectResponse(t, send(t, http.MethodPut, urls.Manifest("repo", digest.FromString("other").String()), ctHeader, subjectRaw), http.StatusBadRequest, v1.ErrDigestInvalid)
+	expectResponse(t, send(t, http.MethodPut, urls.Manifest("repo", "v1"), ctHeader, bytes.Repeat([]byte(" "), 1025)), http.StatusRequestEntityTooLarge, "")
+	expectResponse(t, send(t, http.MethodPut, urls.Manifest("repo", "-v1"), ctHeader, subjectRaw), http.StatusBadRequest, "")
+
+	// tags pushed with the digest are returned in the tag list
+	resp = send(t, http.MethodPut, urls.ManifestTags("repo", subject.Digest.String(), "v2", "latest"), ctHeader, subjectRaw)
+	expectResponse(t, resp, http.StatusCreated, "")
+	if resp.Header.Get(v1.TagHeader) != "v2, latest" {
+		t.Errorf("unexpected %s header %q", v1.TagHeader, resp.Header.Get(v1.TagHeader))
+	}
+	resp = send(t, http.MethodGet, urls.TagList("repo", 2, ""), nil, nil)
+	expectResponse(t, resp, http.StatusOK, "")
+	tl := v1.TagList{}
+	_ = json.NewDecoder(resp.Body).Decode(&tl)
+	if strings.Join(tl.Tags, ",") != "latest,v1" || resp.Header.Get("Link") == "" {
+		t.Errorf("unexpected tag list %v with Link %q", tl.Tags, resp.Header.Get("Link"))
+	}
+
+	// referrers are paginated with a page size of 1
+	for _, at := range []string{"application/vnd.example.a", "application/vnd.example.b"} {
+		raw, _ := json.Marshal(image.Manifest{
+			Versioned:    specs.Versioned{SchemaVersion: 2},
+			MediaType:    image.MediaTypeImageManifest,
+			ArtifactType: at,
+			Config:       image.DescriptorEmptyJSON,
+			Layers:       []image.Descriptor{image.DescriptorEmptyJSON},
+			Subject:      &subject,
+		})
+		resp = send(t, http.MethodPut, urls.Manifest("repo", digest.FromBytes(raw).String()), ctHeader, raw)
+		expectResponse(t, resp, http.StatusCreated, "")
+		if resp.Header.Get(v1.SubjectHeader) != subject.Digest.String() {
+			t.Errorf("expected %s header %s, received %q", v1.SubjectHeader, subject.Digest, resp.Header.Get(v1.SubjectHeader))
+		}
+	}
+	resp = send(t, http.MethodGet, urls.Referrers("repo", subject.Digest.String(), ""), nil, nil)
+	expectResponse(t, resp, http.StatusOK, "")
+	index := image.Index{}
+	_ = json.NewDecoder(resp.Body).Decode(&index)
+	if len(index.Manifests) != 1 || resp.Header.Get("Link") == "" {
+		t.Errorf("expected 1 referrer and a Link header, received %d and %q", len(index.Manifests), resp.Header.Get("Link"))
+	}
+	resp = send(t, http.MethodGet, urls.Referrers("repo", subject.Digest.String(), "application/vnd.example.b"), nil, nil)
+	expectResponse(t, resp, http.StatusOK, "")
+	index = image.Index{}
+	_ = json.NewDecoder(resp.Body).Decode(&index)
+	if len(index.Manifests) != 1 || index.Manifests[0].ArtifactType != "application/vnd.example.b" || resp.Header.Get(v1.FiltersAppliedHeader) != "artifactType" {
+		t.Errorf("unexpected filtered referrers %v with %s %q", index.Manifests, v1.FiltersAppliedHeader, resp.Header.Get(v1.FiltersAppliedHeader))
+	}
+
+	// deleting the digest removes the tags
+	expectResponse(t, send(t, http.MethodDelete, urls.Manifest("repo", "v1"), nil, nil), http.StatusAccepted, "")
+	expectResponse(t, send(t, http.MethodGet, urls.Manifest("repo", "v1"), nil, nil), http.StatusNotFound, v1.ErrManifestUnknown)
+	expectResponse(t, send(t, http.MethodDelete, urls.Manifest("repo", subject.Digest.String()), nil, nil), http.StatusAccepted, "")
+	expectResponse(t, send(t, http.MethodGet, urls.Manifest("repo", "latest"), nil, nil), http.StatusNotFound, v1.ErrManifestUnknown)
+}
@@ -0,0 +1,93 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server adapts a Registry to an http.Handler implementing the distribution API.
+// The Handler routes requests for each endpoint in the spec, validates the parameters,
+// and generates the status codes, headers, and error responses,
+// leaving storage to the Registry.
+package server
+
+import (
+	"context"
+	"io"
+
+	digest "github.com/opencontainers/go-digest"
+	image "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Registry stores the content served by a Handler.
+//
+// Errors select the response sent to the client.
+// Return a v1.ErrorCode (or an error wrapping one), such as v1.ErrBlobUnknown,
+// or a *v1.ErrorResponse to include a custom message.
+// Any other error results in a 500 Internal Server Error.
+type Registry interface {
+	// BlobStat returns the descriptor of a blob in the repository.
+	BlobStat(ctx context.Context, repo string, dig digest.Digest) (image.Descriptor, error)
+	// BlobOpen returns the content and descriptor of a blob in the repository.
+	BlobOpen(ctx context.Context, repo string, dig digest.Digest) (io.ReadCloser, image.Descriptor, error)
+
+	// UploadCreate opens an upload session in the repository and returns the session ID.
+	// The ID is used as the last component of the <blob-push-location>, and must not contain a "/".
+	UploadCreate(ctx context.Context, repo string) (string, error)
+	// UploadStatus returns the number of bytes received by the session.
+	UploadStatus(ctx context.Context, repo, id string) (int64, error)
+	// UploadWrite appends the content of r to the session and returns the new number of bytes received.
+	// The Handler verifies the offset of each chunk before calling UploadWrite.
+	UploadWrite(ctx context.Context, repo, id string, r io.Reader) (int64, error)
+	// UploadCommit closes the session, storing the content as a blob when it matches the digest.
+	// A mismatched digest should return v1.ErrDigestInvalid.
+	UploadCommit(ctx context.Context, repo, id string, dig digest.Digest) (image.Descriptor, error)
+	// UploadCancel closes the session, discarding any content.
+	UploadCancel(ctx context.Context, repo, id string) error
+
+	// ManifestGet returns the descriptor and content of a manifest by tag or digest.
+	ManifestGet(ctx context.Context, repo, ref string) (image.Descriptor, []byte, error)
+	// ManifestPut stores a manifest by tag or digest.
+	// The Handler computes the digest and size in desc, and verifies the digest when ref is a digest.
+	// Registries should verify the blobs and manifests referenced by the content exist,
+	// returning v1.ErrManifestBlobUnknown otherwise.
+	ManifestPut(ctx context.Context, repo, ref string, desc image.Descriptor, raw []byte) error
+
+	// TagList returns every tag in the repository in any order.
+	// The Handler sorts and paginates the response.
+	TagList(ctx context.Context, repo string) ([]string, error)
+}
+
+// BlobDeleter is implemented by a Registry that supports deleting blobs (end-10).
+// Without it, the Handler responds with a 405.
+type BlobDeleter interface {
+	BlobDelete(ctx context.Context, repo string, dig digest.Digest) error
+}
+
+// ManifestDeleter is implemented by a Registry that supports deleting manifests and tags (end-9).
+// Without it, the Handler responds with a 405.
+type ManifestDeleter interface {
+	ManifestDelete(ctx context.Context, repo, ref string) error
+}
+
+// BlobMounter is implemented by a Registry that supports mounting blobs from another repository (end-11).
+// The from repository is empty when the client requests the registry find the blob.
+// When the mount fails or BlobMounter is not implemented, the Handler opens an upload session instead.
+type BlobMounter interface {
+	BlobMount(ctx context.Context, repo, from string, dig digest.Digest) (image.Descriptor, error)
+}
+
+// ReferrersLister is implemented by a Registry that supports the referrers API (end-12a and end-12b).
+// The descriptors can be generated with v1.ReferrerDescriptor when each manifest is pushed.
+// The Handler applies the artifactType filter and paginates the response with v1.ReferrersPage.
+// Without it, the Handler responds with a 404 and does not return the OCI-Subject header.
+type ReferrersLister interface {
+	Referrers(ctx context.Context, repo string, subject digest.Digest) ([]image.Descriptor, error)
+}
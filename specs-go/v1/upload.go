@@ -0,0 +1,283 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ChunkMinLengthHeader is returned by a registry with a minimum chunk size for uploads.
+	ChunkMinLengthHeader = "OCI-Chunk-Min-Length"
+	// ContentDigestHeader contains the digest of the blob or manifest in a response.
+	ContentDigestHeader = "Docker-Content-Digest"
+	// UploadUUIDHeader is an optional header containing the ID of an upload session.
+	UploadUUIDHeader = "Docker-Upload-UUID"
+	// MediaTypeOctetStream is the content type of a blob upload.
+	MediaTypeOctetStream = "application/octet-stream"
+)
+
+var (
+	// ErrUploadResponseInvalid is returned when a registry response to an upload request does not follow the spec.
+	ErrUploadResponseInvalid = errors.New("upload response is invalid")
+	// ErrUploadRangeNotSatisfiable is returned when a chunk was rejected with a 416, the status should be queried to resume the upload.
+	ErrUploadRangeNotSatisfiable = errors.New("upload chunk was out of order")
+)
+
+// FormatContentRange returns the Content-Range value for a chunk, inclusive on both ends.
+func FormatContentRange(start, end int64) string {
+	return fmt.Sprintf("%d-%d", start, end)
+}
+
+// ParseUploadRange parses a Range header from an upload response in the form "0-<end-of-range>",
+// returning the offset of the next byte to upload.
+//
+// The spec has no range for a session without any bytes, and registries report an empty session as "0-0".
+// That value is parsed as an offset of 0, matching the Range sent by the server package.
+// A session containing a single byte reports the same value, so callers that know a byte was accepted,
+// such as HandleChunkResponse, treat "0-0" as an offset of 1.
+func ParseUploadRange(value string) (int64, error) {
+	if !strings.HasPrefix(value, "0-") {
+		return 0, fmt.Errorf("%w: range %q must begin with \"0-\"", ErrUploadResponseInvalid, value)
+	}
+	i, err := strconv.ParseInt(strings.TrimPrefix(value, "0-"), 10, 64)
+	if err != nil || i < 0 || i == math.MaxInt64 {
+		return 0, fmt.Errorf("%w: range %q must end with a byte offset", ErrUploadResponseInvalid, value)
+	}
+	if i == 0 {
+		return 0, nil
+	}
+	return i + 1, nil
+}
+
+// UploadSession tracks the state of a blob upload session on a registry.
+// Requests are generated by the session and sent by the caller,
+// with each response passed back to the session to be validated and update the state.
+type UploadSession struct {
+	// Location is the current <blob-push-location>, resolved to an absolute URL.
+	Location *url.URL
+	// Offset is the number of bytes accepted by the registry.
+	Offset int64
+	// ChunkMinLength is the minimum chunk size from the OCI-Chunk-Min-Length header, or 0 if not provided.
+	ChunkMinLength int64
+	// UUID is the value of the optional Docker-Upload-UUID header.
+	UUID string
+}
+
+// NewUploadSession creates a session from the 202 response to a POST request (end-4a, end-4c, or end-11).
+func NewUploadSession(resp *http.Response) (*UploadSession, error) {
+	if resp.StatusCode != http.StatusAccepted {
+		return nil, unexpectedUploadStatus(resp, http.StatusAccepted)
+	}
+	s := &UploadSession{}
+	if err := s.updateLocation(resp); err != nil {
+		return nil, err
+	}
+	if minStr := resp.Header.Get(ChunkMinLengthHeader); minStr != "" {
+		minLen, err := strconv.ParseInt(minStr, 10, 64)
+		if err != nil || minLen < 0 {
+			return nil, fmt.Errorf("%w: %s header %q is not a valid size", ErrUploadResponseInvalid, ChunkMinLengthHeader, minStr)
+		}
+		s.ChunkMinLength = minLen
+	}
+	return s, nil
+}
+
+// ResumeUploadSession creates a session from the 204 response to a GET on the <blob-push-location> (end-13).
+func ResumeUploadSession(resp *http.Response) (*UploadSession, error) {
+	s := &UploadSession{}
+	if err := s.HandleStatusResponse(resp); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ChunkSize returns the preferred chunk size, increased to the minimum chunk size from the registry.
+func (s *UploadSession) ChunkSize(preferred int64) int64 {
+	if preferred < s.ChunkMinLength {
+		return s.ChunkMinLength
+	}
+	return preferred
+}
+
+// NewChunkRequest returns a PATCH request uploading the next chunk (end-5).
+// When length is negative, the chunk is streamed without the Content-Range and Content-Length headers.
+func (s *UploadSession) NewChunkRequest(ctx context.Context, body io.Reader, length int64) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, s.Location.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", MediaTypeOctetStream)
+	if length >= 0 {
+		req.ContentLength = length
+		req.Header.Set("Content-Range", FormatContentRange(s.Offset, s.Offset+length-1))
+	}
+	return req, nil
+}
+
+// HandleChunkResponse validates the response to a chunk request and updates the location and offset.
+// The length must match the value used to create the request, with a negative value skipping the verification of the Range header.
+// ErrUploadRangeNotSatisfiable is returned for a 416 response, use a status request to resume the upload.
+func (s *UploadSession) HandleChunkResponse(resp *http.Response, length int64) error {
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return fmt.Errorf("%w: registry returned %d", ErrUploadRangeNotSatisfiable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return unexpectedUploadStatus(resp, http.StatusAccepted)
+	}
+	if err := s.updateLocation(resp); err != nil {
+		return err
+	}
+	rangeHeader := resp.Header.Get("Range")
+	if rangeHeader == "" {
+		return fmt.Errorf("%w: Range header is missing", ErrUploadResponseInvalid)
+	}
+	offset, err := ParseUploadRange(rangeHeader)
+	if err != nil {
+		return err
+	}
+	if offset == 0 && length > 0 && s.Offset+length == 1 {
+		// "0-0" after uploading a single byte
+		offset = 1
+	}
+	if length >= 0 && offset != s.Offset+length {
+		return fmt.Errorf("%w: Range header %q does not match the uploaded chunk, expected \"0-%d\"", ErrUploadResponseInvalid, rangeHeader, s.Offset+length-1)
+	}
+	s.Offset = offset
+	return nil
+}
+
+// NewStatusRequest returns a GET request for the status of the upload (end-13).
+func (s *UploadSession) NewStatusRequest(ctx context.Context) (*http.Request, error) {
+	return http.NewRequestWithContext(ctx, http.MethodGet, s.Location.String(), nil)
+}
+
+// HandleStatusResponse validates the response to a status request and updates the location and offset.
+func (s *UploadSession) HandleStatusResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusNoContent {
+		return unexpectedUploadStatus(resp, http.StatusNoContent)
+	}
+	if err := s.updateLocation(resp); err != nil {
+		return err
+	}
+	rangeHeader := resp.Header.Get("Range")
+	if rangeHeader == "" {
+		return fmt.Errorf("%w: Range header is missing", ErrUploadResponseInvalid)
+	}
+	offset, err := ParseUploadRange(rangeHeader)
+	if err != nil {
+		return err
+	}
+	s.Offset = offset
+	return nil
+}
+
+// NewFinishRequest returns the PUT request closing the session (end-6) with the digest of the entire blob.
+// The body is an optional final chunk, and is ignored when length is 0.
+func (s *UploadSession) NewFinishRequest(ctx context.Context, dig string, body io.Reader, length int64) (*http.Request, error) {
+	u := *s.Location
+	q := u.Query()
+	q.Set("digest", dig)
+	u.RawQuery = q.Encode()
+	if length <= 0 {
+		body = nil
+		length = 0
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	req.Header.Set("Content-Type", MediaTypeOctetStream)
+	if length > 0 {
+		req.Header.Set("Content-Range", FormatContentRange(s.Offset, s.Offset+length-1))
+	}
+	return req, nil
+}
+
+// HandleFinishResponse validates the response to the closing PUT request and returns the <blob-location>.
+// ErrUploadRangeNotSatisfiable is returned for a 416 response to a final chunk.
+func (s *UploadSession) HandleFinishResponse(resp *http.Response, dig string) (*url.URL, error) {
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return nil, fmt.Errorf("%w: registry returned %d", ErrUploadRangeNotSatisfiable, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return nil, unexpectedUploadStatus(resp, http.StatusCreated)
+	}
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return nil, fmt.Errorf("%w: Location header is missing", ErrUploadResponseInvalid)
+	}
+	u, err := s.Location.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Location header %q: %v", ErrUploadResponseInvalid, loc, err)
+	}
+	if digHeader := resp.Header.Get(ContentDigestHeader); digHeader != "" && dig != "" && digHeader != dig {
+		return nil, fmt.Errorf("%w: %s header %q does not match %q", ErrUploadResponseInvalid, ContentDigestHeader, digHeader, dig)
+	}
+	return u, nil
+}
+
+// NewCancelRequest returns a DELETE request canceling the upload (end-14).
+// Clients should ignore any failures from this request.
+func (s *UploadSession) NewCancelRequest(ctx context.Context) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.Location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = 0
+	return req, nil
+}
+
+// updateLocation sets the location from the response, resolving relative values against the request URL.
+func (s *UploadSession) updateLocation(resp *http.Response) error {
+	loc := resp.Header.Get("Location")
+	if loc == "" {
+		return fmt.Errorf("%w: Location header is missing", ErrUploadResponseInvalid)
+	}
+	u, err := url.Parse(loc)
+	if err != nil {
+		return fmt.Errorf("%w: Location header %q: %v", ErrUploadResponseInvalid, loc, err)
+	}
+	switch {
+	case resp.Request != nil && resp.Request.URL != nil:
+		u = resp.Request.URL.ResolveReference(u)
+	case s.Location != nil:
+		u = s.Location.ResolveReference(u)
+	}
+	if !u.IsAbs() {
+		return fmt.Errorf("%w: Location header %q could not be resolved to an absolute URL", ErrUploadResponseInvalid, loc)
+	}
+	s.Location = u
+	if uuid := resp.Header.Get(UploadUUIDHeader); uuid != "" {
+		s.UUID = uuid
+	}
+	return nil
+}
+
+func unexpectedUploadStatus(resp *http.Response, expected int) error {
+	if err := ParseErrorResponse(resp); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: expected status %d, received %d", ErrUploadResponseInvalid, expected, resp.StatusCode)
+}
@@ -0,0 +1,342 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseUploadRange(t *testing.T) {
+	tt := []struct {
+		value     string
+		expect    int64
+		expectErr bool
+	}{
+		{value: "0-0", expect: 0},
+		{value: "0-1", expect: 2},
+		{value: "0-1023", expect: 1024},
+		{value: "0-9223372036854775806", expect: 9223372036854775807},
+		{value: "0-9223372036854775807", expectErr: true},
+		{value: "0-9223372036854775808", expectErr: true},
+		{value: "1-1023", expectErr: true},
+		{value: "1023", expectErr: true},
+		{value: "bytes=0-1023", expectErr: true},
+		{value: "0-", expectErr: true},
+		{value: "0--1", expectErr: true},
+		{value: "0-abc", expectErr: true},
+		{value: "", expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.value, func(t *testing.T) {
+			offset, err := ParseUploadRange(tc.value)
+			if tc.expectErr {
+				if !errors.Is(err, ErrUploadResponseInvalid) {
+					t.Errorf("expected error %v, received %v", ErrUploadResponseInvalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if offset != tc.expect {
+				t.Errorf("expected %d, received %d", tc.expect, offset)
+			}
+		})
+	}
+}
+
+// uploadResponse returns a response to a request for the URL with the status and headers.
+func uploadResponse(t *testing.T, method, u string, status int, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp := &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+	for k, v := range headers {
+		resp.Header.Set(k, v)
+	}
+	return resp
+}
+
+func TestNewUploadSession(t *testing.T) {
+	postURL := "https://registry.example.com/v2/repo/blobs/uploads/"
+	tt := []struct {
+		name         string
+		status       int
+		headers      map[string]string
+		expectLoc    string
+		expectMin    int64
+		expectUUID   string
+		expectErr    error
+		expectStatus int
+	}{
+		{
+			name:      "relative location",
+			status:    http.StatusAccepted,
+			headers:   map[string]string{"Location": "/v2/repo/blobs/uploads/abc?state=1"},
+			expectLoc: "https://registry.example.com/v2/repo/blobs/uploads/abc?state=1",
+		},
+		{
+			name:   "absolute location with min length and uuid",
+			status: http.StatusAccepted,
+			headers: map[string]string{
+				"Location":           "https://upload.example.com/session/abc",
+				ChunkMinLengthHeader: "1024",
+				UploadUUIDHeader:     "abc",
+			},
+			expectLoc:  "https://upload.example.com/session/abc",
+			expectMin:  1024,
+			expectUUID: "abc",
+		},
+		{
+			name:      "missing location",
+			status:    http.StatusAccepted,
+			expectErr: ErrUploadResponseInvalid,
+		},
+		{
+			name:      "invalid min length",
+			status:    http.StatusAccepted,
+			headers:   map[string]string{"Location": "/v2/repo/blobs/uploads/abc", ChunkMinLengthHeader: "-1"},
+			expectErr: ErrUploadResponseInvalid,
+		},
+		{
+			name:      "unexpected success",
+			status:    http.StatusCreated,
+			headers:   map[string]string{"Location": "/v2/repo/blobs/sha256:abc"},
+			expectErr: ErrUploadResponseInvalid,
+		},
+		{
+			name:         "error response",
+			status:       http.StatusForbidden,
+			expectStatus: http.StatusForbidden,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewUploadSession(uploadResponse(t, http.MethodPost, postURL, tc.status, tc.headers))
+			if tc.expectStatus != 0 {
+				re := &ResponseError{}
+				if !errors.As(err, &re) || re.StatusCode != tc.expectStatus {
+					t.Errorf("expected a ResponseError with status %d, received %v", tc.expectStatus, err)
+				}
+				return
+			}
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Errorf("expected error %v, received %v", tc.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Location.String() != tc.expectLoc || s.ChunkMinLength != tc.expectMin || s.UUID != tc.expectUUID || s.Offset != 0 {
+				t.Errorf("unexpected session %+v", s)
+			}
+		})
+	}
+}
+
+func TestUploadSessionChunks(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewUploadSession(uploadResponse(t, http.MethodPost, "https://registry.example.com/v2/repo/blobs/uploads/", http.StatusAccepted,
+		map[string]string{"Location": "/v2/repo/blobs/uploads/abc?state=0", ChunkMinLengthHeader: "4"}))
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	if s.ChunkSize(2) != 4 || s.ChunkSize(8) != 8 {
+		t.Errorf("unexpected chunk sizes %d and %d", s.ChunkSize(2), s.ChunkSize(8))
+	}
+
+	// first chunk
+	req, err := s.NewChunkRequest(ctx, strings.NewReader("abcd"), 4)
+	if err != nil {
+		t.Fatalf("failed to create chunk request: %v", err)
+	}
+	if req.Method != http.MethodPatch || req.Header.Get("Content-Range") != "0-3" || req.ContentLength != 4 || req.Header.Get("Content-Type") != MediaTypeOctetStream {
+		t.Errorf("unexpected chunk request %s %v %d", req.Method, req.Header, req.ContentLength)
+	}
+	resp := uploadResponse(t, http.MethodPatch, req.URL.String(), http.StatusAccepted, map[string]string{"Location": "/v2/repo/blobs/uploads/abc?state=1", "Range": "0-3"})
+	if err := s.HandleChunkResponse(resp, 4); err != nil {
+		t.Fatalf("failed to handle chunk response: %v", err)
+	}
+	if s.Offset != 4 || s.Location.RawQuery != "state=1" {
+		t.Errorf("unexpected session after the first chunk: %+v", s)
+	}
+
+	// a Range that does not match the chunk leaves the session unchanged
+	resp = uploadResponse(t, http.MethodPatch, s.Location.String(), http.StatusAccepted, map[string]string{"Location": "/v2/repo/blobs/uploads/abc?state=2", "Range": "0-5"})
+	if err := s.HandleChunkResponse(resp, 4); !errors.Is(err, ErrUploadResponseInvalid) {
+		t.Errorf("expected error %v, received %v", ErrUploadResponseInvalid, err)
+	}
+	if s.Offset != 4 {
+		t.Errorf("offset changed after an invalid response: %d", s.Offset)
+	}
+	resp = uploadResponse(t, http.MethodPatch, s.Location.String(), http.StatusAccepted, map[string]string{"Location": "/v2/repo/blobs/uploads/abc"})
+	if err := s.HandleChunkResponse(resp, 4); !errors.Is(err, ErrUploadResponseInvalid) {
+		t.Errorf("missing Range: expected error %v, received %v", ErrUploadResponseInvalid, err)
+	}
+
+	// a rejected chunk is resumed with the status
+	resp = uploadResponse(t, http.MethodPatch, s.Location.String(), http.StatusRequestedRangeNotSatisfiable, nil)
+	if err := s.HandleChunkResponse(resp, 4); !errors.Is(err, ErrUploadRangeNotSatisfiable) {
+		t.Errorf("expected error %v, received %v", ErrUploadRangeNotSatisfiable, err)
+	}
+	req, err = s.NewStatusRequest(ctx)
+	if err != nil || req.Method != http.MethodGet {
+		t.Fatalf("unexpected status request: %v", err)
+	}
+	resp = uploadResponse(t, http.MethodGet, req.URL.String(), http.StatusNoContent, map[string]string{"Location": "/v2/repo/blobs/uploads/abc?state=3", "Range": "0-5"})
+	if err := s.HandleStatusResponse(resp); err != nil {
+		t.Fatalf("failed to handle status response: %v", err)
+	}
+	if s.Offset != 6 || s.Location.RawQuery != "state=3" {
+		t.Errorf("unexpected session after the status: %+v", s)
+	}
+	resp = uploadResponse(t, http.MethodGet, req.URL.String(), http.StatusOK, map[string]string{"Location": "/v2/repo/blobs/uploads/abc", "Range": "0-5"})
+	if err := s.HandleStatusResponse(resp); !errors.Is(err, ErrUploadResponseInvalid) {
+		t.Errorf("expected error %v for a 200 status, received %v", ErrUploadResponseInvalid, err)
+	}
+
+	// streamed chunk without a range
+	req, err = s.NewChunkRequest(ctx, strings.NewReader("gh"), -1)
+	if err != nil {
+		t.Fatalf("failed to create chunk request: %v", err)
+	}
+	if req.Header.Get("Content-Range") != "" {
+		t.Errorf("streamed chunk included a Content-Range: %s", req.Header.Get("Content-Range"))
+	}
+	resp = uploadResponse(t, http.MethodPatch, req.URL.String(), http.StatusAccepted, map[string]string{"Location": "/v2/repo/blobs/uploads/abc?state=4", "Range": "0-7"})
+	if err := s.HandleChunkResponse(resp, -1); err != nil || s.Offset != 8 {
+		t.Fatalf("failed to handle streamed chunk response, offset %d: %v", s.Offset, err)
+	}
+
+	// final chunk in the PUT
+	dig := "sha256:" + strings.Repeat("0", 64)
+	req, err = s.NewFinishRequest(ctx, dig, strings.NewReader("ij"), 2)
+	if err != nil {
+		t.Fatalf("failed to create finish request: %v", err)
+	}
+	if req.Method != http.MethodPut || req.URL.Query().Get("digest") != dig || req.URL.Query().Get("state") != "4" || req.Header.Get("Content-Range") != "8-9" {
+		t.Errorf("unexpected finish request %s %s %v", req.Method, req.URL, req.Header)
+	}
+	resp = uploadResponse(t, http.MethodPut, req.URL.String(), http.StatusCreated, map[string]string{"Location": "/v2/repo/blobs/" + dig, ContentDigestHeader: dig})
+	loc, err := s.HandleFinishResponse(resp, dig)
+	if err != nil || loc.String() != "https://registry.example.com/v2/repo/blobs/"+dig {
+		t.Errorf("unexpected blob location %v: %v", loc, err)
+	}
+	resp = uploadResponse(t, http.MethodPut, req.URL.String(), http.StatusCreated, map[string]string{"Location": "/v2/repo/blobs/" + dig, ContentDigestHeader: "sha256:" + strings.Repeat("1", 64)})
+	if _, err := s.HandleFinishResponse(resp, dig); !errors.Is(err, ErrUploadResponseInvalid) {
+		t.Errorf("mismatched digest: expected error %v, received %v", ErrUploadResponseInvalid, err)
+	}
+	resp = uploadResponse(t, http.MethodPut, req.URL.String(), http.StatusRequestedRangeNotSatisfiable, nil)
+	if _, err := s.HandleFinishResponse(resp, dig); !errors.Is(err, ErrUploadRangeNotSatisfiable) {
+		t.Errorf("expected error %v, received %v", ErrUploadRangeNotSatisfiable, err)
+	}
+	req, err = s.NewFinishRequest(ctx, dig, strings.NewReader("ignored"), 0)
+	if err != nil || req.ContentLength != 0 || req.Header.Get("Content-Range") != "" {
+		t.Errorf("unexpected finish request without a chunk: %v", err)
+	}
+}
+
+func TestUploadSessionEmptyRange(t *testing.T) {
+	// a new session reports "0-0" and resumes from the start
+	s, err := ResumeUploadSession(uploadResponse(t, http.MethodGet, "https://registry.example.com/v2/repo/blobs/uploads/abc", http.StatusNoContent,
+		map[string]string{"Location": "/v2/repo/blobs/uploads/abc", "Range": "0-0"}))
+	if err != nil {
+		t.Fatalf("failed to resume session: %v", err)
+	}
+	if s.Offset != 0 {
+		t.Errorf("expected offset 0, received %d", s.Offset)
+	}
+	// "0-0" after a single byte chunk
+	req, err := s.NewChunkRequest(context.Background(), strings.NewReader("a"), 1)
+	if err != nil {
+		t.Fatalf("failed to create chunk request: %v", err)
+	}
+	if req.Header.Get("Content-Range") != "0-0" {
+		t.Errorf("unexpected Content-Range %s", req.Header.Get("Content-Range"))
+	}
+	resp := uploadResponse(t, http.MethodPatch, req.URL.String(), http.StatusAccepted, map[string]string{"Location": "/v2/repo/blobs/uploads/abc", "Range": "0-0"})
+	if err := s.HandleChunkResponse(resp, 1); err != nil || s.Offset != 1 {
+		t.Errorf("unexpected offset %d after a single byte: %v", s.Offset, err)
+	}
+}
+
+func TestUploadSessionChunkRange(t *testing.T) {
+	tt := []struct {
+		name         string
+		offset       int64
+		length       int64
+		rangeHeader  string
+		expectOffset int64
+		expectErr    bool
+	}{
+		{name: "single byte", offset: 0, length: 1, rangeHeader: "0-0", expectOffset: 1},
+		{name: "single byte after offset", offset: 4, length: 1, rangeHeader: "0-4", expectOffset: 5},
+		{name: "single byte not accepted", offset: 0, length: 1, rangeHeader: "0-1", expectErr: true},
+		{name: "streamed", offset: 4, length: -1, rangeHeader: "0-7", expectOffset: 8},
+		{name: "streamed empty", offset: 0, length: -1, rangeHeader: "0-0", expectOffset: 0},
+		{name: "streamed after offset with empty range", offset: 2, length: -1, rangeHeader: "0-0", expectOffset: 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := url.Parse("https://registry.example.com/v2/repo/blobs/uploads/abc")
+			if err != nil {
+				t.Fatalf("failed to parse url: %v", err)
+			}
+			s := &UploadSession{Location: u, Offset: tc.offset}
+			resp := uploadResponse(t, http.MethodPatch, u.String(), http.StatusAccepted, map[string]string{"Location": "/v2/repo/blobs/uploads/abc", "Range": tc.rangeHeader})
+			err = s.HandleChunkResponse(resp, tc.length)
+			if tc.expectErr {
+				if !errors.Is(err, ErrUploadResponseInvalid) {
+					t.Errorf("expected error %v, received %v", ErrUploadResponseInvalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s.Offset != tc.expectOffset {
+				t.Errorf("expected offset %d, received %d", tc.expectOffset, s.Offset)
+			}
+		})
+	}
+}
+
+func TestUploadSessionCancel(t *testing.T) {
+	s := &UploadSession{}
+	resp := uploadResponse(t, http.MethodPost, "https://registry.example.com/v2/repo/blobs/uploads/", http.StatusAccepted, map[string]string{"Location": "abc"})
+	if err := s.updateLocation(resp); err != nil {
+		t.Fatalf("failed to update location: %v", err)
+	}
+	req, err := s.NewCancelRequest(context.Background())
+	if err != nil || req.Method != http.MethodDelete || req.URL.String() != "https://registry.example.com/v2/repo/blobs/uploads/abc" {
+		t.Errorf("unexpected cancel request: %v", err)
+	}
+}
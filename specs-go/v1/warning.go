@@ -0,0 +1,357 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// WarningHeader is the name of the HTTP header used for warnings.
+	WarningHeader = "Warning"
+	// WarningCode is the only warn-code a registry may send.
+	WarningCode = 299
+	// WarningAgent is the only warn-agent a registry may send.
+	WarningAgent = "-"
+	// WarningMaxBytes is the limit of warning data from all headers combined in a response.
+	WarningMaxBytes = 4096
+)
+
+var (
+	// ErrWarningSyntax is returned when a Warning header cannot be parsed.
+	ErrWarningSyntax = errors.New("warning header syntax is invalid")
+	// ErrWarningCode is returned when the warn-code is not 299.
+	ErrWarningCode = errors.New("warning must use a warn-code of 299")
+	// ErrWarningAgent is returned when the warn-agent is not "-".
+	ErrWarningAgent = errors.New("warning must use a warn-agent of \"-\"")
+	// ErrWarningDate is returned when a warn-date is included.
+	ErrWarningDate = errors.New("warning must not include a warn-date")
+	// ErrWarningSize is returned when the combined warning headers exceed WarningMaxBytes.
+	ErrWarningSize = fmt.Errorf("warning headers must not exceed %d bytes", WarningMaxBytes)
+)
+
+// Warning is a single warning from a Warning header, defined in RFC 7234 (section 5.5).
+type Warning struct {
+	Code  int
+	Agent string
+	Text  string
+	// Date is the unquoted warn-date, which registries must not send.
+	Date string
+}
+
+// WarningError reports a Warning header value that does not follow the spec.
+// Err is one of the ErrWarning errors, identifying the rule that was broken.
+type WarningError struct {
+	Value  string
+	Err    error
+	Reason string
+}
+
+// Error implements the Error interface.
+func (we *WarningError) Error() string {
+	msg := we.Err.Error()
+	if we.Reason != "" {
+		msg += ": " + we.Reason
+	}
+	if we.Value != "" {
+		msg += fmt.Sprintf(" (%q)", we.Value)
+	}
+	return msg
+}
+
+// Unwrap returns the rule that was broken.
+func (we *WarningError) Unwrap() error {
+	return we.Err
+}
+
+// NewWarning returns a spec compliant Warning with the text.
+func NewWarning(text string) Warning {
+	return Warning{
+		Code:  WarningCode,
+		Agent: WarningAgent,
+		Text:  text,
+	}
+}
+
+// String formats the warning as a Warning header value.
+func (w Warning) String() string {
+	s := fmt.Sprintf("%03d %s %s", w.Code, w.Agent, quoteString(w.Text))
+	if w.Date != "" {
+		s += " " + quoteString(w.Date)
+	}
+	return s
+}
+
+// Validate checks the warning against the rules of the spec.
+func (w Warning) Validate() error {
+	if w.Code != WarningCode {
+		return &WarningError{Value: w.String(), Err: ErrWarningCode, Reason: fmt.Sprintf("received %03d", w.Code)}
+	}
+	if w.Agent != WarningAgent {
+		return &WarningError{Value: w.String(), Err: ErrWarningAgent, Reason: fmt.Sprintf("received %q", w.Agent)}
+	}
+	if w.Date != "" {
+		return &WarningError{Value: w.String(), Err: ErrWarningDate}
+	}
+	for _, c := range w.Text {
+		if c != '\t' && (c < 0x20 || c == 0x7f) {
+			return &WarningError{Value: w.String(), Err: ErrWarningSyntax, Reason: "warn-text contains a control character"}
+		}
+	}
+	return nil
+}
+
+// ParseWarnings parses a single Warning header value, which may contain a comma separated list of warnings.
+// Only the syntax is verified, use Validate to check each warning against the spec.
+func ParseWarnings(value string) ([]Warning, error) {
+	ws := []Warning{}
+	p := headerParser{s: value}
+	for {
+		p.skipListSep()
+		if p.done() {
+			break
+		}
+		w, err := p.warning()
+		if err != nil {
+			return nil, &WarningError{Value: value, Err: ErrWarningSyntax, Reason: err.Error()}
+		}
+		ws = append(ws, w)
+		p.skipOWS()
+		if !p.done() && p.peek() != ',' {
+			return nil, &WarningError{Value: value, Err: ErrWarningSyntax, Reason: fmt.Sprintf("unexpected character at offset %d", p.i)}
+		}
+	}
+	return ws, nil
+}
+
+// ParseWarningHeader parses and validates every Warning header in h.
+// The first violation of the spec is returned as a *WarningError, along with any warnings that were parsed.
+func ParseWarningHeader(h http.Header) ([]Warning, error) {
+	ws := []Warning{}
+	var errFirst error
+	size := 0
+	for _, value := range h.Values(WarningHeader) {
+		size += len(value)
+		parsed, err := ParseWarnings(value)
+		if err != nil {
+			if errFirst == nil {
+				errFirst = err
+			}
+			continue
+		}
+		for _, w := range parsed {
+			if err := w.Validate(); err != nil && errFirst == nil {
+				errFirst = err
+			}
+			ws = append(ws, w)
+		}
+	}
+	if size > WarningMaxBytes && errFirst == nil {
+		errFirst = &WarningError{Err: ErrWarningSize, Reason: fmt.Sprintf("received %d bytes", size)}
+	}
+	return ws, errFirst
+}
+
+// SetWarnings adds each warning to the Warning headers in h.
+// ErrWarningSize is returned without modifying h when the warnings would exceed WarningMaxBytes.
+func SetWarnings(h http.Header, ws ...Warning) error {
+	size := 0
+	for _, value := range h.Values(WarningHeader) {
+		size += len(value)
+	}
+	values := make([]string, 0, len(ws))
+	for _, w := range ws {
+		if err := w.Validate(); err != nil {
+			return err
+		}
+		value := w.String()
+		size += len(value)
+		values = append(values, value)
+	}
+	if size > WarningMaxBytes {
+		return &WarningError{Err: ErrWarningSize, Reason: fmt.Sprintf("%d bytes requested", size)}
+	}
+	for _, value := range values {
+		h.Add(WarningHeader, value)
+	}
+	return nil
+}
+
+// WarningCollector accumulates warnings from multiple responses, removing duplicates.
+// It is safe for concurrent use.
+// The zero value is ready to use.
+type WarningCollector struct {
+	mu       sync.Mutex
+	seen     map[string]bool
+	warnings []Warning
+}
+
+// Add records the warnings from the response headers and returns any warnings that were not previously seen.
+// Values that cannot be parsed are ignored, use ParseWarningHeader to detect invalid headers.
+func (wc *WarningCollector) Add(h http.Header) []Warning {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.seen == nil {
+		wc.seen = map[string]bool{}
+	}
+	added := []Warning{}
+	for _, value := range h.Values(WarningHeader) {
+		parsed, err := ParseWarnings(value)
+		if err != nil {
+			continue
+		}
+		for _, w := range parsed {
+			key := fmt.Sprintf("%03d %s %s", w.Code, w.Agent, w.Text)
+			if wc.seen[key] {
+				continue
+			}
+			wc.seen[key] = true
+			wc.warnings = append(wc.warnings, w)
+			added = append(added, w)
+		}
+	}
+	return added
+}
+
+// Warnings returns the deduplicated warnings in the order they were first received.
+func (wc *WarningCollector) Warnings() []Warning {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return append([]Warning{}, wc.warnings...)
+}
+
+// headerParser is used to parse lists of values in HTTP headers.
+type headerParser struct {
+	s string
+	i int
+}
+
+func (p *headerParser) done() bool {
+	return p.i >= len(p.s)
+}
+
+func (p *headerParser) peek() byte {
+	return p.s[p.i]
+}
+
+func (p *headerParser) skipOWS() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.i++
+	}
+}
+
+func (p *headerParser) skipListSep() {
+	for !p.done() && (p.peek() == ' ' || p.peek() == '\t' || p.peek() == ',') {
+		p.i++
+	}
+}
+
+func (p *headerParser) sp() error {
+	if p.done() || p.peek() != ' ' {
+		return fmt.Errorf("expected a space at offset %d", p.i)
+	}
+	p.i++
+	return nil
+}
+
+// warning parses the grammar from RFC 7234 (section 5.5):
+//
+//	warning-value = warn-code SP warn-agent SP warn-text [ SP warn-date ]
+func (p *headerParser) warning() (Warning, error) {
+	w := Warning{}
+	if p.i+3 > len(p.s) {
+		return w, fmt.Errorf("missing warn-code")
+	}
+	for _, c := range p.s[p.i : p.i+3] {
+		if c < '0' || c > '9' {
+			return w, fmt.Errorf("warn-code must be 3 digits")
+		}
+		w.Code = w.Code*10 + int(c-'0')
+	}
+	p.i += 3
+	if err := p.sp(); err != nil {
+		return w, err
+	}
+	start := p.i
+	for !p.done() && p.peek() != ' ' && p.peek() != ',' && p.peek() != '"' {
+		p.i++
+	}
+	w.Agent = p.s[start:p.i]
+	if w.Agent == "" {
+		return w, fmt.Errorf("missing warn-agent")
+	}
+	if err := p.sp(); err != nil {
+		return w, err
+	}
+	text, err := p.quotedString()
+	if err != nil {
+		return w, fmt.Errorf("warn-text: %w", err)
+	}
+	w.Text = text
+	if !p.done() && p.peek() == ' ' && p.i+1 < len(p.s) && p.s[p.i+1] == '"' {
+		p.i++
+		date, err := p.quotedString()
+		if err != nil {
+			return w, fmt.Errorf("warn-date: %w", err)
+		}
+		w.Date = date
+	}
+	return w, nil
+}
+
+func (p *headerParser) quotedString() (string, error) {
+	if p.done() || p.peek() != '"' {
+		return "", fmt.Errorf("expected a quoted string at offset %d", p.i)
+	}
+	p.i++
+	var sb strings.Builder
+	for !p.done() {
+		c := p.peek()
+		p.i++
+		switch {
+		case c == '"':
+			return sb.String(), nil
+		case c == '\\':
+			if p.done() {
+				return "", fmt.Errorf("unterminated quoted-pair")
+			}
+			sb.WriteByte(p.peek())
+			p.i++
+		case c != '\t' && (c < 0x20 || c == 0x7f):
+			return "", fmt.Errorf("control character at offset %d", p.i-1)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted string")
+}
+
+// quoteString returns s as an RFC 7230 quoted-string.
+func quoteString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+		sb.WriteByte(s[i])
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
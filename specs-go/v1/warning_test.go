@@ -0,0 +1,169 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseWarningHeader(t *testing.T) {
+	tt := []struct {
+		name      string
+		values    []string
+		expect    []Warning
+		expectErr error
+	}{
+		{
+			name:   "none",
+			expect: []Warning{},
+		},
+		{
+			name:   "valid",
+			values: []string{`299 - "repository is deprecated"`},
+			expect: []Warning{NewWarning("repository is deprecated")},
+		},
+		{
+			name:   "list and multiple headers",
+			values: []string{`299 - "first", 299 - "second \"quoted\""`, `299 - "third"`},
+			expect: []Warning{NewWarning("first"), NewWarning(`second "quoted"`), NewWarning("third")},
+		},
+		{
+			name:      "code",
+			values:    []string{`199 - "miscellaneous"`},
+			expect:    []Warning{{Code: 199, Agent: "-", Text: "miscellaneous"}},
+			expectErr: ErrWarningCode,
+		},
+		{
+			name:      "agent",
+			values:    []string{`299 registry.example.com "deprecated"`},
+			expect:    []Warning{{Code: 299, Agent: "registry.example.com", Text: "deprecated"}},
+			expectErr: ErrWarningAgent,
+		},
+		{
+			name:      "date",
+			values:    []string{`299 - "deprecated" "Thu, 01 Jan 2026 00:00:00 GMT"`},
+			expect:    []Warning{{Code: 299, Agent: "-", Text: "deprecated", Date: "Thu, 01 Jan 2026 00:00:00 GMT"}},
+			expectErr: ErrWarningDate,
+		},
+		{
+			name:      "unquoted text",
+			values:    []string{`299 - deprecated`},
+			expect:    []Warning{},
+			expectErr: ErrWarningSyntax,
+		},
+		{
+			name:      "short code",
+			values:    []string{`29 - "deprecated"`},
+			expect:    []Warning{},
+			expectErr: ErrWarningSyntax,
+		},
+		{
+			name:      "control character",
+			values:    []string{"299 - \"line\x01break\""},
+			expect:    []Warning{},
+			expectErr: ErrWarningSyntax,
+		},
+		{
+			name:      "unterminated",
+			values:    []string{`299 - "deprecated`},
+			expect:    []Warning{},
+			expectErr: ErrWarningSyntax,
+		},
+		{
+			name:   "limit",
+			values: []string{`299 - "` + strings.Repeat("x", WarningMaxBytes-len(`299 - ""`)) + `"`},
+			expect: []Warning{NewWarning(strings.Repeat("x", WarningMaxBytes-len(`299 - ""`)))},
+		},
+		{
+			name:      "over limit",
+			values:    []string{`299 - "` + strings.Repeat("x", WarningMaxBytes/2) + `"`, `299 - "` + strings.Repeat("y", WarningMaxBytes/2) + `"`},
+			expect:    []Warning{NewWarning(strings.Repeat("x", WarningMaxBytes/2)), NewWarning(strings.Repeat("y", WarningMaxBytes/2))},
+			expectErr: ErrWarningSize,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range tc.values {
+				h.Add(WarningHeader, v)
+			}
+			ws, err := ParseWarningHeader(h)
+			if tc.expectErr != nil {
+				we := &WarningError{}
+				if !errors.Is(err, tc.expectErr) || !errors.As(err, &we) {
+					t.Errorf("expected error %v, received %v", tc.expectErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if len(ws) != len(tc.expect) {
+				t.Fatalf("expected %d warnings, received %d: %v", len(tc.expect), len(ws), ws)
+			}
+			for i := range ws {
+				if ws[i] != tc.expect[i] {
+					t.Errorf("warning %d: expected %+v, received %+v", i, tc.expect[i], ws[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetWarnings(t *testing.T) {
+	h := http.Header{}
+	if err := SetWarnings(h, NewWarning(`say "hello"`)); err != nil {
+		t.Fatalf("failed to set warning: %v", err)
+	}
+	if h.Get(WarningHeader) != `299 - "say \"hello\""` {
+		t.Errorf("unexpected header: %s", h.Get(WarningHeader))
+	}
+	if err := SetWarnings(h, Warning{Code: 199, Agent: "-", Text: "invalid"}); !errors.Is(err, ErrWarningCode) {
+		t.Errorf("expected error %v, received %v", ErrWarningCode, err)
+	}
+	if err := SetWarnings(h, NewWarning(strings.Repeat("x", WarningMaxBytes))); !errors.Is(err, ErrWarningSize) {
+		t.Errorf("expected error %v, received %v", ErrWarningSize, err)
+	}
+	if len(h.Values(WarningHeader)) != 1 {
+		t.Errorf("header modified by a failed call: %v", h.Values(WarningHeader))
+	}
+}
+
+func TestWarningCollector(t *testing.T) {
+	wc := WarningCollector{}
+	h1 := http.Header{}
+	h1.Add(WarningHeader, `299 - "first", 299 - "second"`)
+	h2 := http.Header{}
+	h2.Add(WarningHeader, `299 - "second"`)
+	h2.Add(WarningHeader, `not a warning`)
+	h2.Add(WarningHeader, `299 - "third"`)
+	if added := wc.Add(h1); len(added) != 2 {
+		t.Errorf("expected 2 new warnings, received %v", added)
+	}
+	if added := wc.Add(h2); len(added) != 1 || added[0].Text != "third" {
+		t.Errorf("expected the third warning, received %v", added)
+	}
+	ws := wc.Warnings()
+	expect := []string{"first", "second", "third"}
+	if len(ws) != len(expect) {
+		t.Fatalf("expected %d warnings, received %v", len(expect), ws)
+	}
+	for i := range expect {
+		if ws[i].Text != expect[i] {
+			t.Errorf("warning %d: expected %s, received %s", i, expect[i], ws[i].Text)
+		}
+	}
+}
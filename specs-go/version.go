@@ -14,7 +14,12 @@
 
 package specs
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 const (
 	// VersionMajor is for an API incompatible changes
@@ -30,3 +35,85 @@ const (
 
 // Version is the specification version that the package types support.
 var Version = fmt.Sprintf("%d.%d.%d%s", VersionMajor, VersionMinor, VersionPatch, VersionDev)
+
+// versionDevSuffix marks a version with unreleased changes.
+const versionDevSuffix = "+dev"
+
+// ErrVersionInvalid is returned when a spec version cannot be parsed.
+var ErrVersionInvalid = errors.New("spec version is invalid")
+
+// SpecVersion is a release of the specification, with Dev indicating unreleased changes made after the release.
+type SpecVersion struct {
+	Major int
+	Minor int
+	Patch int
+	Dev   bool
+}
+
+// Current returns the specification version that the package types support.
+func Current() SpecVersion {
+	return SpecVersion{
+		Major: VersionMajor,
+		Minor: VersionMinor,
+		Patch: VersionPatch,
+		Dev:   VersionDev != "",
+	}
+}
+
+// ParseVersion parses a version in the form "MAJOR.MINOR[.PATCH][+dev]", with an optional "v" prefix.
+func ParseVersion(s string) (SpecVersion, error) {
+	v := SpecVersion{}
+	str := strings.TrimPrefix(s, "v")
+	if strings.HasSuffix(str, versionDevSuffix) {
+		v.Dev = true
+		str = strings.TrimSuffix(str, versionDevSuffix)
+	}
+	parts := strings.Split(str, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return SpecVersion{}, fmt.Errorf("%w: %q", ErrVersionInvalid, s)
+	}
+	nums := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return SpecVersion{}, fmt.Errorf("%w: %q", ErrVersionInvalid, s)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// String returns the version in the same format as Version.
+func (v SpecVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Dev {
+		s += versionDevSuffix
+	}
+	return s
+}
+
+// Compare returns -1, 0, or 1 when v is older, equal, or newer than o.
+// A development version is newer than the release with the same number.
+func (v SpecVersion) Compare(o SpecVersion) int {
+	for _, cmp := range [][2]int{{v.Major, o.Major}, {v.Minor, o.Minor}, {v.Patch, o.Patch}} {
+		if cmp[0] < cmp[1] {
+			return -1
+		} else if cmp[0] > cmp[1] {
+			return 1
+		}
+	}
+	switch {
+	case v.Dev == o.Dev:
+		return 0
+	case v.Dev:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// AtLeast reports if v is the same or newer than o.
+func (v SpecVersion) AtLeast(o SpecVersion) bool {
+	return v.Compare(o) >= 0
+}
@@ -0,0 +1,110 @@
+// Copyright the Open Container Initiative Contributors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package specs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tt := []struct {
+		version   string
+		expect    SpecVersion
+		expectErr bool
+	}{
+		{version: "1.0", expect: SpecVersion{Major: 1, Minor: 0}},
+		{version: "1.1.1", expect: SpecVersion{Major: 1, Minor: 1, Patch: 1}},
+		{version: "v1.1.0", expect: SpecVersion{Major: 1, Minor: 1}},
+		{version: "1.1+dev", expect: SpecVersion{Major: 1, Minor: 1, Dev: true}},
+		{version: "v1.1.1+dev", expect: SpecVersion{Major: 1, Minor: 1, Patch: 1, Dev: true}},
+		{version: "10.20.30", expect: SpecVersion{Major: 10, Minor: 20, Patch: 30}},
+		{version: Version, expect: Current()},
+		{version: "", expectErr: true},
+		{version: "1", expectErr: true},
+		{version: "1.1.1.1", expectErr: true},
+		{version: "1.01", expectErr: true},
+		{version: "1.-1", expectErr: true},
+		{version: "1.x", expectErr: true},
+		{version: "1.1-rc.1", expectErr: true},
+		{version: "1.1+build", expectErr: true},
+		{version: "dev", expectErr: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.version, func(t *testing.T) {
+			v, err := ParseVersion(tc.version)
+			if tc.expectErr {
+				if !errors.Is(err, ErrVersionInvalid) {
+					t.Errorf("expected error %v, received %v", ErrVersionInvalid, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v != tc.expect {
+				t.Errorf("expected %s, received %s", tc.expect, v)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tt := []struct {
+		a, b   string
+		expect int
+	}{
+		{a: "1.0.0", b: "1.0", expect: 0},
+		{a: "1.1+dev", b: "1.1.0+dev", expect: 0},
+		{a: "1.0", b: "1.1", expect: -1},
+		{a: "1.1", b: "1.0.5", expect: 1},
+		{a: "1.1.1", b: "1.1.0", expect: 1},
+		{a: "2.0", b: "1.10", expect: 1},
+		{a: "1.1+dev", b: "1.1", expect: 1},
+		{a: "1.1", b: "1.1+dev", expect: -1},
+		{a: "1.1+dev", b: "1.1.1", expect: -1},
+		{a: "1.1.1+dev", b: "1.1+dev", expect: 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			a, err := ParseVersion(tc.a)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			b, err := ParseVersion(tc.b)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result := a.Compare(b); result != tc.expect {
+				t.Errorf("expected %d, received %d", tc.expect, result)
+			}
+			if result := b.Compare(a); result != -tc.expect {
+				t.Errorf("reversed: expected %d, received %d", -tc.expect, result)
+			}
+			if a.AtLeast(b) != (tc.expect >= 0) {
+				t.Errorf("expected AtLeast %t", tc.expect >= 0)
+			}
+		})
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	if Current().String() != Version {
+		t.Errorf("expected %s, received %s", Version, Current().String())
+	}
+	if s := (SpecVersion{Major: 1, Minor: 1, Dev: true}).String(); s != "1.1.0+dev" {
+		t.Errorf("expected 1.1.0+dev, received %s", s)
+	}
+}